@@ -0,0 +1,9 @@
+// Package api содержит контракты сервиса: OpenAPI для HTTP, схемы Protobuf и Avro для Kafka.
+package api
+
+import _ "embed"
+
+// OpenAPI — спецификация HTTP API, отдаётся на /api/v1/openapi.json.
+//
+//go:embed openapi.json
+var OpenAPI []byte
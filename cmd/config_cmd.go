@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"wb-test-task/config"
+)
+
+const configUsage = `usage: wb-service config <command> [flags]
+
+commands:
+  print     show every setting with the layer it came from (secrets redacted)
+  validate  load and validate config, exit 1 on errors (for CI)
+
+flags are the same as for the service itself, e.g. --config config.yaml --db.host=localhost
+`
+
+// runConfigCommand обрабатывает подкоманды `config print` и `config validate`.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	flags := config.NewFlagSet("wb-service config " + args[0])
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, sources, err := config.LoadWith(config.OptionsFromFlags(flags))
+
+	switch args[0] {
+	case "print":
+		if cfg != nil {
+			fmt.Print(cfg.Describe(sources))
+		}
+	case "validate":
+		if err == nil {
+			fmt.Println("config OK")
+		}
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
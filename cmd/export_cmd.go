@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/export"
+	"wb-test-task/internal/ports"
+)
+
+const exportUsage = `usage: wb-service export --out FILE [flags]
+
+writes orders straight from Postgres, same as GET /api/v1/orders/export:
+  --format csv|ndjson        (default csv)
+  --layout orders|items      csv only: a row per order or a row per item
+  --columns a,b,...          e.g. order_uid,delivery.city,payment.amount,items.price
+  --customer-id, --delivery-service, --locale   filters, comma-separated
+  --from, --to               date_created range, RFC 3339, --to is exclusive
+  --out -                    write to stdout
+
+database settings come from the service config: --config, --env-file, --db.host=...
+`
+
+// runExportCommand выгружает заказы в файл, читая БД напрямую.
+func runExportCommand(args []string) int {
+	flags := config.NewFlagSet("wb-service export")
+	out := flags.String("out", "", "output file, - for stdout")
+	format := flags.String("format", export.FormatCSV, "csv | ndjson")
+	layout := flags.String("layout", "", "orders | items (csv only)")
+	columns := flags.StringSlice("columns", nil, "columns to export")
+	customers := flags.StringSlice("customer-id", nil, "only these customers")
+	services := flags.StringSlice("delivery-service", nil, "only these delivery services")
+	locales := flags.StringSlice("locale", nil, "only these locales")
+	from := flags.String("from", "", "date_created from, RFC 3339")
+	to := flags.String("to", "", "date_created before, RFC 3339")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			fmt.Fprint(os.Stderr, exportUsage)
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *out == "" {
+		fmt.Fprint(os.Stderr, exportUsage)
+		return 2
+	}
+
+	opts := export.Options{Format: *format, Layout: *layout, Columns: *columns}
+	if _, err := export.NewWriter(io.Discard, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	filter := ports.OrderFilter{CustomerIDs: *customers, DeliveryServices: *services, Locales: *locales}
+	var err error
+	if filter.CreatedFrom, err = parseTimeFlag("--from", *from); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if filter.CreatedTo, err = parseTimeFlag("--to", *to); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, _, err := config.LoadWith(config.OptionsFromFlags(flags))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	pool, err := db.NewPostgresPool(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "postgres pool: %v\n", err)
+		return 1
+	}
+	defer pool.Close()
+
+	n, err := exportToFile(ctx, db.NewRepository(pool), filter, *out, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d orders to %s\n", n, *out)
+	return 0
+}
+
+// exportToFile пишет во временный файл рядом с целевым и переименовывает его
+// только после успешной выгрузки, чтобы не оставлять обрезанный файл.
+func exportToFile(ctx context.Context, src ports.OrderExporter, filter ports.OrderFilter, path string, opts export.Options) (int, error) {
+	if path == "-" {
+		w, err := export.NewWriter(os.Stdout, opts)
+		if err != nil {
+			return 0, err
+		}
+		return export.Run(ctx, src, filter, w)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".export-*")
+	if err != nil {
+		return 0, fmt.Errorf("create output: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	w, err := export.NewWriter(f, opts)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	n, err := export.Run(ctx, src, filter, w)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, fmt.Errorf("export after %d orders: %w", n, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return n, fmt.Errorf("rename output: %w", err)
+	}
+	return n, nil
+}
+
+func parseTimeFlag(name, v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
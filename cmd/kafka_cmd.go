@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"wb-test-task/internal/kafka"
+)
+
+const kafkaUsage = `usage: wb-service kafka <command> [flags]
+
+commands:
+  status    consumer state and per-partition lag
+  pause     stop consuming (offsets stay where they are)
+  resume    continue consuming
+  reset     move group offsets: --to earliest|latest|timestamp|offset
+            [--timestamp 2024-05-01T10:00:00Z] [--offset N] [--topic T [--partition P]] [--dry-run]
+            the consumer must be paused (all replicas of the group)
+
+the commands call the admin API of a running service:
+  --admin-url (default http://localhost:8081), --token (default $ADMIN_TOKEN)
+`
+
+// runKafkaCommand обрабатывает подкоманды `kafka ...` через админский API работающего сервиса.
+func runKafkaCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, kafkaUsage)
+		return 2
+	}
+
+	flags := pflag.NewFlagSet("wb-service kafka "+args[0], pflag.ContinueOnError)
+	adminURL := flags.String("admin-url", "http://localhost:8081", "base URL of the running service")
+	token := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	to := flags.String("to", "", "earliest | latest | timestamp | offset")
+	ts := flags.String("timestamp", "", "RFC 3339 time for --to timestamp")
+	offset := flags.Int64("offset", 0, "offset for --to offset")
+	topic := flags.String("topic", "", "reset only this topic")
+	partition := flags.Int("partition", -1, "reset only this partition of --topic")
+	dryRun := flags.Bool("dry-run", false, "show the plan without committing")
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	base := strings.TrimRight(*adminURL, "/") + "/admin/kafka/consumer"
+	var (
+		method = http.MethodPost
+		path   string
+		body   any
+	)
+	switch args[0] {
+	case "status":
+		method = http.MethodGet
+	case "pause", "resume":
+		path = "/" + args[0]
+	case "reset":
+		spec := kafka.ResetSpec{To: *to, Offset: *offset, Topic: *topic}
+		if *ts != "" {
+			t, err := time.Parse(time.RFC3339, *ts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "--timestamp: %v\n", err)
+				return 2
+			}
+			spec.Timestamp = t
+		}
+		if *partition >= 0 {
+			spec.Partition = partition
+		}
+		if err := spec.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		path = fmt.Sprintf("/offsets/reset?dry_run=%t", *dryRun)
+		body = spec
+	default:
+		fmt.Fprint(os.Stderr, kafkaUsage)
+		return 2
+	}
+
+	if err := callAdmin(method, base+path, *token, body, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// callAdmin выполняет запрос и печатает ответ в out с отступами.
+func callAdmin(method, url, token string, body any, out io.Writer) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, data, "", "  ") == nil {
+		data = pretty.Bytes()
+	}
+	fmt.Fprintln(out, strings.TrimSpace(string(data)))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return nil
+}
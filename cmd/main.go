@@ -5,8 +5,8 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -17,17 +17,45 @@ import (
 	"wb-test-task/internal/bootstrap"
 	wbcache "wb-test-task/internal/cache"
 	"wb-test-task/internal/db"
+	"wb-test-task/internal/feed"
+	"wb-test-task/internal/handlers"
 	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/logging"
 	"wb-test-task/internal/models"
+	"wb-test-task/internal/outbox"
+	"wb-test-task/internal/peers"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/rediscache"
 	"wb-test-task/internal/routes"
 	"wb-test-task/internal/service"
+	"wb-test-task/internal/tuning"
+	"wb-test-task/internal/webhooks"
 )
 
 func main() {
-	cfg, err := config.LoadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "kafka" {
+		os.Exit(runKafkaCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExportCommand(os.Args[2:]))
+	}
+
+	flags := config.NewFlagSet("wb-service")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		log.Fatalf("parse flags: %v", err)
+	}
+	opts := config.OptionsFromFlags(flags)
+	cfg, _, err := config.LoadWith(opts)
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+		log.Fatalf("logging: %v", err)
+	}
+	log.Printf("config:\n%s", cfg.Redacted())
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -40,17 +68,159 @@ func main() {
 
 	repo := db.NewRepository(pool)
 
-	cache := wbcache.NewShardedLRU[*models.Order](16, cfg.CacheCapacity, cfg.CacheTTL)
-	if err := bootstrap.RestoreCacheFromDB(ctx, repo, cache); err != nil {
-		log.Printf("bootstrap cache: %v", err)
+	hub := feed.NewHub(cfg.StreamBufferSize, cfg.StreamClientBuffer, cfg.StreamMaxClients)
+	if !cfg.DBListen {
+		// без LISTEN/NOTIFY лента видит только заказы, сохранённые этим инстансом
+		repo.OnStored = func(s models.OrderSummary) { hub.Publish(s) }
+	}
+
+	policy, err := wbcache.ParsePolicy(cfg.CachePolicy)
+	if err != nil {
+		log.Fatalf("cache policy: %v", err)
+	}
+	var cache ports.Cache[string, *models.Order]
+	if cfg.CacheEncoded {
+		// в кэше лежат готовые JSON-ответы, попадание отдаётся без маршалинга
+		inner, err := wbcache.New[*wbcache.Encoded[*models.Order]](policy, 16, cfg.CacheCapacity, cfg.CacheTTL)
+		if err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+		cache = wbcache.NewEncodedCache(inner, cfg.CacheGzip)
+	} else {
+		cache, err = wbcache.New[*models.Order](policy, 16, cfg.CacheCapacity, cfg.CacheTTL)
+		if err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+	}
+	var redisCache *rediscache.Tiered
+	if cfg.RedisAddr != "" {
+		// Redis как L2 за локальным кэшем, общий для всех реплик
+		client := rediscache.NewClient(rediscache.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		})
+		defer client.Close()
+		redisCache = rediscache.NewTiered(cache, rediscache.New(client, "order:", cfg.CacheTTL), client, cfg.RedisChannel, cfg.InstanceID)
+		cache = redisCache
+	}
+
+	strategy, err := bootstrap.ParseWarmupStrategy(cfg.WarmupStrategy)
+	if err != nil {
+		log.Fatalf("warmup strategy: %v", err)
+	}
+	warmer := bootstrap.NewWarmer(repo, cache, bootstrap.WarmupConfig{
+		Strategy:     strategy,
+		Limit:        cfg.WarmupLimit,
+		Window:       cfg.WarmupWindow,
+		Concurrency:  cfg.WarmupConcurrency,
+		SnapshotPath: cfg.CacheSnapshotPath,
+		HotKeysPath:  cfg.CacheHotKeysPath,
+	})
+
+	var orders ports.OrderRepository = repo
+	if len(cfg.Peers) > 0 {
+		// промахи сначала идут к реплике-владельцу ключа, потом в Postgres
+		orders = peers.NewRepository(repo, cfg.PeerSelf, cfg.Peers, cfg.PeerTimeout)
+	}
+	svc := service.NewOrderService(orders, cache)
+
+	consumerCfg, err := kafka.NewConsumerConfig(cfg)
+	if err != nil {
+		log.Fatalf("kafka: %v", err)
+	}
+	fallback, err := kafka.ParseFallbackPolicy(cfg.KafkaUnknownEventPolicy)
+	if err != nil {
+		log.Fatalf("kafka: %v", err)
+	}
+	dispatcher := &kafka.Dispatcher{
+		Registry: kafka.NewOrderRegistry(cfg, repo, repo, cache),
+		Fallback: fallback,
+	}
+	if cfg.KafkaDLQTopic != "" {
+		dlq := kafka.NewWriter(consumerCfg, cfg.KafkaDLQTopic)
+		defer dlq.Close()
+		dispatcher.DLQ = dlq
+	}
+	if cfg.KafkaParkingTopic != "" {
+		parking := kafka.NewWriter(consumerCfg, cfg.KafkaParkingTopic)
+		defer parking.Close()
+		dispatcher.Parking = parking
+	}
+	var relay *outbox.Relay
+	if cfg.OutboxTopic != "" {
+		// order.stored пишется в outbox вместе с заказом, relay публикует его в Kafka
+		repo.EnableOutbox()
+		writer := kafka.NewWriter(consumerCfg, cfg.OutboxTopic)
+		defer writer.Close()
+		relay = outbox.NewRelay(repo, writer, outbox.Config{
+			Topic:        cfg.OutboxTopic,
+			BatchSize:    cfg.OutboxBatchSize,
+			PollInterval: cfg.OutboxPollInterval,
+			MaxBackoff:   cfg.OutboxMaxBackoff,
+		})
+	}
+
+	var (
+		hooks  ports.Webhooks
+		sender *webhooks.Sender
+	)
+	if cfg.WebhooksEnabled {
+		// доставки ставятся в очередь в транзакции изменения заказа, sender шлёт их подписчикам
+		repo.EnableWebhooks()
+		hooks = repo
+		sender = webhooks.NewSender(repo, webhooks.Config{
+			Timeout:          cfg.WebhookTimeout,
+			MinBackoff:       cfg.WebhookMinBackoff,
+			MaxBackoff:       cfg.WebhookMaxBackoff,
+			MaxAttempts:      cfg.WebhookMaxAttempts,
+			BreakerThreshold: cfg.WebhookBreakerThreshold,
+			BreakerCooldown:  cfg.WebhookBreakerCooldown,
+		})
+	}
+
+	var dedup *kafka.Deduplicator
+	if cfg.KafkaDedupWindow > 0 {
+		// повторы от ретраев продюсера: ключи в памяти и в таблице processed_messages
+		dedup, err = kafka.NewDeduplicator(repo, cfg.KafkaDedupWindow, cfg.KafkaDedupMemorySize, cfg.KafkaDedupKeys)
+		if err != nil {
+			log.Fatalf("dedup: %v", err)
+		}
+		dispatcher.Dedup = dedup
+	}
+	consumer := kafka.NewConsumer(consumerCfg, dispatcher)
+	if cfg.KafkaPaused {
+		consumer.Pause()
 	}
 
-	svc := service.NewOrderService(repo, cache)
+	// часть настроек меняется без перезапуска: через /admin/runtime или правкой конфига
+	tunable, _ := cache.(wbcache.Tunable)
+	tuner := tuning.NewTuner(cfg, tunable, consumer)
+	if cfg.AdminToken == "" {
+		log.Printf("[admin] ADMIN_TOKEN is empty, /admin endpoints are not protected")
+	}
 
 	r := gin.Default()
 	r.Static("/assets", "./internal/assets")
 	r.LoadHTMLGlob("internal/templates/*")
-	r = routes.InitRoutes(r, svc)
+	r = routes.InitRoutes(r, svc, cfg.HTTPCacheControl)
+	r = routes.InitStreamRoutes(r, hub, cfg.StreamHeartbeat, cfg.StreamWriteTimeout)
+	r = routes.InitBatchRoutes(r, svc, cfg.HTTPBatchMaxUIDs)
+	r = routes.InitExportRoutes(r, repo, cfg.ExportWriteTimeout)
+	r = routes.InitHealthRoutes(r, warmer.Ready)
+	var dedupCounters handlers.DedupCounters
+	if dedup != nil {
+		dedupCounters = dedup
+	}
+	r = routes.InitMetricsRoutes(r, dedupCounters)
+	if len(cfg.Peers) > 0 {
+		r = routes.InitPeerRoutes(r, service.NewOrderService(repo, cache))
+	}
+	var circuits handlers.CircuitStates
+	if sender != nil {
+		circuits = sender
+	}
+	r = routes.InitAdminRoutes(r, tuner, consumer, hooks, circuits, cfg.AdminToken)
 
 	srv := &http.Server{
 		Addr:              ":" + cfg.HTTPPort,
@@ -62,17 +232,86 @@ func main() {
 		BaseContext:       func(net.Listener) context.Context { return ctx },
 	}
 
-	brokers := strings.Split(cfg.KafkaBrokers, ",")
-	consumer := kafka.NewConsumer(brokers, cfg.KafkaGroupID, cfg.KafkaTopic, repo, cache)
-
 	var wg sync.WaitGroup
 
+	if redisCache != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			redisCache.Listen(ctx)
+		}()
+	}
+
+	if cfg.DBListen {
+		// изменения заказов с других инстансов приходят через LISTEN/NOTIFY,
+		// новые заказы (включая свои) оттуда же попадают в живую ленту
+		inv := bootstrap.NewCacheInvalidator(repo, cache)
+		onChange := func(ctx context.Context, ch db.OrderChange) {
+			inv.Apply(ctx, ch)
+			if ch.Summary != nil {
+				hub.Publish(*ch.Summary)
+			}
+		}
+		listener := db.NewListener(db.ConnString(cfg), onChange, inv.Resync)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listener.Run(ctx)
+		}()
+	}
+
+	// прогрев идёт в фоне, HTTP-сервер стартует сразу; /readyz ждёт окончания
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		warmer.Run(ctx)
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		consumer.Run(ctx)
 	}()
 
+	if relay != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay.Run(ctx)
+		}()
+	}
+
+	if sender != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sender.Run(ctx)
+		}()
+	}
+
+	if dedup != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dedup.RunPurger(ctx, cfg.KafkaDedupWindow)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		bootstrap.RunCacheSnapshotter(ctx, cfg.CacheSnapshotPath, cfg.CacheSnapshotInterval, cache)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tuning.Watch(ctx, cfg.ReloadInterval, tuner, func() (*config.Config, error) {
+			next, _, err := config.LoadWith(opts)
+			return next, err
+		}, opts.ConfigFile, opts.EnvFile)
+	}()
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -92,5 +331,7 @@ func main() {
 	}
 
 	wg.Wait()
+	bootstrap.SaveCacheSnapshot(cfg.CacheSnapshotPath, cache)
+	bootstrap.SaveHotKeys(cfg.CacheHotKeysPath, cache, cfg.WarmupLimit)
 	log.Println("graceful shutdown complete")
 }
@@ -1,60 +1,762 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+// Config — итоговые настройки сервиса. Каждое поле описано тегами:
+//
+//	key     — путь в YAML-файле и имя CLI-флага (--db.host)
+//	env     — переменная окружения (и ключ в .env)
+//	default — значение по умолчанию
+//	secret  — скрывать значение при выводе; можно передать файлом через
+//	          <ENV>_FILE (или <key>_file в YAML), как принято для Docker/K8s secrets
+//	runtime — применяется без перезапуска (см. internal/tuning)
+//
+// Приоритет источников (от слабого к сильному): default < YAML < .env < окружение < флаги.
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
+	// DBURL — готовый DSN (postgres://... или host=... user=...); если задан,
+	// отдельные DB_HOST/DB_USER/... не нужны
+	DBURL      string `key:"db.url" env:"DB_URL" secret:"true"`
+	DBHost     string `key:"db.host" env:"DB_HOST"`
+	DBPort     string `key:"db.port" env:"DB_PORT" default:"5432"`
+	DBUser     string `key:"db.user" env:"DB_USER"`
+	DBPassword string `key:"db.password" env:"DB_PASSWORD" secret:"true"`
+	DBName     string `key:"db.name" env:"DB_NAME"`
+	DBSSLMode  string `key:"db.sslmode" env:"DB_SSLMODE" default:"disable"`
+	DBListen   bool   `key:"db.listen" env:"DB_LISTEN" default:"true"`
 
-	HTTPPort            string
-	HTTPShutdownTimeout time.Duration
+	DBSSLRootCert string `key:"db.sslrootcert" env:"DB_SSLROOTCERT"`
+	DBSSLCert     string `key:"db.sslcert" env:"DB_SSLCERT"`
+	DBSSLKey      string `key:"db.sslkey" env:"DB_SSLKEY"`
 
-	KafkaBrokers string
-	KafkaTopic   string
-	KafkaGroupID string
+	// нули — значения pgxpool по умолчанию
+	DBPoolMaxConns          int           `key:"db.pool.max_conns" env:"DB_POOL_MAX_CONNS"`
+	DBPoolMinConns          int           `key:"db.pool.min_conns" env:"DB_POOL_MIN_CONNS"`
+	DBPoolHealthCheckPeriod time.Duration `key:"db.pool.health_check_period" env:"DB_POOL_HEALTH_CHECK_PERIOD"`
+	DBPoolMaxConnLifetime   time.Duration `key:"db.pool.max_conn_lifetime" env:"DB_POOL_MAX_CONN_LIFETIME"`
+	DBPoolMaxConnIdleTime   time.Duration `key:"db.pool.max_conn_idle_time" env:"DB_POOL_MAX_CONN_IDLE_TIME"`
+	DBStatementCacheMode    string        `key:"db.statement_cache_mode" env:"DB_STATEMENT_CACHE_MODE"`
 
-	CacheCapacity int
-	CacheTTL      time.Duration
+	HTTPPort            string        `key:"http.port" env:"HTTP_PORT" default:"8081"`
+	HTTPShutdownTimeout time.Duration `key:"http.shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" default:"10s"`
+	// Cache-Control ответов с заказом; по умолчанию кэшировать можно, но только с перепроверкой по ETag
+	HTTPCacheControl string `key:"http.cache_control" env:"HTTP_CACHE_CONTROL" default:"no-cache"`
+	// предел UID в одном POST /api/v1/orders:batchGet
+	HTTPBatchMaxUIDs int `key:"http.batch_max_uids" env:"HTTP_BATCH_MAX_UIDS" default:"500"`
+
+	KafkaBrokers string `key:"kafka.brokers" env:"KAFKA_BROKERS"`
+	KafkaTopic   string `key:"kafka.topic" env:"KAFKA_TOPIC"`
+	KafkaGroupID string `key:"kafka.group_id" env:"KAFKA_GROUP_ID" default:"order-service"`
+	KafkaPaused  bool   `key:"kafka.paused" env:"KAFKA_PAUSED" runtime:"true"`
+
+	// KafkaTopics — дополнительные топики с событиями: "topic" (тип из заголовка
+	// event-type) или "topic=event.type" (тип для сообщений без заголовка)
+	KafkaTopics             []string `key:"kafka.topics" env:"KAFKA_TOPICS"`
+	KafkaUnknownEventPolicy string   `key:"kafka.unknown_event_policy" env:"KAFKA_UNKNOWN_EVENT_POLICY" default:"skip"`
+	KafkaDLQTopic           string   `key:"kafka.dlq_topic" env:"KAFKA_DLQ_TOPIC"`
+	// события новее поддерживаемой версии схемы; пусто — консьюмер встаёт на паузу
+	KafkaParkingTopic string `key:"kafka.parking_topic" env:"KAFKA_PARKING_TOPIC"`
+
+	// отсев повторов: ключ берётся из первого доступного источника, 0 — выключено
+	KafkaDedupWindow     time.Duration `key:"kafka.dedup.window" env:"KAFKA_DEDUP_WINDOW" default:"1h"`
+	KafkaDedupKeys       []string      `key:"kafka.dedup.keys" env:"KAFKA_DEDUP_KEYS" default:"key,header,hash"`
+	KafkaDedupMemorySize int           `key:"kafka.dedup.memory_size" env:"KAFKA_DEDUP_MEMORY_SIZE" default:"100000"`
+
+	// TLS включается флагом или любым из путей к сертификатам
+	KafkaTLS     bool   `key:"kafka.tls.enabled" env:"KAFKA_TLS"`
+	KafkaTLSCA   string `key:"kafka.tls.ca" env:"KAFKA_TLS_CA"`
+	KafkaTLSCert string `key:"kafka.tls.cert" env:"KAFKA_TLS_CERT"`
+	KafkaTLSKey  string `key:"kafka.tls.key" env:"KAFKA_TLS_KEY"`
+	// только для dev-стендов с самоподписанными сертификатами
+	KafkaTLSInsecure bool `key:"kafka.tls.insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+
+	KafkaSASLMechanism string `key:"kafka.sasl.mechanism" env:"KAFKA_SASL_MECHANISM"`
+	KafkaSASLUsername  string `key:"kafka.sasl.username" env:"KAFKA_SASL_USERNAME"`
+	KafkaSASLPassword  string `key:"kafka.sasl.password" env:"KAFKA_SASL_PASSWORD" secret:"true"`
+
+	// StartOffset действует, только пока у группы нет закоммиченных смещений
+	KafkaStartOffset       string        `key:"kafka.start_offset" env:"KAFKA_START_OFFSET" default:"earliest"`
+	KafkaIsolationLevel    string        `key:"kafka.isolation_level" env:"KAFKA_ISOLATION_LEVEL" default:"read_uncommitted"`
+	KafkaSessionTimeout    time.Duration `key:"kafka.session_timeout" env:"KAFKA_SESSION_TIMEOUT" default:"30s"`
+	KafkaRebalanceStrategy []string      `key:"kafka.rebalance_strategy" env:"KAFKA_REBALANCE_STRATEGY" default:"range"`
+
+	// реестр схем для Avro/Protobuf в Confluent wire format; пусто — только JSON и protobuf по заголовку
+	SchemaRegistryURL      string        `key:"schema_registry.url" env:"SCHEMA_REGISTRY_URL"`
+	SchemaRegistryUsername string        `key:"schema_registry.username" env:"SCHEMA_REGISTRY_USERNAME"`
+	SchemaRegistryPassword string        `key:"schema_registry.password" env:"SCHEMA_REGISTRY_PASSWORD" secret:"true"`
+	SchemaRegistryTimeout  time.Duration `key:"schema_registry.timeout" env:"SCHEMA_REGISTRY_TIMEOUT" default:"5s"`
+
+	// outbox: событие order.stored в той же транзакции, что и заказ; пустой топик — выключено
+	OutboxTopic        string        `key:"outbox.topic" env:"OUTBOX_TOPIC"`
+	OutboxBatchSize    int           `key:"outbox.batch_size" env:"OUTBOX_BATCH_SIZE" default:"100"`
+	OutboxPollInterval time.Duration `key:"outbox.poll_interval" env:"OUTBOX_POLL_INTERVAL" default:"1s"`
+	OutboxMaxBackoff   time.Duration `key:"outbox.max_backoff" env:"OUTBOX_MAX_BACKOFF" default:"5m"`
+
+	// исходящие вебхуки о новых и изменённых заказах; подписки — через /admin/webhooks
+	WebhooksEnabled         bool          `key:"webhooks.enabled" env:"WEBHOOKS_ENABLED"`
+	WebhookTimeout          time.Duration `key:"webhooks.timeout" env:"WEBHOOK_TIMEOUT" default:"10s"`
+	WebhookMaxAttempts      int           `key:"webhooks.max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"10"`
+	WebhookMinBackoff       time.Duration `key:"webhooks.min_backoff" env:"WEBHOOK_MIN_BACKOFF" default:"10s"`
+	WebhookMaxBackoff       time.Duration `key:"webhooks.max_backoff" env:"WEBHOOK_MAX_BACKOFF" default:"1h"`
+	WebhookBreakerThreshold int           `key:"webhooks.breaker_threshold" env:"WEBHOOK_BREAKER_THRESHOLD" default:"5"`
+	WebhookBreakerCooldown  time.Duration `key:"webhooks.breaker_cooldown" env:"WEBHOOK_BREAKER_COOLDOWN" default:"1m"`
+
+	// живая лента новых заказов (SSE, /api/v1/orders/stream)
+	StreamBufferSize   int           `key:"stream.buffer_size" env:"STREAM_BUFFER_SIZE" default:"1000"`
+	StreamClientBuffer int           `key:"stream.client_buffer" env:"STREAM_CLIENT_BUFFER" default:"64"`
+	StreamMaxClients   int           `key:"stream.max_clients" env:"STREAM_MAX_CLIENTS" default:"100"`
+	StreamHeartbeat    time.Duration `key:"stream.heartbeat" env:"STREAM_HEARTBEAT" default:"15s"`
+	StreamWriteTimeout time.Duration `key:"stream.write_timeout" env:"STREAM_WRITE_TIMEOUT" default:"10s"`
+
+	// срок на каждую запись выгрузки вместо общего WriteTimeout сервера
+	ExportWriteTimeout time.Duration `key:"export.write_timeout" env:"EXPORT_WRITE_TIMEOUT" default:"30s"`
+
+	CacheCapacity int           `key:"cache.capacity" env:"CACHE_CAPACITY" default:"1000" runtime:"true"`
+	CacheTTL      time.Duration `key:"cache.ttl" env:"CACHE_TTL" default:"5m" runtime:"true"`
+	CachePolicy   string        `key:"cache.policy" env:"CACHE_POLICY" default:"lru"`
+	CacheEncoded  bool          `key:"cache.encoded" env:"CACHE_ENCODED"`
+	CacheGzip     bool          `key:"cache.gzip" env:"CACHE_GZIP"`
+
+	CacheSnapshotPath     string        `key:"cache.snapshot_path" env:"CACHE_SNAPSHOT_PATH"`
+	CacheSnapshotInterval time.Duration `key:"cache.snapshot_interval" env:"CACHE_SNAPSHOT_INTERVAL" default:"1m"`
+	CacheHotKeysPath      string        `key:"cache.hotkeys_path" env:"CACHE_HOTKEYS_PATH"`
+
+	RedisAddr     string `key:"redis.addr" env:"REDIS_ADDR"`
+	RedisPassword string `key:"redis.password" env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int    `key:"redis.db" env:"REDIS_DB"`
+	RedisChannel  string `key:"redis.channel" env:"REDIS_CHANNEL" default:"orders:invalidate"`
+	InstanceID    string `key:"instance_id" env:"INSTANCE_ID"`
+
+	Peers       []string      `key:"peers.list" env:"PEERS"`
+	PeerSelf    string        `key:"peers.self" env:"PEER_SELF"`
+	PeerTimeout time.Duration `key:"peers.timeout" env:"PEER_TIMEOUT" default:"500ms"`
+
+	WarmupStrategy    string        `key:"warmup.strategy" env:"WARMUP_STRATEGY" default:"all"`
+	WarmupLimit       int           `key:"warmup.limit" env:"WARMUP_LIMIT"`
+	WarmupWindow      time.Duration `key:"warmup.window" env:"WARMUP_WINDOW" default:"24h"`
+	WarmupConcurrency int           `key:"warmup.concurrency" env:"WARMUP_CONCURRENCY" default:"4"`
+
+	LogLevel  string `key:"logging.level" env:"LOG_LEVEL" default:"info" runtime:"true"`
+	LogFormat string `key:"logging.format" env:"LOG_FORMAT" default:"text"`
+
+	AdminToken     string        `key:"admin.token" env:"ADMIN_TOKEN" secret:"true"`
+	ReloadInterval time.Duration `key:"reload.interval" env:"CONFIG_RELOAD_INTERVAL" default:"10s"`
+}
+
+// Sources — откуда взято каждое значение: ключ → "env DB_HOST", "file config.yaml", ...
+type Sources map[string]string
+
+// ValidationError собирает все проблемы конфига, чтобы не чинить их по одной.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// legacyKey — старое имя переменной; unit != 0 — старое значение было целым числом в этих единицах.
+type legacyKey struct {
+	env  string
+	unit time.Duration
+}
+
+// legacyKeys — старые имена переменных, которые ещё встречаются в окружениях.
+var legacyKeys = map[string]legacyKey{
+	"HTTP_SHUTDOWN_TIMEOUT": {"HTTP_SHUTDOWNTIMEOUT_SEC", time.Second},
+	"CACHE_TTL":             {"CACHE_TTL_MIN", time.Minute},
+	"HTTP_PORT":             {"WEB_PORT", 0},
+}
+
+// DefaultConfigFile читается, если он есть; явно указанный файл обязан существовать.
+const DefaultConfigFile = "config.yaml"
+
+type Options struct {
+	ConfigFile         string
+	ConfigFileRequired bool
+	EnvFile            string
+	Flags              *pflag.FlagSet // результат NewFlagSet после Parse; может быть nil
+}
+
+// NewFlagSet регистрирует --config, --env-file и флаг на каждый ключ конфига.
+func NewFlagSet(name string) *pflag.FlagSet {
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.String("config", DefaultConfigFile, "path to YAML config file (env CONFIG_FILE)")
+	fs.String("env-file", ".env", "path to optional dotenv file")
+	forEachField(func(f reflect.StructField, _ reflect.Value) {
+		fs.String(f.Tag.Get("key"), "", "overrides "+f.Tag.Get("env"))
+	})
+	return fs
+}
+
+// OptionsFromFlags достаёт пути к файлам из разобранных флагов (или CONFIG_FILE).
+func OptionsFromFlags(fs *pflag.FlagSet) Options {
+	opts := Options{ConfigFile: DefaultConfigFile, EnvFile: ".env", Flags: fs}
+	if env := os.Getenv("CONFIG_FILE"); env != "" {
+		opts.ConfigFile, opts.ConfigFileRequired = env, true
+	}
+	if f := fs.Lookup("config"); f != nil && f.Changed {
+		opts.ConfigFile, opts.ConfigFileRequired = f.Value.String(), true
+	}
+	if f := fs.Lookup("env-file"); f != nil {
+		opts.EnvFile = f.Value.String()
+	}
+	return opts
 }
 
 func LoadConfig() (*Config, error) {
-	viper.SetConfigFile(".env")
-	viper.AutomaticEnv()
+	return Load(".env")
+}
+
+// Load читает только необязательный dotenv-файл и окружение.
+func Load(envFile string) (*Config, error) {
+	cfg, _, err := LoadWith(Options{EnvFile: envFile})
+	return cfg, err
+}
+
+// LoadWith собирает конфиг из всех слоёв, проверяет значения и возвращает
+// все ошибки разом вместе с источником каждого значения.
+func LoadWith(opts Options) (*Config, Sources, error) {
+	l := &loader{flags: opts.Flags, sources: Sources{}}
+
+	if opts.ConfigFile != "" {
+		l.file = viper.New()
+		l.file.SetConfigFile(opts.ConfigFile)
+		l.file.SetConfigType("yaml")
+		l.fileName = opts.ConfigFile
+		if err := l.file.ReadInConfig(); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) || opts.ConfigFileRequired {
+				return nil, nil, fmt.Errorf("read %s: %w", opts.ConfigFile, err)
+			}
+			l.file = nil
+		}
+	}
+	if opts.EnvFile != "" {
+		l.dotenv = viper.New()
+		l.dotenv.SetConfigFile(opts.EnvFile)
+		l.dotenv.SetConfigType("env")
+		l.dotenvName = opts.EnvFile
+		if err := l.dotenv.ReadInConfig(); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, nil, fmt.Errorf("read %s: %w", opts.EnvFile, err)
+			}
+			l.dotenv = nil
+		}
+	}
+
+	cfg := &Config{}
+	rv := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		l.set(rv.Type().Field(i), rv.Field(i))
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = defaultInstanceID()
+		l.sources["instance_id"] = "default (hostname-pid)"
+	}
+
+	l.errs = append(l.errs, cfg.validate()...)
+	if len(l.errs) > 0 {
+		// конфиг возвращаем и при ошибках — его показывает `config print`
+		return cfg, l.sources, &ValidationError{Problems: l.errs}
+	}
+	return cfg, l.sources, nil
+}
+
+func (c *Config) validate() []string {
+	var errs []string
+	required := func(env, val string) {
+		if strings.TrimSpace(val) == "" {
+			errs = append(errs, name(env)+" is required")
+		}
+	}
+	if c.DBURL == "" {
+		required("DB_HOST", c.DBHost)
+		required("DB_USER", c.DBUser)
+		required("DB_NAME", c.DBName)
+	}
+	required("KAFKA_BROKERS", c.KafkaBrokers)
+	required("KAFKA_TOPIC", c.KafkaTopic)
+	required("KAFKA_GROUP_ID", c.KafkaGroupID)
+
+	if !isPort(c.DBPort) {
+		errs = append(errs, fmt.Sprintf("%s: %q is not a valid port", name("DB_PORT"), c.DBPort))
+	}
+	if (c.DBSSLCert == "") != (c.DBSSLKey == "") {
+		errs = append(errs, name("DB_SSLCERT")+" and "+name("DB_SSLKEY")+" must be set together")
+	}
+	if c.DBPoolMaxConns < 0 || c.DBPoolMinConns < 0 {
+		errs = append(errs, name("DB_POOL_MAX_CONNS")+" and "+name("DB_POOL_MIN_CONNS")+" must not be negative")
+	}
+	if c.DBPoolMaxConns > 0 && c.DBPoolMinConns > c.DBPoolMaxConns {
+		errs = append(errs, name("DB_POOL_MIN_CONNS")+" must not exceed "+name("DB_POOL_MAX_CONNS"))
+	}
+	if c.DBStatementCacheMode != "" && !oneOf(c.DBStatementCacheMode, "cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol") {
+		errs = append(errs, fmt.Sprintf("%s: unknown mode %q", name("DB_STATEMENT_CACHE_MODE"), c.DBStatementCacheMode))
+	}
+	if !isPort(c.HTTPPort) {
+		errs = append(errs, fmt.Sprintf("%s: %q is not a valid port", name("HTTP_PORT"), c.HTTPPort))
+	}
+	if c.HTTPShutdownTimeout <= 0 {
+		errs = append(errs, name("HTTP_SHUTDOWN_TIMEOUT")+" must be positive")
+	}
+	for _, b := range splitList(c.KafkaBrokers) {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %q is not host:port", name("KAFKA_BROKERS"), b))
+		}
+	}
+	if (c.KafkaTLSCert == "") != (c.KafkaTLSKey == "") {
+		errs = append(errs, name("KAFKA_TLS_CERT")+" and "+name("KAFKA_TLS_KEY")+" must be set together")
+	}
+	if !oneOf(c.KafkaUnknownEventPolicy, "skip", "dlq", "block") {
+		errs = append(errs, fmt.Sprintf("%s: unknown policy %q", name("KAFKA_UNKNOWN_EVENT_POLICY"), c.KafkaUnknownEventPolicy))
+	}
+	if c.KafkaUnknownEventPolicy == "dlq" && c.KafkaDLQTopic == "" {
+		errs = append(errs, name("KAFKA_DLQ_TOPIC")+" is required for the dlq policy")
+	}
+	for _, t := range c.KafkaTopics {
+		if topic, eventType, ok := strings.Cut(t, "="); topic == "" || (ok && eventType == "") {
+			errs = append(errs, fmt.Sprintf("%s: %q is not topic or topic=event.type", name("KAFKA_TOPICS"), t))
+		}
+	}
+	if c.KafkaSASLMechanism != "" {
+		if !oneOf(c.KafkaSASLMechanism, "plain", "scram-sha-256", "scram-sha-512") {
+			errs = append(errs, fmt.Sprintf("%s: unknown mechanism %q", name("KAFKA_SASL_MECHANISM"), c.KafkaSASLMechanism))
+		}
+		required("KAFKA_SASL_USERNAME", c.KafkaSASLUsername)
+		required("KAFKA_SASL_PASSWORD", c.KafkaSASLPassword)
+	}
+	if !oneOf(c.KafkaStartOffset, "earliest", "latest") {
+		errs = append(errs, fmt.Sprintf("%s: %q is not earliest or latest", name("KAFKA_START_OFFSET"), c.KafkaStartOffset))
+	}
+	if !oneOf(c.KafkaIsolationLevel, "read_uncommitted", "read_committed") {
+		errs = append(errs, fmt.Sprintf("%s: unknown level %q", name("KAFKA_ISOLATION_LEVEL"), c.KafkaIsolationLevel))
+	}
+	if c.KafkaSessionTimeout <= 0 {
+		errs = append(errs, name("KAFKA_SESSION_TIMEOUT")+" must be positive")
+	}
+	for _, st := range c.KafkaRebalanceStrategy {
+		if !oneOf(st, "range", "roundrobin") {
+			errs = append(errs, fmt.Sprintf("%s: unknown strategy %q", name("KAFKA_REBALANCE_STRATEGY"), st))
+		}
+	}
+	if c.KafkaDedupWindow < 0 {
+		errs = append(errs, name("KAFKA_DEDUP_WINDOW")+" must not be negative")
+	}
+	if c.KafkaDedupWindow > 0 {
+		if len(c.KafkaDedupKeys) == 0 {
+			errs = append(errs, name("KAFKA_DEDUP_KEYS")+" is required when "+name("KAFKA_DEDUP_WINDOW")+" is set")
+		}
+		for _, k := range c.KafkaDedupKeys {
+			if !oneOf(k, "header", "event_id", "key", "hash") {
+				errs = append(errs, fmt.Sprintf("%s: unknown key source %q", name("KAFKA_DEDUP_KEYS"), k))
+			}
+		}
+		if c.KafkaDedupMemorySize <= 0 {
+			errs = append(errs, name("KAFKA_DEDUP_MEMORY_SIZE")+" must be positive")
+		}
+	}
+	if c.OutboxTopic != "" {
+		if c.OutboxBatchSize <= 0 {
+			errs = append(errs, name("OUTBOX_BATCH_SIZE")+" must be positive")
+		}
+		if c.OutboxPollInterval <= 0 {
+			errs = append(errs, name("OUTBOX_POLL_INTERVAL")+" must be positive")
+		}
+		if c.OutboxMaxBackoff <= 0 {
+			errs = append(errs, name("OUTBOX_MAX_BACKOFF")+" must be positive")
+		}
+	}
+	if c.WebhooksEnabled {
+		if c.WebhookTimeout <= 0 {
+			errs = append(errs, name("WEBHOOK_TIMEOUT")+" must be positive")
+		}
+		if c.WebhookMaxAttempts <= 0 {
+			errs = append(errs, name("WEBHOOK_MAX_ATTEMPTS")+" must be positive")
+		}
+		if c.WebhookMinBackoff <= 0 {
+			errs = append(errs, name("WEBHOOK_MIN_BACKOFF")+" must be positive")
+		}
+		if c.WebhookMaxBackoff < c.WebhookMinBackoff {
+			errs = append(errs, name("WEBHOOK_MAX_BACKOFF")+" must not be less than "+name("WEBHOOK_MIN_BACKOFF"))
+		}
+		if c.WebhookBreakerThreshold < 0 {
+			errs = append(errs, name("WEBHOOK_BREAKER_THRESHOLD")+" must not be negative")
+		}
+		if c.WebhookBreakerCooldown <= 0 {
+			errs = append(errs, name("WEBHOOK_BREAKER_COOLDOWN")+" must be positive")
+		}
+	}
+	if c.HTTPBatchMaxUIDs <= 0 {
+		errs = append(errs, name("HTTP_BATCH_MAX_UIDS")+" must be positive")
+	}
+	if c.StreamBufferSize <= 0 {
+		errs = append(errs, name("STREAM_BUFFER_SIZE")+" must be positive")
+	}
+	if c.StreamClientBuffer <= 0 {
+		errs = append(errs, name("STREAM_CLIENT_BUFFER")+" must be positive")
+	}
+	if c.StreamMaxClients < 0 {
+		errs = append(errs, name("STREAM_MAX_CLIENTS")+" must not be negative")
+	}
+	if c.StreamHeartbeat <= 0 {
+		errs = append(errs, name("STREAM_HEARTBEAT")+" must be positive")
+	}
+	if c.StreamWriteTimeout <= 0 {
+		errs = append(errs, name("STREAM_WRITE_TIMEOUT")+" must be positive")
+	}
+	if c.ExportWriteTimeout <= 0 {
+		errs = append(errs, name("EXPORT_WRITE_TIMEOUT")+" must be positive")
+	}
+	if c.SchemaRegistryURL != "" {
+		if u, err := url.Parse(c.SchemaRegistryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("%s: %q is not an http(s) URL", name("SCHEMA_REGISTRY_URL"), c.SchemaRegistryURL))
+		}
+		if c.SchemaRegistryTimeout <= 0 {
+			errs = append(errs, name("SCHEMA_REGISTRY_TIMEOUT")+" must be positive")
+		}
+	}
 
-	// cache default
-	viper.SetDefault("CACHE_CAPACITY", 1000)
-	viper.SetDefault("CACHE_TTL", "5m")
+	if c.CacheCapacity <= 0 {
+		errs = append(errs, name("CACHE_CAPACITY")+" must be positive")
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, name("CACHE_TTL")+" must be positive")
+	}
+	if !oneOf(c.CachePolicy, "lru", "lfu", "2q", "tinylfu") {
+		errs = append(errs, fmt.Sprintf("%s: unknown policy %q", name("CACHE_POLICY"), c.CachePolicy))
+	}
+	if c.CacheSnapshotPath != "" && c.CacheSnapshotInterval < 0 {
+		errs = append(errs, name("CACHE_SNAPSHOT_INTERVAL")+" must not be negative")
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Ошибка чтения .env файла: %v", err)
-		return nil, err
+	if len(c.Peers) > 0 && c.PeerSelf == "" {
+		errs = append(errs, name("PEER_SELF")+" is required when "+name("PEERS")+" is set")
 	}
 
-	return &Config{
-		DBHost:     viper.GetString("DB_HOST"),
-		DBPort:     viper.GetString("DB_PORT"),
-		DBUser:     viper.GetString("DB_USER"),
-		DBPassword: viper.GetString("DB_PASSWORD"),
-		DBName:     viper.GetString("DB_NAME"),
-		DBSSLMode:  viper.GetString("DB_SSLMODE"),
+	if !oneOf(c.WarmupStrategy, "none", "all", "recent", "window", "hot") {
+		errs = append(errs, fmt.Sprintf("%s: unknown strategy %q", name("WARMUP_STRATEGY"), c.WarmupStrategy))
+	}
+	if c.WarmupStrategy == "window" && c.WarmupWindow <= 0 {
+		errs = append(errs, name("WARMUP_WINDOW")+" must be positive for the window strategy")
+	}
+	if c.WarmupStrategy == "hot" && c.CacheHotKeysPath == "" {
+		errs = append(errs, name("CACHE_HOTKEYS_PATH")+" is required for the hot strategy")
+	}
+	if c.WarmupConcurrency <= 0 {
+		errs = append(errs, name("WARMUP_CONCURRENCY")+" must be positive")
+	}
 
-		KafkaBrokers: viper.GetString("KAFKA_BROKERS"),
-		KafkaTopic:   viper.GetString("KAFKA_TOPIC"),
-		KafkaGroupID: viper.GetString("KAFKA_GROUP_ID"),
+	if !oneOf(c.LogLevel, "debug", "info", "warn", "error") {
+		errs = append(errs, fmt.Sprintf("%s: unknown level %q", name("LOG_LEVEL"), c.LogLevel))
+	}
+	if !oneOf(c.LogFormat, "text", "json") {
+		errs = append(errs, fmt.Sprintf("%s: unknown format %q", name("LOG_FORMAT"), c.LogFormat))
+	}
+	if c.ReloadInterval < 0 {
+		errs = append(errs, name("CONFIG_RELOAD_INTERVAL")+" must not be negative")
+	}
+	return errs
+}
 
-		HTTPPort:            viper.GetString("HTTP_PORT"),
-		HTTPShutdownTimeout: time.Duration(viper.GetInt("HTTP_SHUTDOWNTIMEOUT_SEC")) * time.Second,
+// With возвращает копию конфига с изменёнными ключами (значения — как в YAML
+// или флагах) и проверяет результат целиком.
+func (c *Config) With(changes map[string]string) (*Config, error) {
+	next := *c
+	l := &loader{sources: Sources{}}
+	fields := map[string]bool{}
+	forEachFieldOf(&next, func(f reflect.StructField, v reflect.Value) {
+		key := f.Tag.Get("key")
+		raw, ok := changes[key]
+		if !ok {
+			return
+		}
+		fields[key] = true
+		l.assign(f, v, raw, "request", 0)
+	})
+	for key := range changes {
+		if !fields[key] {
+			l.errs = append(l.errs, fmt.Sprintf("unknown setting %q", key))
+		}
+	}
+	if len(l.errs) == 0 {
+		l.errs = next.validate()
+	}
+	if len(l.errs) > 0 {
+		return nil, &ValidationError{Problems: l.errs}
+	}
+	return &next, nil
+}
+
+// Diff возвращает ключи, значения которых в a и b различаются.
+func Diff(a, b *Config) []string {
+	var keys []string
+	bv := reflect.ValueOf(b).Elem()
+	forEachFieldOf(a, func(f reflect.StructField, v reflect.Value) {
+		if !reflect.DeepEqual(v.Interface(), bv.FieldByIndex(f.Index).Interface()) {
+			keys = append(keys, f.Tag.Get("key"))
+		}
+	})
+	return keys
+}
+
+// Runtime сообщает, можно ли менять ключ без перезапуска.
+func Runtime(key string) bool {
+	return runtimeKeys[key]
+}
+
+// Value возвращает значение ключа в том виде, в каком оно печатается в config print.
+func (c *Config) Value(key string) string {
+	var out string
+	forEachFieldOf(c, func(f reflect.StructField, v reflect.Value) {
+		if f.Tag.Get("key") == key {
+			out = display(f, v)
+		}
+	})
+	return out
+}
+
+// Redacted возвращает конфиг в виде KEY=value по строке, секреты скрыты.
+func (c *Config) Redacted() string {
+	var b strings.Builder
+	forEachFieldOf(c, func(f reflect.StructField, v reflect.Value) {
+		fmt.Fprintf(&b, "%s=%s\n", f.Tag.Get("env"), display(f, v))
+	})
+	return b.String()
+}
+
+// Describe печатает таблицу «ключ, значение, источник» для команды config print.
+func (c *Config) Describe(src Sources) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	forEachFieldOf(c, func(f reflect.StructField, v reflect.Value) {
+		key := f.Tag.Get("key")
+		s := src[key]
+		if s == "" {
+			s = "unset"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", key, display(f, v), s)
+	})
+	tw.Flush()
+	return b.String()
+}
+
+func display(f reflect.StructField, v reflect.Value) string {
+	val := fmt.Sprint(v.Interface())
+	if list, ok := v.Interface().([]string); ok {
+		val = strings.Join(list, ",")
+	}
+	if f.Tag.Get("secret") == "true" && val != "" {
+		val = "******"
+	}
+	return val
+}
+
+// loader ищет значение каждого ключа по слоям, разбирает его по типу поля
+// и копит ошибки разбора.
+type loader struct {
+	flags      *pflag.FlagSet
+	file       *viper.Viper
+	fileName   string
+	dotenv     *viper.Viper
+	dotenvName string
+
+	sources Sources
+	errs    []string
+}
 
-		CacheCapacity: viper.GetInt("CACHE_CAPACITY"),
-		CacheTTL:      time.Duration(viper.GetInt("CACHE_TTL_MIN")) * time.Minute,
-	}, nil
+func (l *loader) set(f reflect.StructField, v reflect.Value) {
+	key, env := f.Tag.Get("key"), f.Tag.Get("env")
+	raw, src, unit := l.lookup(key, env, f.Tag.Get("default"), f.Tag.Get("secret") == "true")
+	if src != "" {
+		l.sources[key] = src
+	}
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	l.assign(f, v, raw, src, unit)
+}
+
+// assign разбирает сырое значение по типу поля; ошибки копятся в l.errs.
+func (l *loader) assign(f reflect.StructField, v reflect.Value, raw, src string, unit time.Duration) {
+	env := f.Tag.Get("env")
+	raw = strings.TrimSpace(raw)
+	bad := func(what string) {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %q is not %s (from %s)", name(env), raw, what, src))
+	}
+	switch {
+	case f.Type == reflect.TypeOf(time.Duration(0)):
+		if unit != 0 {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				bad("an integer")
+			}
+			v.SetInt(int64(time.Duration(n) * unit))
+			return
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			bad("a duration (e.g. 30s, 5m, 2h)")
+		}
+		v.SetInt(int64(d))
+	case f.Type.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			bad("an integer")
+		}
+		v.SetInt(int64(n))
+	case f.Type.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			bad("a boolean")
+		}
+		v.SetBool(b)
+	case f.Type.Kind() == reflect.Slice:
+		v.Set(reflect.ValueOf(splitList(raw)))
+	default:
+		v.SetString(raw)
+	}
+}
+
+// lookup возвращает сырое значение, его источник и, для устаревших ключей, единицу измерения.
+// Для секретов в каждом слое после самого ключа проверяется вариант с суффиксом _FILE.
+func (l *loader) lookup(key, env, def string, secret bool) (string, string, time.Duration) {
+	if l.flags != nil {
+		if f := l.flags.Lookup(key); f != nil && f.Changed {
+			return f.Value.String(), "flag --" + key, 0
+		}
+	}
+	legacy, hasLegacy := legacyKeys[env]
+	if val, ok := os.LookupEnv(env); ok {
+		return val, "env " + env, 0
+	}
+	if path, ok := os.LookupEnv(env + "_FILE"); ok && secret {
+		return l.readSecret(env, path, "env "+env+"_FILE")
+	}
+	if hasLegacy {
+		if val, ok := os.LookupEnv(legacy.env); ok {
+			log.Printf("config: %s is deprecated, use %s", legacy.env, env)
+			return val, "env " + legacy.env + " (deprecated)", legacy.unit
+		}
+	}
+	if l.dotenv != nil {
+		if l.dotenv.InConfig(strings.ToLower(env)) {
+			return l.dotenv.GetString(env), l.dotenvName, 0
+		}
+		if secret && l.dotenv.InConfig(strings.ToLower(env+"_FILE")) {
+			return l.readSecret(env, l.dotenv.GetString(env+"_FILE"), l.dotenvName+" "+env+"_FILE")
+		}
+		if hasLegacy && l.dotenv.InConfig(strings.ToLower(legacy.env)) {
+			log.Printf("config: %s is deprecated, use %s", legacy.env, env)
+			return l.dotenv.GetString(legacy.env), l.dotenvName + " " + legacy.env + " (deprecated)", legacy.unit
+		}
+	}
+	if l.file != nil && l.file.IsSet(key) {
+		val := l.file.Get(key)
+		if list, ok := val.([]any); ok {
+			parts := make([]string, len(list))
+			for i, p := range list {
+				parts[i] = fmt.Sprint(p)
+			}
+			return strings.Join(parts, ","), "file " + l.fileName, 0
+		}
+		return fmt.Sprint(val), "file " + l.fileName, 0
+	}
+	if l.file != nil && secret && l.file.IsSet(key+"_file") {
+		return l.readSecret(env, l.file.GetString(key+"_file"), "file "+l.fileName+" "+key+"_file")
+	}
+	if def != "" {
+		return def, "default", 0
+	}
+	return "", "", 0
+}
+
+// readSecret читает значение из файла; завершающий перевод строки отбрасывается.
+func (l *loader) readSecret(env, path, via string) (string, string, time.Duration) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("%s: read secret file (from %s): %v", name(env), via, err))
+		return "", "", 0
+	}
+	return strings.TrimRight(string(data), "\r\n"), "secret file " + path + " (" + via + ")", 0
+}
+
+var runtimeKeys = func() map[string]bool {
+	m := map[string]bool{}
+	forEachField(func(f reflect.StructField, _ reflect.Value) {
+		if f.Tag.Get("runtime") == "true" {
+			m[f.Tag.Get("key")] = true
+		}
+	})
+	return m
+}()
+
+var envToKey = func() map[string]string {
+	m := map[string]string{}
+	forEachField(func(f reflect.StructField, _ reflect.Value) {
+		m[f.Tag.Get("env")] = f.Tag.Get("key")
+	})
+	return m
+}()
+
+// name даёт человекочитаемое имя настройки для сообщений об ошибках.
+func name(env string) string {
+	return envToKey[env] + "/" + env
+}
+
+func forEachField(fn func(f reflect.StructField, v reflect.Value)) {
+	forEachFieldOf(&Config{}, fn)
+}
+
+func forEachFieldOf(c *Config, fn func(f reflect.StructField, v reflect.Value)) {
+	rv := reflect.ValueOf(c).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		fn(rv.Type().Field(i), rv.Field(i))
+	}
+}
+
+// defaultInstanceID отличает реплики друг от друга (имя хоста и PID).
+func defaultInstanceID() string {
+	host, _ := os.Hostname()
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+func isPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n < 65536
+}
+
+func oneOf(s string, options ...string) bool {
+	s = strings.ToLower(s)
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
 }
@@ -0,0 +1,62 @@
+-- Заказы и их части (см. ERD-схему в README). Миграции применяются по порядку
+-- номеров; в docker-compose каталог смонтирован в /docker-entrypoint-initdb.d и
+-- срабатывает только на пустом томе — в существующую БД новые файлы
+-- накатываются вручную: psql -f deployments/migrations/000N_....sql.
+CREATE TABLE orders (
+    order_uid          TEXT PRIMARY KEY,
+    track_number       TEXT NOT NULL,
+    entry              TEXT NOT NULL,
+    locale             TEXT NOT NULL,
+    internal_signature TEXT NOT NULL,
+    customer_id        TEXT NOT NULL,
+    delivery_service   TEXT NOT NULL,
+    shardkey           TEXT NOT NULL,
+    sm_id              INT NOT NULL,
+    date_created       TIMESTAMPTZ NOT NULL,
+    oof_shard          TEXT NOT NULL
+);
+
+CREATE INDEX orders_date_created_idx ON orders (date_created);
+
+CREATE TABLE deliveries (
+    order_uid TEXT PRIMARY KEY REFERENCES orders (order_uid),
+    name      TEXT NOT NULL,
+    phone     TEXT NOT NULL,
+    zip       TEXT NOT NULL,
+    city      TEXT NOT NULL,
+    address   TEXT NOT NULL,
+    region    TEXT NOT NULL,
+    email     TEXT NOT NULL
+);
+
+CREATE TABLE payments (
+    order_uid     TEXT PRIMARY KEY REFERENCES orders (order_uid),
+    transaction   TEXT NOT NULL,
+    request_id    TEXT NOT NULL,
+    currency      TEXT NOT NULL,
+    provider      TEXT NOT NULL,
+    amount        INT NOT NULL,
+    payment_dt    BIGINT NOT NULL,
+    bank          TEXT NOT NULL,
+    delivery_cost INT NOT NULL,
+    goods_total   INT NOT NULL,
+    custom_fee    INT NOT NULL
+);
+
+CREATE TABLE items (
+    id           BIGSERIAL PRIMARY KEY,
+    order_uid    TEXT NOT NULL REFERENCES orders (order_uid),
+    chrt_id      INT NOT NULL,
+    track_number TEXT NOT NULL,
+    price        INT NOT NULL,
+    rid          TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    sale         INT NOT NULL,
+    size         TEXT NOT NULL,
+    total_price  INT NOT NULL,
+    nm_id        INT NOT NULL,
+    brand        TEXT NOT NULL,
+    status       INT NOT NULL
+);
+
+CREATE INDEX items_order_uid_idx ON items (order_uid);
@@ -0,0 +1,3 @@
+-- Время последнего изменения заказа (Last-Modified); уже сохранённые заказы
+-- получают время миграции.
+ALTER TABLE orders ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
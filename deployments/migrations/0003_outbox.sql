@@ -0,0 +1,15 @@
+-- События для публикации в Kafka (OUTBOX_TOPIC): пишутся в транзакции с заказом,
+-- relay забирает их по id.
+CREATE TABLE outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        UUID NOT NULL DEFAULT gen_random_uuid(),
+    aggregate_id    TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT
+);
+
+CREATE INDEX outbox_aggregate_idx ON outbox (aggregate_id, id);
@@ -0,0 +1,8 @@
+-- Журнал обработанных сообщений для отсева повторов (KAFKA_DEDUP_WINDOW);
+-- записи старше окна удаляет сам сервис.
+CREATE TABLE processed_messages (
+    key          TEXT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX processed_messages_processed_at_idx ON processed_messages (processed_at);
@@ -0,0 +1,89 @@
+// Страница документации: строится из /api/v1/openapi.json без внешних зависимостей.
+
+function el(tag, attrs = {}, ...children) {
+    const node = document.createElement(tag);
+    Object.assign(node, attrs);
+    node.append(...children);
+    return node;
+}
+
+function refName(ref) {
+    return ref.split('/').pop();
+}
+
+function resolve(spec, obj) {
+    while (obj && obj.$ref) {
+        const path = obj.$ref.replace(/^#\//, '').split('/');
+        obj = path.reduce((o, key) => o[key], spec);
+    }
+    return obj;
+}
+
+function schemaLabel(schema) {
+    if (!schema) return '';
+    if (schema.$ref) return refName(schema.$ref);
+    if (schema.type === 'array') return `${schemaLabel(schema.items)}[]`;
+    return schema.format ? `${schema.type} (${schema.format})` : schema.type;
+}
+
+function renderOperation(spec, path, method, op) {
+    const badge = { get: 'primary', post: 'success', patch: 'warning', put: 'warning', delete: 'danger' }[method] || 'secondary';
+    const title = el('div', { className: 'd-flex align-items-center' },
+        el('span', { className: `badge bg-${badge} me-2 text-uppercase` }, method),
+        el('code', { className: op.deprecated ? 'text-decoration-line-through' : '' }, path),
+        el('span', { className: 'ms-3' }, op.summary || ''));
+    const body = el('div', { className: 'card-body' });
+    if (op.description) body.append(el('p', {}, op.description));
+
+    const params = (op.parameters || []).map((p) => resolve(spec, p));
+    if (params.length) {
+        const rows = params.map((p) => el('tr', {},
+            el('td', {}, el('code', {}, p.name)), el('td', {}, p.in),
+            el('td', {}, schemaLabel(p.schema)), el('td', {}, p.description || '')));
+        body.append(el('table', { className: 'table table-sm' },
+            el('thead', {}, el('tr', {}, el('th', {}, 'Параметр'), el('th', {}, 'Где'), el('th', {}, 'Тип'), el('th', {}, ''))),
+            el('tbody', {}, ...rows)));
+    }
+
+    if (op.requestBody) {
+        const types = Object.entries(op.requestBody.content || {}).map(([type, c]) => `${type}: ${schemaLabel(c.schema)}`);
+        body.append(el('p', {}, el('strong', {}, 'Тело запроса'), ` — ${types.join(', ')}`));
+    }
+
+    const responses = Object.entries(op.responses || {}).map(([code, r]) => {
+        r = resolve(spec, r);
+        const types = Object.entries(r.content || {}).map(([type, c]) => `${type}: ${schemaLabel(c.schema)}`);
+        return el('li', {}, el('strong', {}, code), ` — ${r.description} `, el('span', { className: 'text-muted' }, types.join(', ')));
+    });
+    body.append(el('ul', { className: 'mb-0' }, ...responses));
+
+    return el('div', { className: 'card mb-3' }, el('div', { className: 'card-header' }, title), body);
+}
+
+function renderSchema(name, schema) {
+    const required = new Set(schema.required || []);
+    const rows = Object.entries(schema.properties || {}).map(([prop, s]) => el('tr', {},
+        el('td', {}, el('code', {}, prop)), el('td', {}, schemaLabel(s)), el('td', {}, required.has(prop) ? 'да' : '')));
+    return el('div', { className: 'card mb-3', id: `schema-${name}` },
+        el('div', { className: 'card-header' }, el('strong', {}, name)),
+        el('table', { className: 'table table-sm mb-0' },
+            el('thead', {}, el('tr', {}, el('th', {}, 'Поле'), el('th', {}, 'Тип'), el('th', {}, 'Обязательное'))),
+            el('tbody', {}, ...rows)));
+}
+
+(async function () {
+    const spec = await (await fetch('/api/v1/openapi.json')).json();
+    document.title = `${spec.info.title} ${spec.info.version}`;
+    document.getElementById('docsInfo').textContent = `${spec.info.title} ${spec.info.version}. ${spec.info.description || ''}`;
+
+    const paths = document.getElementById('docsPaths');
+    for (const [path, item] of Object.entries(spec.paths)) {
+        for (const [method, op] of Object.entries(item)) {
+            paths.append(renderOperation(spec, path, method, op));
+        }
+    }
+    const schemas = document.getElementById('docsSchemas');
+    for (const [name, schema] of Object.entries(spec.components.schemas)) {
+        schemas.append(renderSchema(name, schema));
+    }
+})();
@@ -47,7 +47,7 @@ document.getElementById('orderForm').addEventListener('submit', async function(e
     orderResult.innerHTML = "";
 
     try {
-        const response = await fetch(`http://localhost:8081/order/${orderId}`);
+        const response = await fetch(`/api/v1/orders/${encodeURIComponent(orderId)}`);
         if (!response.ok) {
             if (response.status === 404) {
                 showAlert("Заказ с таким UID не найден", "warning");
@@ -64,3 +64,79 @@ document.getElementById('orderForm').addEventListener('submit', async function(e
         showAlert("⚠ Ошибка соединения с сервером", "danger");
     }
 });
+
+// Живая лента новых заказов (SSE). EventSource сам переподключается
+// и присылает Last-Event-ID, так что пропущенные события догружаются.
+const FEED_LIMIT = 50;
+let feedSource = null;
+
+function feedURL() {
+    const params = new URLSearchParams();
+    const service = document.getElementById('feedDeliveryService').value.trim();
+    const locale = document.getElementById('feedLocale').value.trim();
+    if (service) params.set('delivery_service', service);
+    if (locale) params.set('locale', locale);
+    const query = params.toString();
+    return '/api/v1/orders/stream' + (query ? '?' + query : '');
+}
+
+function setFeedStatus(text, cls) {
+    const status = document.getElementById('feedStatus');
+    status.textContent = text;
+    status.className = `badge bg-${cls} me-2`;
+}
+
+function addFeedItem(order) {
+    const list = document.getElementById('feedList');
+    const item = document.createElement('li');
+    item.className = 'list-group-item d-flex justify-content-between feed-new';
+    item.title = 'Показать заказ';
+
+    const left = document.createElement('span');
+    left.textContent = `${order.order_uid} · ${order.delivery_service} · ${order.city}`;
+    const right = document.createElement('span');
+    right.className = 'text-muted';
+    right.textContent = `${order.items_count} шт. · ${order.amount} ${order.currency}`;
+    item.append(left, right);
+
+    item.addEventListener('click', () => {
+        document.getElementById('orderId').value = order.order_uid;
+        document.getElementById('orderForm').requestSubmit();
+    });
+    list.prepend(item);
+    setTimeout(() => item.classList.remove('feed-new'), 50);
+    while (list.children.length > FEED_LIMIT) {
+        list.lastChild.remove();
+    }
+}
+
+function startFeed() {
+    stopFeed();
+    feedSource = new EventSource(feedURL());
+    feedSource.onopen = () => setFeedStatus('онлайн', 'success');
+    feedSource.onerror = () => setFeedStatus('переподключение…', 'warning');
+    feedSource.addEventListener('order', (e) => addFeedItem(JSON.parse(e.data)));
+    feedSource.addEventListener('gap', () => showAlert('Часть заказов пропущена — лента продолжена с последних', 'warning'));
+    document.getElementById('feedToggle').textContent = 'Пауза';
+}
+
+function stopFeed() {
+    if (feedSource) {
+        feedSource.close();
+        feedSource = null;
+    }
+    setFeedStatus('отключено', 'secondary');
+    document.getElementById('feedToggle').textContent = 'Продолжить';
+}
+
+document.getElementById('feedToggle').addEventListener('click', () => {
+    feedSource ? stopFeed() : startFeed();
+});
+
+document.getElementById('feedFilter').addEventListener('submit', (e) => {
+    e.preventDefault();
+    document.getElementById('feedList').innerHTML = '';
+    startFeed();
+});
+
+startFeed();
@@ -0,0 +1,65 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// CacheInvalidator применяет к локальному кэшу изменения, сделанные
+// другими инстансами (уведомления db.Listener).
+type CacheInvalidator struct {
+	repo  ports.OrderRepository
+	cache ports.Cache[string, *models.Order]
+}
+
+func NewCacheInvalidator(repo ports.OrderRepository, c ports.Cache[string, *models.Order]) *CacheInvalidator {
+	return &CacheInvalidator{repo: repo, cache: c}
+}
+
+// Apply удаляет запись или перечитывает её из БД. Обновляем только то,
+// что уже лежит в кэше, — иначе каждая вставка раздувала бы кэш всех инстансов.
+func (i *CacheInvalidator) Apply(ctx context.Context, ch db.OrderChange) {
+	if ch.Op == db.OrderDeleted {
+		i.cache.Delete(ch.UID)
+		return
+	}
+	if _, ok := i.cache.Get(ch.UID); !ok {
+		return
+	}
+	i.refresh(ctx, ch.UID)
+}
+
+// Resync перечитывает из БД всё содержимое кэша (после пропущенных уведомлений).
+// Если кэш не умеет перечислять ключи, он ничего не делает.
+func (i *CacheInvalidator) Resync(ctx context.Context) {
+	s, ok := i.cache.(cache.Snapshotter[*models.Order])
+	if !ok {
+		return
+	}
+	entries := s.Snapshot()
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		i.refresh(ctx, e.Key)
+	}
+	log.Printf("[db-listen] resynced %d cached orders", len(entries))
+}
+
+func (i *CacheInvalidator) refresh(ctx context.Context, uid string) {
+	o, err := i.repo.GetOrder(ctx, uid)
+	if err != nil {
+		if !errors.Is(err, ports.ErrOrderNotFound) {
+			log.Printf("[db-listen] refresh %s: %v", uid, err)
+		}
+		i.cache.Delete(uid)
+		return
+	}
+	cache.Fill(i.cache, uid, o)
+}
@@ -0,0 +1,43 @@
+package bootstrap
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// SaveCacheSnapshot пишет снимок, если кэш это поддерживает и путь задан.
+func SaveCacheSnapshot(snapshotPath string, c ports.Cache[string, *models.Order]) {
+	s, ok := c.(cache.Snapshotter[*models.Order])
+	if !ok || snapshotPath == "" {
+		return
+	}
+	n, err := cache.SaveSnapshot(snapshotPath, s)
+	if err != nil {
+		log.Printf("[cache] save snapshot: %v", err)
+		return
+	}
+	log.Printf("[cache] saved %d orders to snapshot %s", n, snapshotPath)
+}
+
+// RunCacheSnapshotter периодически сохраняет снимок до отмены ctx.
+// Финальный снимок при остановке делает вызывающий код после остановки консьюмера.
+func RunCacheSnapshotter(ctx context.Context, snapshotPath string, interval time.Duration, c ports.Cache[string, *models.Order]) {
+	if snapshotPath == "" || interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			SaveCacheSnapshot(snapshotPath, c)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// WarmupStrategy определяет, какие заказы попадут в кэш при старте.
+type WarmupStrategy string
+
+const (
+	WarmupNone   WarmupStrategy = "none"
+	WarmupAll    WarmupStrategy = "all"    // все заказы (старое поведение)
+	WarmupRecent WarmupStrategy = "recent" // последние Limit заказов по date_created
+	WarmupWindow WarmupStrategy = "window" // заказы за последние Window
+	WarmupHot    WarmupStrategy = "hot"    // ключи, горячие перед остановкой
+)
+
+func ParseWarmupStrategy(s string) (WarmupStrategy, error) {
+	switch st := WarmupStrategy(strings.ToLower(strings.TrimSpace(s))); st {
+	case "":
+		return WarmupAll, nil
+	case WarmupNone, WarmupAll, WarmupRecent, WarmupWindow, WarmupHot:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown warmup strategy %q", s)
+}
+
+type WarmupConfig struct {
+	Strategy     WarmupStrategy
+	Limit        int
+	Window       time.Duration
+	Concurrency  int
+	SnapshotPath string
+	HotKeysPath  string
+}
+
+// WarmupSource — то, что прогреву нужно от репозитория (*db.Repository).
+// Отдельный интерфейс, чтобы не расширять ports.OrderRepository.
+type WarmupSource interface {
+	GetOrder(ctx context.Context, uid string) (*models.Order, error)
+	GetOrderUIDs(ctx context.Context, since time.Time, limit int) ([]string, error)
+}
+
+// Warmer прогревает кэш в фоне; Ready становится true после завершения
+// (в том числе неудачного — сервис при этом работает через БД).
+type Warmer struct {
+	repo  WarmupSource
+	cache ports.Cache[string, *models.Order]
+	cfg   WarmupConfig
+	ready atomic.Bool
+}
+
+func NewWarmer(repo WarmupSource, c ports.Cache[string, *models.Order], cfg WarmupConfig) *Warmer {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	return &Warmer{repo: repo, cache: c, cfg: cfg}
+}
+
+func (w *Warmer) Ready() bool { return w.ready.Load() }
+
+func (w *Warmer) Run(ctx context.Context) {
+	defer w.ready.Store(true)
+
+	start := time.Now()
+	if w.restoreSnapshot() {
+		return
+	}
+
+	uids, err := w.selectUIDs(ctx)
+	if err != nil {
+		log.Printf("[warmup] %s: %v", w.cfg.Strategy, err)
+		return
+	}
+	loaded := w.load(ctx, uids)
+	log.Printf("[warmup] %s: loaded %d/%d orders in %s", w.cfg.Strategy, loaded, len(uids), time.Since(start).Round(time.Millisecond))
+}
+
+func (w *Warmer) restoreSnapshot() bool {
+	s, ok := w.cache.(cache.Snapshotter[*models.Order])
+	if !ok || w.cfg.SnapshotPath == "" {
+		return false
+	}
+	n, err := cache.LoadSnapshot(w.cfg.SnapshotPath, s)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[warmup] snapshot unusable, falling back to db: %v", err)
+		}
+		return false
+	}
+	if n == 0 {
+		log.Printf("[warmup] snapshot %s is empty, falling back to db", w.cfg.SnapshotPath)
+		return false
+	}
+	log.Printf("[warmup] restored %d orders from snapshot %s", n, w.cfg.SnapshotPath)
+	return true
+}
+
+func (w *Warmer) selectUIDs(ctx context.Context) ([]string, error) {
+	switch w.cfg.Strategy {
+	case WarmupNone:
+		return nil, nil
+	case WarmupRecent:
+		return w.repo.GetOrderUIDs(ctx, time.Time{}, w.cfg.Limit)
+	case WarmupWindow:
+		return w.repo.GetOrderUIDs(ctx, time.Now().Add(-w.cfg.Window), w.cfg.Limit)
+	case WarmupHot:
+		return readHotKeys(w.cfg.HotKeysPath, w.cfg.Limit)
+	default:
+		return w.repo.GetOrderUIDs(ctx, time.Time{}, 0)
+	}
+}
+
+// load загружает заказы не более чем в Concurrency потоков.
+// UID приходят от самых горячих/свежих, кладём их в обратном порядке,
+// чтобы они оказались «свежими» и в LRU.
+func (w *Warmer) load(ctx context.Context, uids []string) int {
+	jobs := make(chan string)
+	var loaded atomic.Int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uid := range jobs {
+				o, err := w.repo.GetOrder(ctx, uid)
+				if err != nil {
+					log.Printf("[warmup] load %s: %v", uid, err)
+					continue
+				}
+				cache.Fill(w.cache, uid, o)
+				loaded.Add(1)
+			}
+		}()
+	}
+
+	for i := len(uids) - 1; i >= 0; i-- {
+		select {
+		case jobs <- uids[i]:
+		case <-ctx.Done():
+			i = -1
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return int(loaded.Load())
+}
+
+// SaveHotKeys записывает до limit самых горячих ключей кэша (по одному на строку,
+// горячие первыми) для стратегии WarmupHot. Горячесть — по политике кэша:
+// Snapshot отдаёт элементы от холодных к горячим (LRU — по давности записи,
+// LFU — по частоте), так что ключи берутся с конца.
+func SaveHotKeys(path string, c ports.Cache[string, *models.Order], limit int) {
+	s, ok := c.(cache.Snapshotter[*models.Order])
+	if !ok || path == "" {
+		return
+	}
+	entries := s.Snapshot()
+	n := len(entries)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+
+	var b strings.Builder
+	for i := len(entries) - 1; i >= len(entries)-n; i-- {
+		b.WriteString(entries[i].Key)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		log.Printf("[warmup] save hot keys: %v", err)
+		return
+	}
+	log.Printf("[warmup] saved %d hot keys to %s", n, path)
+}
+
+func readHotKeys(path string, limit int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read hot keys: %w", err)
+	}
+	defer f.Close()
+
+	var keys []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() && (limit <= 0 || len(keys) < limit) {
+		if k := strings.TrimSpace(sc.Text()); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys, sc.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,8 +22,8 @@ type shard[V any] struct {
 
 type ShardedLRU[V any] struct {
 	shards   []shard[V]
-	capacity int
-	ttl      time.Duration
+	capacity atomic.Int64 // на шард
+	ttl      atomic.Int64
 }
 
 func NewShardedLRU[V any](numShards int, capacity int, ttl time.Duration) *ShardedLRU[V] {
@@ -33,7 +34,10 @@ func NewShardedLRU[V any](numShards int, capacity int, ttl time.Duration) *Shard
 	for i := range s {
 		s[i] = shard[V]{items: make(map[string]*list.Element), lru: list.New()}
 	}
-	return &ShardedLRU[V]{shards: s, capacity: capacity / numShards, ttl: ttl}
+	c := &ShardedLRU[V]{shards: s}
+	c.capacity.Store(int64(perShardCapacity(capacity, numShards)))
+	c.ttl.Store(int64(ttl))
+	return c
 }
 
 func (c *ShardedLRU[V]) shardFor(key string) *shard[V] {
@@ -58,26 +62,32 @@ func (c *ShardedLRU[V]) Get(key string) (V, bool) {
 }
 
 func (c *ShardedLRU[V]) Set(key string, value V) {
+	c.set(key, value, time.Now().Add(time.Duration(c.ttl.Load())))
+}
+
+func (c *ShardedLRU[V]) set(key string, value V, expiresAt time.Time) {
 	s := c.shardFor(key)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if el, ok := s.items[key]; ok {
-		el.Value = cacheItem[V]{key: key, value: value, expiresAt: time.Now().Add(c.ttl)}
+		el.Value = cacheItem[V]{key: key, value: value, expiresAt: expiresAt}
 		s.lru.MoveToFront(el)
 		return
 	}
 
-	if s.lru.Len() >= c.capacity && c.capacity > 0 {
+	capacity := int(c.capacity.Load())
+	for s.lru.Len() >= capacity && capacity > 0 {
 		tail := s.lru.Back()
-		if tail != nil {
-			del := tail.Value.(cacheItem[V]).key
-			s.lru.Remove(tail)
-			delete(s.items, del)
+		if tail == nil {
+			break
 		}
+		del := tail.Value.(cacheItem[V]).key
+		s.lru.Remove(tail)
+		delete(s.items, del)
 	}
 
-	el := s.lru.PushFront(cacheItem[V]{key: key, value: value, expiresAt: time.Now().Add(c.ttl)})
+	el := s.lru.PushFront(cacheItem[V]{key: key, value: value, expiresAt: expiresAt})
 	s.items[key] = el
 }
 
@@ -90,3 +100,54 @@ func (c *ShardedLRU[V]) Delete(key string) {
 		delete(s.items, key)
 	}
 }
+
+// Snapshot возвращает живые элементы от давно использованных к свежим.
+func (c *ShardedLRU[V]) Snapshot() []SnapshotEntry[V] {
+	shards := make([][]SnapshotEntry[V], len(c.shards))
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.RLock()
+		for el := s.lru.Back(); el != nil; el = el.Prev() {
+			it := el.Value.(cacheItem[V])
+			if now.Before(it.expiresAt) {
+				shards[i] = append(shards[i], SnapshotEntry[V]{Key: it.key, Value: it.value, ExpiresAt: it.expiresAt})
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return interleave(shards)
+}
+
+// Restore кладёт элементы в порядке слайса с их исходным сроком жизни,
+// поэтому последний элемент окажется самым свежим.
+func (c *ShardedLRU[V]) Restore(entries []SnapshotEntry[V]) {
+	for _, it := range entries {
+		c.set(it.Key, it.Value, it.ExpiresAt)
+	}
+}
+
+// Resize меняет общую ёмкость на лету; лишние элементы вытесняются сразу.
+// Ёмкость меньше числа шардов округляется до одного элемента на шард.
+func (c *ShardedLRU[V]) Resize(capacity int) {
+	perShard := perShardCapacity(capacity, len(c.shards))
+	c.capacity.Store(int64(perShard))
+	if perShard <= 0 {
+		return
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		for s.lru.Len() > perShard {
+			tail := s.lru.Back()
+			s.lru.Remove(tail)
+			delete(s.items, tail.Value.(cacheItem[V]).key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SetTTL действует на элементы, положенные после вызова.
+func (c *ShardedLRU[V]) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
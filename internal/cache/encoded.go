@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+
+	"wb-test-task/internal/ports"
+)
+
+// Encoded — неизменяемый снимок значения вместе с готовым JSON-ответом.
+// Байты сериализуются один раз при Set, так что попадание в кэш отдаётся
+// одной записью в ResponseWriter без повторного маршалинга.
+type Encoded[V any] struct {
+	// Value — копия (если V реализует Cloner), общая для всех читателей
+	// снимка: только для чтения, иначе разойдётся с JSON и ETag
+	Value V
+	JSON  []byte
+	Gzip  []byte // nil, если сжатие выключено
+	ETag  string
+}
+
+// Cloner реализуют значения со ссылочными полями: Encode хранит копию, чтобы
+// последующие изменения оригинала не расходились с сериализованными байтами.
+type Cloner[V any] interface {
+	Clone() V
+}
+
+// Encode сериализует значение в тот же вид, что отдаёт json.Encoder
+// (с завершающим переводом строки), и считает сильный ETag по содержимому.
+func Encode[V any](v V, withGzip bool) (*Encoded[V], error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+
+	if c, ok := any(v).(Cloner[V]); ok {
+		v = c.Clone()
+	}
+	sum := sha256.Sum256(body)
+	e := &Encoded[V]{Value: v, JSON: body, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+
+	if withGzip {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		e.Gzip = buf.Bytes()
+	}
+	return e, nil
+}
+
+// EncodedCache реализует ports.Cache[string, V] поверх кэша снимков Encoded,
+// поэтому консьюмер и восстановление кэша работают с ним как с обычным кэшем.
+type EncodedCache[V any] struct {
+	inner ports.Cache[string, *Encoded[V]]
+	gzip  bool
+}
+
+func NewEncodedCache[V any](inner ports.Cache[string, *Encoded[V]], withGzip bool) *EncodedCache[V] {
+	return &EncodedCache[V]{inner: inner, gzip: withGzip}
+}
+
+func (c *EncodedCache[V]) Get(key string) (V, bool) {
+	if e, ok := c.inner.Get(key); ok && e != nil {
+		return e.Value, true
+	}
+	return *new(V), false
+}
+
+func (c *EncodedCache[V]) GetEncoded(key string) (*Encoded[V], bool) {
+	e, ok := c.inner.Get(key)
+	if !ok || e == nil {
+		return nil, false
+	}
+	return e, true
+}
+
+func (c *EncodedCache[V]) Set(key string, value V) {
+	e, err := Encode(value, c.gzip)
+	if err != nil {
+		log.Printf("[cache] encode %q: %v", key, err)
+		c.inner.Delete(key)
+		return
+	}
+	c.inner.Set(key, e)
+}
+
+func (c *EncodedCache[V]) Delete(key string) {
+	c.inner.Delete(key)
+}
+
+func (c *EncodedCache[V]) Resize(capacity int) {
+	if t, ok := c.inner.(Tunable); ok {
+		t.Resize(capacity)
+	}
+}
+
+func (c *EncodedCache[V]) SetTTL(ttl time.Duration) {
+	if t, ok := c.inner.(Tunable); ok {
+		t.SetTTL(ttl)
+	}
+}
+
+// Snapshot сохраняет только исходные значения: байты ответа пересобираются при Restore.
+func (c *EncodedCache[V]) Snapshot() []SnapshotEntry[V] {
+	inner, ok := c.inner.(Snapshotter[*Encoded[V]])
+	if !ok {
+		return nil
+	}
+	src := inner.Snapshot()
+	out := make([]SnapshotEntry[V], 0, len(src))
+	for _, it := range src {
+		out = append(out, SnapshotEntry[V]{Key: it.Key, Value: it.Value.Value, ExpiresAt: it.ExpiresAt})
+	}
+	return out
+}
+
+func (c *EncodedCache[V]) Restore(entries []SnapshotEntry[V]) {
+	inner, ok := c.inner.(Snapshotter[*Encoded[V]])
+	if !ok {
+		for _, it := range entries {
+			c.Set(it.Key, it.Value)
+		}
+		return
+	}
+	enc := make([]SnapshotEntry[*Encoded[V]], 0, len(entries))
+	for _, it := range entries {
+		e, err := Encode(it.Value, c.gzip)
+		if err != nil {
+			log.Printf("[cache] encode %q: %v", it.Key, err)
+			continue
+		}
+		enc = append(enc, SnapshotEntry[*Encoded[V]]{Key: it.Key, Value: e, ExpiresAt: it.ExpiresAt})
+	}
+	inner.Restore(enc)
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"container/list"
+	"sort"
+)
+
+// lfu — O(1) LFU: узлы сгруппированы по частоте обращений,
+// вытесняется самый старый узел из группы с минимальной частотой.
+type lfu[V any] struct {
+	capacity int
+	items    map[string]*list.Element
+	freqs    map[int]*list.List
+	minFreq  int
+}
+
+type lfuNode[V any] struct {
+	key  string
+	e    entry[V]
+	freq int
+}
+
+func newLFU[V any](capacity int) evictor[V] {
+	return &lfu[V]{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		freqs:    make(map[int]*list.List),
+	}
+}
+
+func (c *lfu[V]) touch(el *list.Element) *list.Element {
+	n := el.Value.(*lfuNode[V])
+	old := c.freqs[n.freq]
+	old.Remove(el)
+	if old.Len() == 0 {
+		delete(c.freqs, n.freq)
+		if c.minFreq == n.freq {
+			c.minFreq++
+		}
+	}
+	n.freq++
+	return c.bucket(n.freq).PushFront(n)
+}
+
+func (c *lfu[V]) bucket(freq int) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+func (c *lfu[V]) get(key string) (entry[V], bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return entry[V]{}, false
+	}
+	el = c.touch(el)
+	c.items[key] = el
+	return el.Value.(*lfuNode[V]).e, true
+}
+
+func (c *lfu[V]) set(key string, e entry[V]) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lfuNode[V]).e = e
+		c.items[key] = c.touch(el)
+		return
+	}
+
+	if c.capacity > 0 && len(c.items) >= c.capacity {
+		c.evict()
+	}
+
+	c.minFreq = 1
+	c.items[key] = c.bucket(1).PushFront(&lfuNode[V]{key: key, e: e, freq: 1})
+}
+
+func (c *lfu[V]) del(key string) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	n := el.Value.(*lfuNode[V])
+	l := c.freqs[n.freq]
+	l.Remove(el)
+	if l.Len() == 0 {
+		delete(c.freqs, n.freq)
+		if c.minFreq == n.freq {
+			c.resetMinFreq()
+		}
+	}
+	delete(c.items, key)
+}
+
+// resetMinFreq ищет минимальную частоту заново: после удаления последнего узла
+// младшей группы следующая по частоте может отстоять на несколько шагов.
+func (c *lfu[V]) resetMinFreq() {
+	c.minFreq = 0
+	for f := range c.freqs {
+		if c.minFreq == 0 || f < c.minFreq {
+			c.minFreq = f
+		}
+	}
+}
+
+func (c *lfu[V]) each(fn func(key string, e entry[V])) {
+	freqs := make([]int, 0, len(c.freqs))
+	for f := range c.freqs {
+		freqs = append(freqs, f)
+	}
+	sort.Ints(freqs)
+	for _, f := range freqs {
+		for el := c.freqs[f].Back(); el != nil; el = el.Prev() {
+			n := el.Value.(*lfuNode[V])
+			fn(n.key, n.e)
+		}
+	}
+}
+
+// evict удаляет самый старый элемент с минимальной частотой.
+func (c *lfu[V]) evict() {
+	l := c.freqs[c.minFreq]
+	if l == nil {
+		return
+	}
+	tail := l.Back()
+	l.Remove(tail)
+	if l.Len() == 0 {
+		delete(c.freqs, c.minFreq)
+		c.resetMinFreq()
+	}
+	delete(c.items, tail.Value.(*lfuNode[V]).key)
+}
+
+func (c *lfu[V]) resize(capacity int) {
+	c.capacity = capacity
+	for capacity > 0 && len(c.items) > capacity {
+		c.evict()
+	}
+}
@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wb-test-task/internal/ports"
+)
+
+// Policy — стратегия вытеснения, которую выбирают через конфиг (CACHE_POLICY).
+type Policy string
+
+const (
+	PolicyLRU     Policy = "lru"
+	PolicyLFU     Policy = "lfu"
+	Policy2Q      Policy = "2q"
+	PolicyTinyLFU Policy = "tinylfu"
+)
+
+// Policies перечисляет все поддерживаемые стратегии (нужно бенчмарку и валидации конфига).
+var Policies = []Policy{PolicyLRU, PolicyLFU, Policy2Q, PolicyTinyLFU}
+
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy(strings.ToLower(strings.TrimSpace(s)))
+	if p == "" {
+		return PolicyLRU, nil
+	}
+	for _, known := range Policies {
+		if p == known {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown cache policy %q", s)
+}
+
+// New создаёт шардированный кэш с выбранной стратегией вытеснения.
+func New[V any](p Policy, numShards int, capacity int, ttl time.Duration) (ports.Cache[string, V], error) {
+	switch p {
+	case PolicyLRU, "":
+		return NewShardedLRU[V](numShards, capacity, ttl), nil
+	case PolicyLFU:
+		return newSharded(numShards, capacity, ttl, newLFU[V]), nil
+	case Policy2Q:
+		return newSharded(numShards, capacity, ttl, newTwoQueue[V]), nil
+	case PolicyTinyLFU:
+		return newSharded(numShards, capacity, ttl, newTinyLFU[V]), nil
+	}
+	return nil, fmt.Errorf("unknown cache policy %q", p)
+}
+
+// evictor — однопоточная структура вытеснения внутри одного шарда.
+// Блокировки и TTL берёт на себя sharded.
+type evictor[V any] interface {
+	get(key string) (entry[V], bool)
+	set(key string, e entry[V])
+	del(key string)
+	// each обходит элементы от «холодных» к «горячим»
+	each(fn func(key string, e entry[V]))
+	// resize меняет ёмкость и сразу вытесняет лишнее
+	resize(capacity int)
+}
+
+// Tunable реализуют кэши, чьи ёмкость и TTL можно менять без перезапуска.
+type Tunable interface {
+	Resize(capacity int)
+	SetTTL(ttl time.Duration)
+}
+
+// Filler реализуют многоуровневые кэши: Fill кладёт значение, прочитанное из
+// БД (прогрев, промах, перечитывание), не оповещая другие реплики — в отличие
+// от Set, которым консьюмер записывает изменение.
+type Filler[V any] interface {
+	Fill(key string, value V)
+}
+
+// Fill кладёт прочитанное из БД значение через Filler, если кэш его умеет, иначе через Set.
+func Fill[V any](c ports.Cache[string, V], key string, value V) {
+	if f, ok := c.(Filler[V]); ok {
+		f.Fill(key, value)
+		return
+	}
+	c.Set(key, value)
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+type lockedShard[V any] struct {
+	mu sync.Mutex
+	ev evictor[V]
+}
+
+type sharded[V any] struct {
+	shards []lockedShard[V]
+	ttl    atomic.Int64
+}
+
+func newSharded[V any](numShards, capacity int, ttl time.Duration, mk func(capacity int) evictor[V]) *sharded[V] {
+	if numShards <= 0 {
+		numShards = 16
+	}
+	s := make([]lockedShard[V], numShards)
+	for i := range s {
+		s[i].ev = mk(perShardCapacity(capacity, numShards))
+	}
+	c := &sharded[V]{shards: s}
+	c.ttl.Store(int64(ttl))
+	return c
+}
+
+func perShardCapacity(capacity, numShards int) int {
+	perShard := capacity / numShards
+	if capacity > 0 && perShard == 0 {
+		perShard = 1
+	}
+	return perShard
+}
+
+func (c *sharded[V]) shardFor(key string) *lockedShard[V] {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &c.shards[int(h.Sum32())%len(c.shards)]
+}
+
+func (c *sharded[V]) Get(key string) (V, bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.ev.get(key)
+	if !ok {
+		return *new(V), false
+	}
+	if time.Now().After(e.expiresAt) {
+		s.ev.del(key)
+		return *new(V), false
+	}
+	return e.value, true
+}
+
+func (c *sharded[V]) Set(key string, value V) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ev.set(key, entry[V]{value: value, expiresAt: time.Now().Add(time.Duration(c.ttl.Load()))})
+}
+
+func (c *sharded[V]) Delete(key string) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ev.del(key)
+}
+
+func (c *sharded[V]) Snapshot() []SnapshotEntry[V] {
+	shards := make([][]SnapshotEntry[V], len(c.shards))
+	now := time.Now()
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.ev.each(func(key string, e entry[V]) {
+			if now.Before(e.expiresAt) {
+				shards[i] = append(shards[i], SnapshotEntry[V]{Key: key, Value: e.value, ExpiresAt: e.expiresAt})
+			}
+		})
+		s.mu.Unlock()
+	}
+	return interleave(shards)
+}
+
+func (c *sharded[V]) Restore(entries []SnapshotEntry[V]) {
+	for _, it := range entries {
+		s := c.shardFor(it.Key)
+		s.mu.Lock()
+		s.ev.set(it.Key, entry[V]{value: it.Value, expiresAt: it.ExpiresAt})
+		s.mu.Unlock()
+	}
+}
+
+func (c *sharded[V]) Resize(capacity int) {
+	perShard := perShardCapacity(capacity, len(c.shards))
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.ev.resize(perShard)
+		s.mu.Unlock()
+	}
+}
+
+func (c *sharded[V]) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Формат файла снимка:
+//
+//	magic "WBCS" | version uint16 | crc32c(payload) uint32 | len(payload) uint64 | payload
+//
+// payload — gob-последовательность SnapshotEntry в порядке «холодные → горячие».
+const (
+	snapshotMagic   = "WBCS"
+	snapshotVersion = 1
+)
+
+var (
+	ErrSnapshotFormat   = errors.New("cache snapshot: bad header")
+	ErrSnapshotVersion  = errors.New("cache snapshot: unsupported version")
+	ErrSnapshotChecksum = errors.New("cache snapshot: checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type SnapshotEntry[V any] struct {
+	Key       string
+	Value     V
+	ExpiresAt time.Time
+}
+
+// Snapshotter реализуют кэши, умеющие выгрузить и восстановить своё содержимое.
+type Snapshotter[V any] interface {
+	Snapshot() []SnapshotEntry[V]
+	Restore(entries []SnapshotEntry[V])
+}
+
+// interleave сливает снимки шардов (каждый — холодные → горячие), выравнивая
+// их по горячему концу: хвост результата — самые горячие элементы всех шардов,
+// а порядок внутри шарда сохраняется.
+func interleave[V any](shards [][]SnapshotEntry[V]) []SnapshotEntry[V] {
+	total, longest := 0, 0
+	for _, s := range shards {
+		total += len(s)
+		longest = max(longest, len(s))
+	}
+	out := make([]SnapshotEntry[V], 0, total)
+	for rank := longest; rank > 0; rank-- {
+		for _, s := range shards {
+			if len(s) >= rank {
+				out = append(out, s[len(s)-rank])
+			}
+		}
+	}
+	return out
+}
+
+// SaveSnapshot атомарно записывает снимок кэша в path (через временный файл и rename).
+func SaveSnapshot[V any](path string, s Snapshotter[V]) (int, error) {
+	entries := s.Snapshot()
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(entries); err != nil {
+		return 0, fmt.Errorf("cache snapshot: encode: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("cache snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	var hdr [4 + 2 + 4 + 8]byte
+	copy(hdr[:4], snapshotMagic)
+	binary.BigEndian.PutUint16(hdr[4:6], snapshotVersion)
+	binary.BigEndian.PutUint32(hdr[6:10], crc32.Checksum(payload.Bytes(), crcTable))
+	binary.BigEndian.PutUint64(hdr[10:18], uint64(payload.Len()))
+	_, _ = w.Write(hdr[:])
+	_, _ = w.Write(payload.Bytes())
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("cache snapshot: write: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("cache snapshot: sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("cache snapshot: close: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("cache snapshot: rename: %w", err)
+	}
+	return len(entries), nil
+}
+
+// LoadSnapshot читает снимок, проверяет заголовок и контрольную сумму
+// и восстанавливает в кэш ещё не истёкшие элементы.
+func LoadSnapshot[V any](path string, s Snapshotter[V]) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("cache snapshot: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var hdr [18]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil || string(hdr[:4]) != snapshotMagic {
+		return 0, ErrSnapshotFormat
+	}
+	if v := binary.BigEndian.Uint16(hdr[4:6]); v != snapshotVersion {
+		return 0, fmt.Errorf("%w: %d", ErrSnapshotVersion, v)
+	}
+	sum := binary.BigEndian.Uint32(hdr[6:10])
+	size := binary.BigEndian.Uint64(hdr[10:18])
+
+	payload, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return 0, fmt.Errorf("cache snapshot: read: %w", err)
+	}
+	if uint64(len(payload)) != size || crc32.Checksum(payload, crcTable) != sum {
+		return 0, ErrSnapshotChecksum
+	}
+
+	var entries []SnapshotEntry[V]
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&entries); err != nil {
+		return 0, fmt.Errorf("cache snapshot: decode: %w", err)
+	}
+
+	now := time.Now()
+	live := entries[:0]
+	for _, e := range entries {
+		if now.Before(e.ExpiresAt) {
+			live = append(live, e)
+		}
+	}
+	s.Restore(live)
+	return len(live), nil
+}
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"math"
+)
+
+// tinyLFU — W-TinyLFU: маленькое LRU-окно (~1%) принимает все новые ключи,
+// а кандидат, вытесненный из окна, попадает в основной SLRU только если
+// по count-min sketch он популярнее жертвы из основной области.
+type tinyLFU[V any] struct {
+	items  map[string]*list.Element
+	window *list.List
+	probat *list.List
+	protec *list.List
+
+	windowCap int
+	probatCap int
+	protecCap int
+
+	sketch *cmSketch
+}
+
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+type tinyNode[V any] struct {
+	key string
+	e   entry[V]
+	seg int
+}
+
+func newTinyLFU[V any](capacity int) evictor[V] {
+	c := &tinyLFU[V]{
+		items:  make(map[string]*list.Element),
+		window: list.New(),
+		probat: list.New(),
+		protec: list.New(),
+		sketch: newCMSketch(capacity),
+	}
+	c.setCapacity(capacity)
+	return c
+}
+
+// setCapacity: 0 — без ограничений, как у остальных политик; тогда всё живёт в окне.
+func (c *tinyLFU[V]) setCapacity(capacity int) {
+	if capacity <= 0 {
+		c.windowCap, c.probatCap, c.protecCap = math.MaxInt, 0, 0
+		return
+	}
+	c.windowCap = max(capacity/100, 1)
+	mainCap := max(capacity-c.windowCap, 0)
+	c.protecCap = mainCap * 8 / 10
+	c.probatCap = mainCap - c.protecCap
+}
+
+// resize пересчитывает сегменты: излишки окна и защищённого сегмента
+// переходят в испытательный, а вытесняются только испытательные элементы.
+func (c *tinyLFU[V]) resize(capacity int) {
+	c.setCapacity(capacity)
+	for c.window.Len() > c.windowCap {
+		c.demote(c.window.Back())
+	}
+	for c.protec.Len() > c.protecCap {
+		c.demote(c.protec.Back())
+	}
+	for c.probat.Len() > 0 && c.probat.Len()+c.protec.Len() > c.probatCap+c.protecCap {
+		c.remove(c.probat.Back())
+	}
+}
+
+// demote переносит элемент в начало испытательного сегмента.
+func (c *tinyLFU[V]) demote(el *list.Element) {
+	n := el.Value.(*tinyNode[V])
+	c.remove(el)
+	n.seg = segProbation
+	c.items[n.key] = c.probat.PushFront(n)
+}
+
+func (c *tinyLFU[V]) get(key string) (entry[V], bool) {
+	c.sketch.add(key)
+	el, ok := c.items[key]
+	if !ok {
+		return entry[V]{}, false
+	}
+	c.hit(el)
+	return el.Value.(*tinyNode[V]).e, true
+}
+
+func (c *tinyLFU[V]) hit(el *list.Element) {
+	n := el.Value.(*tinyNode[V])
+	switch n.seg {
+	case segWindow:
+		c.window.MoveToFront(el)
+	case segProtected:
+		c.protec.MoveToFront(el)
+	case segProbation:
+		// повторное обращение — переводим в защищённый сегмент
+		c.probat.Remove(el)
+		n.seg = segProtected
+		c.items[n.key] = c.protec.PushFront(n)
+		if c.protec.Len() > c.protecCap {
+			tail := c.protec.Back()
+			c.protec.Remove(tail)
+			d := tail.Value.(*tinyNode[V])
+			d.seg = segProbation
+			c.items[d.key] = c.probat.PushFront(d)
+		}
+	}
+}
+
+func (c *tinyLFU[V]) set(key string, e entry[V]) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*tinyNode[V]).e = e
+		c.hit(el)
+		return
+	}
+	c.sketch.add(key)
+
+	n := &tinyNode[V]{key: key, e: e, seg: segWindow}
+	c.items[key] = c.window.PushFront(n)
+	if c.window.Len() <= c.windowCap {
+		return
+	}
+
+	// окно переполнено: кандидат борется за место в основной области
+	tail := c.window.Back()
+	c.window.Remove(tail)
+	cand := tail.Value.(*tinyNode[V])
+
+	if c.probat.Len()+c.protec.Len() < c.probatCap+c.protecCap {
+		cand.seg = segProbation
+		c.items[cand.key] = c.probat.PushFront(cand)
+		return
+	}
+
+	victimEl := c.probat.Back()
+	if victimEl == nil {
+		victimEl = c.protec.Back()
+	}
+	if victimEl == nil {
+		delete(c.items, cand.key)
+		return
+	}
+	victim := victimEl.Value.(*tinyNode[V])
+	if c.sketch.estimate(cand.key) <= c.sketch.estimate(victim.key) {
+		delete(c.items, cand.key)
+		return
+	}
+	c.remove(victimEl)
+	cand.seg = segProbation
+	c.items[cand.key] = c.probat.PushFront(cand)
+}
+
+func (c *tinyLFU[V]) remove(el *list.Element) {
+	n := el.Value.(*tinyNode[V])
+	switch n.seg {
+	case segWindow:
+		c.window.Remove(el)
+	case segProbation:
+		c.probat.Remove(el)
+	case segProtected:
+		c.protec.Remove(el)
+	}
+	delete(c.items, n.key)
+}
+
+func (c *tinyLFU[V]) del(key string) {
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *tinyLFU[V]) each(fn func(key string, e entry[V])) {
+	for _, l := range []*list.List{c.probat, c.protec, c.window} {
+		for el := l.Back(); el != nil; el = el.Prev() {
+			n := el.Value.(*tinyNode[V])
+			fn(n.key, n.e)
+		}
+	}
+}
+
+// cmSketch — count-min sketch с 4 строками и периодическим старением:
+// после sampleSize добавлений все счётчики делятся пополам.
+type cmSketch struct {
+	rows       [4][]uint8
+	seeds      [4]maphash.Seed
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newCMSketch(capacity int) *cmSketch {
+	width := 16
+	for width < capacity*2 {
+		width <<= 1
+	}
+	s := &cmSketch{mask: uint64(width - 1), sampleSize: 10 * max(capacity, 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+		s.seeds[i] = maphash.MakeSeed()
+	}
+	return s
+}
+
+func (s *cmSketch) add(key string) {
+	for i := range s.rows {
+		idx := maphash.String(s.seeds[i], key) & s.mask
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(key string) uint8 {
+	var m uint8 = 255
+	for i := range s.rows {
+		if v := s.rows[i][maphash.String(s.seeds[i], key)&s.mask]; v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
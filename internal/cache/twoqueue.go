@@ -0,0 +1,149 @@
+package cache
+
+import "container/list"
+
+// twoQueue — упрощённый 2Q: новые ключи попадают в FIFO a1in,
+// вытесненные оттуда ключи помним в «призрачной» очереди a1out,
+// и только повторное обращение к ним переводит ключ в основной LRU am.
+// Разовые запросы так не вымывают горячие заказы.
+type twoQueue[V any] struct {
+	capacity int
+	inCap    int
+	outCap   int
+
+	items map[string]*list.Element // a1in + am
+	inKey map[string]bool          // true, если элемент лежит в a1in
+	ghost map[string]*list.Element // a1out, храним только ключи
+
+	a1in  *list.List
+	a1out *list.List
+	am    *list.List
+}
+
+type twoQNode[V any] struct {
+	key string
+	e   entry[V]
+}
+
+func newTwoQueue[V any](capacity int) evictor[V] {
+	c := &twoQueue[V]{
+		items: make(map[string]*list.Element),
+		inKey: make(map[string]bool),
+		ghost: make(map[string]*list.Element),
+		a1in:  list.New(),
+		a1out: list.New(),
+		am:    list.New(),
+	}
+	c.setCapacity(capacity)
+	return c
+}
+
+func (c *twoQueue[V]) setCapacity(capacity int) {
+	c.capacity = capacity
+	c.inCap = max(capacity/4, 1)
+	c.outCap = max(capacity/2, 1)
+}
+
+func (c *twoQueue[V]) get(key string) (entry[V], bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return entry[V]{}, false
+	}
+	if !c.inKey[key] {
+		c.am.MoveToFront(el)
+	}
+	return el.Value.(*twoQNode[V]).e, true
+}
+
+func (c *twoQueue[V]) set(key string, e entry[V]) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*twoQNode[V]).e = e
+		if !c.inKey[key] {
+			c.am.MoveToFront(el)
+		}
+		return
+	}
+
+	n := &twoQNode[V]{key: key, e: e}
+	if g, ok := c.ghost[key]; ok {
+		c.a1out.Remove(g)
+		delete(c.ghost, key)
+		c.makeRoom()
+		c.items[key] = c.am.PushFront(n)
+		return
+	}
+
+	c.makeRoom()
+	c.items[key] = c.a1in.PushFront(n)
+	c.inKey[key] = true
+}
+
+// makeRoom освобождает место под один элемент.
+func (c *twoQueue[V]) makeRoom() {
+	if c.capacity <= 0 || len(c.items) < c.capacity {
+		return
+	}
+	if c.a1in.Len() >= c.inCap || c.am.Len() == 0 {
+		tail := c.a1in.Back()
+		if tail == nil {
+			return
+		}
+		key := tail.Value.(*twoQNode[V]).key
+		c.a1in.Remove(tail)
+		delete(c.items, key)
+		delete(c.inKey, key)
+
+		c.ghost[key] = c.a1out.PushFront(key)
+		if c.a1out.Len() > c.outCap {
+			old := c.a1out.Back()
+			c.a1out.Remove(old)
+			delete(c.ghost, old.Value.(string))
+		}
+		return
+	}
+	tail := c.am.Back()
+	c.am.Remove(tail)
+	delete(c.items, tail.Value.(*twoQNode[V]).key)
+}
+
+func (c *twoQueue[V]) del(key string) {
+	if el, ok := c.items[key]; ok {
+		if c.inKey[key] {
+			c.a1in.Remove(el)
+			delete(c.inKey, key)
+		} else {
+			c.am.Remove(el)
+		}
+		delete(c.items, key)
+	}
+	if g, ok := c.ghost[key]; ok {
+		c.a1out.Remove(g)
+		delete(c.ghost, key)
+	}
+}
+
+func (c *twoQueue[V]) each(fn func(key string, e entry[V])) {
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for el := l.Back(); el != nil; el = el.Prev() {
+			n := el.Value.(*twoQNode[V])
+			fn(n.key, n.e)
+		}
+	}
+}
+
+func (c *twoQueue[V]) resize(capacity int) {
+	c.setCapacity(capacity)
+	for capacity > 0 && len(c.items) > capacity {
+		if c.a1in.Len() > 0 {
+			tail := c.a1in.Back()
+			c.del(tail.Value.(*twoQNode[V]).key)
+			continue
+		}
+		c.del(c.am.Back().Value.(*twoQNode[V]).key)
+	}
+	for c.a1out.Len() > c.outCap {
+		old := c.a1out.Back()
+		c.a1out.Remove(old)
+		delete(c.ghost, old.Value.(string))
+	}
+}
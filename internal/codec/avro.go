@@ -0,0 +1,326 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Минимальный декодер Avro binary по схеме писателя: разбирает значение в
+// map/[]any/примитивы, а в структуру его раскладывает encoding/json по именам
+// полей. Поддерживаются все типы спецификации, кроме значений по умолчанию
+// при разрешении схем (они не нужны, когда читаем схемой писателя).
+
+type avroSchema struct {
+	typ      string // null, boolean, int, long, float, double, bytes, string, record, enum, array, map, fixed, union
+	logical  string
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema // элементы array и значения map
+	branches []*avroSchema
+	size     int
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+}
+
+var errAvroShort = errors.New("avro: unexpected end of data")
+
+// parseAvroSchema разбирает JSON-схему; именованные типы можно использовать по имени.
+func parseAvroSchema(text string) (*avroSchema, error) {
+	var raw any
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, fmt.Errorf("avro schema: %w", err)
+	}
+	p := &avroParser{named: map[string]*avroSchema{}}
+	return p.parse(raw, "")
+}
+
+type avroParser struct {
+	named map[string]*avroSchema
+}
+
+func (p *avroParser) parse(raw any, namespace string) (*avroSchema, error) {
+	switch v := raw.(type) {
+	case string:
+		switch v {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{typ: v}, nil
+		}
+		if s, ok := p.named[p.fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("avro schema: unknown type %q", v)
+	case []any:
+		u := &avroSchema{typ: "union"}
+		for _, b := range v {
+			s, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			u.branches = append(u.branches, s)
+		}
+		return u, nil
+	case map[string]any:
+		return p.parseComplex(v, namespace)
+	}
+	return nil, fmt.Errorf("avro schema: unexpected %T", raw)
+}
+
+func (p *avroParser) parseComplex(m map[string]any, namespace string) (*avroSchema, error) {
+	typ, _ := m["type"].(string)
+	logical, _ := m["logicalType"].(string)
+	if ns, ok := m["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("avro schema: %s without name", typ)
+		}
+		s := &avroSchema{typ: typ, logical: logical}
+		if typ == "error" {
+			s.typ = "record"
+		}
+		// регистрируем до разбора полей, чтобы работали рекурсивные типы
+		full := p.fullName(name, namespace)
+		p.named[full] = s
+		if i := strings.LastIndex(full, "."); i >= 0 {
+			namespace = full[:i]
+		}
+
+		switch s.typ {
+		case "record":
+			fields, _ := m["fields"].([]any)
+			for _, f := range fields {
+				fm, ok := f.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("avro schema: bad field in %s", name)
+				}
+				fname, _ := fm["name"].(string)
+				fs, err := p.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("avro schema: field %s.%s: %w", name, fname, err)
+				}
+				s.fields = append(s.fields, avroField{name: fname, schema: fs})
+			}
+		case "enum":
+			syms, _ := m["symbols"].([]any)
+			for _, sym := range syms {
+				str, _ := sym.(string)
+				s.symbols = append(s.symbols, str)
+			}
+		case "fixed":
+			size, _ := m["size"].(float64)
+			s.size = int(size)
+		}
+		return s, nil
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: "array", items: items}, nil
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: "map", items: values}, nil
+	}
+
+	// примитив в объектной форме, возможно с logicalType
+	s, err := p.parse(typ, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if logical != "" {
+		cp := *s
+		cp.logical = logical
+		s = &cp
+	}
+	return s, nil
+}
+
+func (p *avroParser) fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+type avroReader struct {
+	b []byte
+}
+
+func (r *avroReader) long() (int64, error) {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		return 0, errAvroShort
+	}
+	r.b = r.b[n:]
+	return v, nil
+}
+
+func (r *avroReader) take(n int) ([]byte, error) {
+	if n < 0 || n > len(r.b) {
+		return nil, errAvroShort
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out, nil
+}
+
+func (r *avroReader) bytes() ([]byte, error) {
+	n, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	return r.take(int(n))
+}
+
+func (s *avroSchema) decode(r *avroReader) (any, error) {
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case "int", "long":
+		v, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		switch s.logical {
+		case "timestamp-millis":
+			return time.UnixMilli(v).UTC(), nil
+		case "timestamp-micros":
+			return time.UnixMicro(v).UTC(), nil
+		}
+		return v, nil
+	case "float":
+		b, err := r.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case "double":
+		b, err := r.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "bytes":
+		return r.bytes()
+	case "string":
+		b, err := r.bytes()
+		return string(b), err
+	case "fixed":
+		return r.take(s.size)
+	case "enum":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.symbols) {
+			return nil, fmt.Errorf("avro: enum index %d out of range", i)
+		}
+		return s.symbols[i], nil
+	case "union":
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(s.branches) {
+			return nil, fmt.Errorf("avro: union index %d out of range", i)
+		}
+		return s.branches[i].decode(r)
+	case "record":
+		out := make(map[string]any, len(s.fields))
+		for _, f := range s.fields {
+			v, err := f.schema.decode(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+			out[f.name] = v
+		}
+		return out, nil
+	case "array":
+		var out []any
+		err := r.blocks(func() error {
+			v, err := s.items.decode(r)
+			out = append(out, v)
+			return err
+		})
+		return out, err
+	case "map":
+		out := map[string]any{}
+		err := r.blocks(func() error {
+			k, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			v, err := s.items.decode(r)
+			out[string(k)] = v
+			return err
+		})
+		return out, err
+	}
+	return nil, fmt.Errorf("avro: unsupported type %q", s.typ)
+}
+
+// blocks читает блоки array/map: count, элементы, ..., 0. Отрицательный
+// count означает, что за ним идёт размер блока в байтах.
+func (r *avroReader) blocks(item func() error) error {
+	for {
+		n, err := r.long()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+			if _, err := r.long(); err != nil {
+				return err
+			}
+		}
+		if n > int64(len(r.b)) {
+			return errAvroShort
+		}
+		for i := int64(0); i < n; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decodeAvroInto разбирает data по схеме и раскладывает результат в dst по JSON-тегам.
+func decodeAvroInto(s *avroSchema, data []byte, dst any) error {
+	r := &avroReader{b: data}
+	v, err := s.decode(r)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("avro: %w", err)
+	}
+	return json.Unmarshal(raw, dst)
+}
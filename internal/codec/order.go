@@ -0,0 +1,175 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/schemaregistry"
+)
+
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatProtobuf Format = "protobuf"
+	FormatAvro     Format = "avro"
+)
+
+// ContentTypeHeader — заголовок Kafka-сообщения с форматом полезной нагрузки.
+const ContentTypeHeader = "content-type"
+
+var contentTypes = map[string]Format{
+	"application/json":                   FormatJSON,
+	"json":                               FormatJSON,
+	"application/x-protobuf":             FormatProtobuf,
+	"application/protobuf":               FormatProtobuf,
+	"application/vnd.google.protobuf":    FormatProtobuf,
+	"protobuf":                           FormatProtobuf,
+	"avro/binary":                        FormatAvro,
+	"application/avro":                   FormatAvro,
+	"application/vnd.apache.avro+binary": FormatAvro,
+	"avro":                               FormatAvro,
+}
+
+// ParseContentType понимает MIME-типы с параметрами ("application/json; charset=utf-8").
+func ParseContentType(ct string) (Format, error) {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		mt = strings.TrimSpace(ct)
+	}
+	if f, ok := contentTypes[strings.ToLower(mt)]; ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported content type %q", ct)
+}
+
+// Confluent wire format: 0x00, ID схемы (4 байта big-endian), данные.
+const magicByte = 0
+
+// ParseWireFormat отделяет ID схемы от данных; ok=false — это не wire format.
+func ParseWireFormat(data []byte) (schemaID int, payload []byte, ok bool) {
+	if len(data) < 5 || data[0] != magicByte {
+		return 0, nil, false
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], true
+}
+
+// WireFormat оборачивает данные так же, как сериализаторы Confluent
+// (для Protobuf перед данными ещё должны идти индексы сообщения, см. skipMessageIndexes).
+func WireFormat(schemaID int, payload []byte) []byte {
+	out := make([]byte, 5, 5+len(payload))
+	out[0] = magicByte
+	binary.BigEndian.PutUint32(out[1:], uint32(schemaID))
+	return append(out, payload...)
+}
+
+// skipMessageIndexes пропускает индексы сообщения в .proto-файле схемы:
+// zigzag-varint с их количеством и сами индексы; один байт 0 означает [0].
+func skipMessageIndexes(b []byte) ([]byte, error) {
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return nil, fmt.Errorf("protobuf message indexes: %w", protowire.ParseError(n))
+	}
+	b = b[n:]
+	for count := protowire.DecodeZigZag(v); count > 0; count-- {
+		if _, n = protowire.ConsumeVarint(b); n < 0 {
+			return nil, fmt.Errorf("protobuf message indexes: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return b, nil
+}
+
+// SchemaSource — откуда брать схемы по ID (schemaregistry.Client).
+type SchemaSource interface {
+	SchemaByID(ctx context.Context, id int) (*schemaregistry.Schema, error)
+}
+
+// OrderDecoder разбирает снимок заказа в JSON, Protobuf или Avro. Формат
+// берётся из Confluent wire format (по типу схемы в реестре), затем из
+// заголовка content-type; без заголовка ожидается JSON.
+type OrderDecoder struct {
+	registry SchemaSource
+
+	mu   sync.Mutex
+	avro map[int]*avroSchema // разобранные схемы по ID
+}
+
+// NewOrderDecoder: registry может быть nil — тогда wire format не поддерживается.
+func NewOrderDecoder(registry SchemaSource) *OrderDecoder {
+	return &OrderDecoder{registry: registry, avro: map[int]*avroSchema{}}
+}
+
+func (d *OrderDecoder) Decode(ctx context.Context, contentType string, data []byte) (models.Order, error) {
+	var order models.Order
+
+	if id, payload, ok := ParseWireFormat(data); ok {
+		if d.registry == nil {
+			return order, fmt.Errorf("schema id %d: schema registry is not configured", id)
+		}
+		schema, err := d.registry.SchemaByID(ctx, id)
+		if err != nil {
+			return order, err
+		}
+		switch schema.Type {
+		case schemaregistry.TypeAvro:
+			s, err := d.avroSchema(schema)
+			if err != nil {
+				return order, err
+			}
+			err = decodeAvroInto(s, payload, &order)
+			return order, err
+		case schemaregistry.TypeProtobuf:
+			if payload, err = skipMessageIndexes(payload); err != nil {
+				return order, err
+			}
+			return UnmarshalOrderProto(payload)
+		case schemaregistry.TypeJSON:
+			err = json.Unmarshal(payload, &order)
+			return order, err
+		}
+		return order, fmt.Errorf("schema id %d: unsupported schema type %q", id, schema.Type)
+	}
+
+	format := FormatJSON
+	if contentType != "" {
+		var err error
+		if format, err = ParseContentType(contentType); err != nil {
+			return order, err
+		}
+	} else if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '{' {
+		return order, fmt.Errorf("payload is not JSON and has no %s header", ContentTypeHeader)
+	}
+
+	switch format {
+	case FormatProtobuf:
+		return UnmarshalOrderProto(data)
+	case FormatAvro:
+		return order, fmt.Errorf("avro payload without schema id (Confluent wire format is required)")
+	}
+	err := json.Unmarshal(data, &order)
+	return order, err
+}
+
+func (d *OrderDecoder) avroSchema(schema *schemaregistry.Schema) (*avroSchema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s, ok := d.avro[schema.ID]; ok {
+		return s, nil
+	}
+	s, err := parseAvroSchema(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("schema id %d: %w", schema.ID, err)
+	}
+	d.avro[schema.ID] = s
+	return s, nil
+}
@@ -0,0 +1,269 @@
+package codec
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"wb-test-task/internal/models"
+)
+
+// Кодек models.Order в Protobuf по схеме api/proto/order.proto.
+// Неизвестные поля пропускаются, как и в сгенерированном коде.
+
+// field — одно поле сообщения: varint-значение или байты вложенного/строкового поля.
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+func (f field) str() (string, error) {
+	if f.typ != protowire.BytesType {
+		return "", fmt.Errorf("field %d: expected length-delimited, got wire type %d", f.num, f.typ)
+	}
+	return string(f.bytes), nil
+}
+
+func (f field) int() (int, error) {
+	if f.typ != protowire.VarintType {
+		return 0, fmt.Errorf("field %d: expected varint, got wire type %d", f.num, f.typ)
+	}
+	return int(int64(f.varint)), nil
+}
+
+func (f field) msg() ([]byte, error) {
+	if f.typ != protowire.BytesType {
+		return nil, fmt.Errorf("field %d: expected message, got wire type %d", f.num, f.typ)
+	}
+	return f.bytes, nil
+}
+
+// walk перебирает поля сообщения в порядке следования.
+func walk(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setters сопоставляет номеру поля функцию, заполняющую значение.
+type setters map[protowire.Number]func(f field) error
+
+func strTo(dst *string) func(field) error {
+	return func(f field) (err error) { *dst, err = f.str(); return }
+}
+
+func intTo(dst *int) func(field) error {
+	return func(f field) (err error) { *dst, err = f.int(); return }
+}
+
+func int64To(dst *int64) func(field) error {
+	return func(f field) error {
+		v, err := f.int()
+		*dst = int64(v)
+		return err
+	}
+}
+
+func decodeWith(b []byte, s setters) error {
+	return walk(b, func(f field) error {
+		if set, ok := s[f.num]; ok {
+			return set(f)
+		}
+		return nil
+	})
+}
+
+// UnmarshalOrderProto разбирает wb.orders.v1.Order.
+func UnmarshalOrderProto(b []byte) (models.Order, error) {
+	var o models.Order
+	err := decodeWith(b, setters{
+		1: strTo(&o.OrderUID),
+		2: strTo(&o.TrackNumber),
+		3: strTo(&o.Entry),
+		4: nested(func(b []byte) error { return decodeDelivery(b, &o.Delivery) }),
+		5: nested(func(b []byte) error { return decodePayment(b, &o.Payment) }),
+		6: nested(func(b []byte) error {
+			var it models.Item
+			if err := decodeItem(b, &it); err != nil {
+				return err
+			}
+			o.Items = append(o.Items, it)
+			return nil
+		}),
+		7:  strTo(&o.Locale),
+		8:  strTo(&o.InternalSignature),
+		9:  strTo(&o.CustomerID),
+		10: strTo(&o.DeliveryService),
+		11: strTo(&o.ShardKey),
+		12: intTo(&o.SMID),
+		13: nested(func(b []byte) error { return decodeTimestamp(b, &o.DateCreated) }),
+		14: strTo(&o.OOFShard),
+	})
+	if err != nil {
+		return o, fmt.Errorf("protobuf order: %w", err)
+	}
+	return o, nil
+}
+
+func nested(fn func(b []byte) error) func(field) error {
+	return func(f field) error {
+		b, err := f.msg()
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	}
+}
+
+func decodeDelivery(b []byte, d *models.Delivery) error {
+	return decodeWith(b, setters{
+		1: strTo(&d.Name), 2: strTo(&d.Phone), 3: strTo(&d.Zip), 4: strTo(&d.City),
+		5: strTo(&d.Address), 6: strTo(&d.Region), 7: strTo(&d.Email),
+	})
+}
+
+func decodePayment(b []byte, p *models.Payment) error {
+	return decodeWith(b, setters{
+		1: strTo(&p.Transaction), 2: strTo(&p.RequestID), 3: strTo(&p.Currency), 4: strTo(&p.Provider),
+		5: intTo(&p.Amount), 6: int64To(&p.PaymentDT), 7: strTo(&p.Bank), 8: intTo(&p.DeliveryCost),
+		9: intTo(&p.GoodsTotal), 10: intTo(&p.CustomFee),
+	})
+}
+
+func decodeItem(b []byte, it *models.Item) error {
+	return decodeWith(b, setters{
+		1: intTo(&it.ChrtID), 2: strTo(&it.TrackNumber), 3: intTo(&it.Price), 4: strTo(&it.RID),
+		5: strTo(&it.Name), 6: intTo(&it.Sale), 7: strTo(&it.Size), 8: intTo(&it.TotalPrice),
+		9: intTo(&it.NMID), 10: strTo(&it.Brand), 11: intTo(&it.Status),
+	})
+}
+
+// decodeTimestamp разбирает google.protobuf.Timestamp.
+func decodeTimestamp(b []byte, t *time.Time) error {
+	var sec int64
+	var nanos int
+	if err := decodeWith(b, setters{1: int64To(&sec), 2: intTo(&nanos)}); err != nil {
+		return err
+	}
+	*t = time.Unix(sec, int64(nanos)).UTC()
+	return nil
+}
+
+// MarshalOrderProto кодирует заказ по той же схеме (для продюсеров и тестов).
+// Нулевые значения не пишутся, как принято в proto3.
+func MarshalOrderProto(o *models.Order) []byte {
+	var b []byte
+	b = appendString(b, 1, o.OrderUID)
+	b = appendString(b, 2, o.TrackNumber)
+	b = appendString(b, 3, o.Entry)
+	b = appendMessage(b, 4, encodeDelivery(&o.Delivery))
+	b = appendMessage(b, 5, encodePayment(&o.Payment))
+	for i := range o.Items {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeItem(&o.Items[i]))
+	}
+	b = appendString(b, 7, o.Locale)
+	b = appendString(b, 8, o.InternalSignature)
+	b = appendString(b, 9, o.CustomerID)
+	b = appendString(b, 10, o.DeliveryService)
+	b = appendString(b, 11, o.ShardKey)
+	b = appendInt(b, 12, int64(o.SMID))
+	if !o.DateCreated.IsZero() {
+		var ts []byte
+		ts = appendInt(ts, 1, o.DateCreated.Unix())
+		ts = appendInt(ts, 2, int64(o.DateCreated.Nanosecond()))
+		b = appendMessage(b, 13, ts)
+	}
+	b = appendString(b, 14, o.OOFShard)
+	return b
+}
+
+func encodeDelivery(d *models.Delivery) []byte {
+	var b []byte
+	for i, s := range []string{d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email} {
+		b = appendString(b, protowire.Number(i+1), s)
+	}
+	return b
+}
+
+func encodePayment(p *models.Payment) []byte {
+	var b []byte
+	b = appendString(b, 1, p.Transaction)
+	b = appendString(b, 2, p.RequestID)
+	b = appendString(b, 3, p.Currency)
+	b = appendString(b, 4, p.Provider)
+	b = appendInt(b, 5, int64(p.Amount))
+	b = appendInt(b, 6, p.PaymentDT)
+	b = appendString(b, 7, p.Bank)
+	b = appendInt(b, 8, int64(p.DeliveryCost))
+	b = appendInt(b, 9, int64(p.GoodsTotal))
+	b = appendInt(b, 10, int64(p.CustomFee))
+	return b
+}
+
+func encodeItem(it *models.Item) []byte {
+	var b []byte
+	b = appendInt(b, 1, int64(it.ChrtID))
+	b = appendString(b, 2, it.TrackNumber)
+	b = appendInt(b, 3, int64(it.Price))
+	b = appendString(b, 4, it.RID)
+	b = appendString(b, 5, it.Name)
+	b = appendInt(b, 6, int64(it.Sale))
+	b = appendString(b, 7, it.Size)
+	b = appendInt(b, 8, int64(it.TotalPrice))
+	b = appendInt(b, 9, int64(it.NMID))
+	b = appendString(b, 10, it.Brand)
+	b = appendInt(b, 11, int64(it.Status))
+	return b
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	if len(msg) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// orderColumns — заголовок заказа и связанные сущности, собранные в JSON на
+// стороне Postgres; невыбранные части отдаются NULL и не читаются.
+// Ожидает алиас o для public.orders.
+func orderColumns(parts ports.OrderParts) string {
+	delivery, payment, items := "NULL::jsonb", "NULL::jsonb", "NULL::jsonb"
+	if parts.Delivery {
+		delivery = "(SELECT to_jsonb(d) FROM public.deliveries d WHERE d.order_uid = o.order_uid)"
+	}
+	if parts.Payment {
+		payment = "(SELECT to_jsonb(p) FROM public.payments p WHERE p.order_uid = o.order_uid)"
+	}
+	if parts.Items {
+		items = "COALESCE((SELECT jsonb_agg(to_jsonb(i)) FROM public.items i WHERE i.order_uid = o.order_uid), '[]'::jsonb)"
+	}
+	return `
+            o.order_uid, o.track_number, o.entry, o.locale,
+            o.internal_signature, o.customer_id, o.delivery_service,
+            o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.updated_at,
+            ` + delivery + `, ` + payment + `, ` + items
+}
+
+// scanOrder читает строку, выбранную с orderColumns.
+func scanOrder(rows pgx.Rows) (*models.Order, error) {
+	var (
+		order                    models.Order
+		delivery, payment, items []byte
+	)
+	err := rows.Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+		&order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+		&order.UpdatedAt, &delivery, &payment, &items,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("scan order failed: %w", err)
+	}
+	// имена колонок совпадают с JSON-тегами моделей
+	if delivery != nil {
+		if err = json.Unmarshal(delivery, &order.Delivery); err != nil {
+			return nil, fmt.Errorf("decode delivery of %s: %w", order.OrderUID, err)
+		}
+	}
+	if payment != nil {
+		if err = json.Unmarshal(payment, &order.Payment); err != nil {
+			return nil, fmt.Errorf("decode payment of %s: %w", order.OrderUID, err)
+		}
+	}
+	if items != nil {
+		if err = json.Unmarshal(items, &order.Items); err != nil {
+			return nil, fmt.Errorf("decode items of %s: %w", order.OrderUID, err)
+		}
+	}
+	return &order, nil
+}
+
+// GetOrders читает заказы целиком одним запросом. Заказов, которых нет, в ответе нет.
+func (r *Repository) GetOrders(ctx context.Context, uids []string) (map[string]*models.Order, error) {
+	rows, err := r.pool.Query(ctx, `
+        SELECT`+orderColumns(ports.AllOrderParts)+`
+        FROM public.orders o
+        WHERE o.order_uid = ANY($1)`, uids)
+	if err != nil {
+		return nil, fmt.Errorf("select orders failed: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make(map[string]*models.Order, len(uids))
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders[order.OrderUID] = order
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+	return orders, nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SeenSince ищет ключ в журнале processed_messages (таблица — миграция
+// 0005_processed_messages.sql).
+func (r *Repository) SeenSince(ctx context.Context, key string, since time.Time) (bool, error) {
+	var seen bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM processed_messages WHERE key = $1 AND processed_at >= $2)`,
+		key, since).Scan(&seen)
+	if err != nil {
+		return false, fmt.Errorf("check processed message: %w", err)
+	}
+	return seen, nil
+}
+
+func (r *Repository) MarkProcessed(ctx context.Context, key string, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO processed_messages (key, processed_at) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET processed_at = EXCLUDED.processed_at`,
+		key, at)
+	if err != nil {
+		return fmt.Errorf("mark processed message: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) PurgeProcessed(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM processed_messages WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge processed messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
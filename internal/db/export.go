@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// exportFetchSize — сколько строк забирать из курсора за один FETCH.
+const exportFetchSize = 500
+
+// ExportOrders читает заказы через серверный курсор порциями по
+// exportFetchSize, так что в памяти одновременно не больше одной порции.
+func (r *Repository) ExportOrders(ctx context.Context, filter ports.OrderFilter, parts ports.OrderParts, fn func(*models.Order) error) error {
+	where, args := orderFilterSQL(filter)
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+        DECLARE orders_export NO SCROLL CURSOR FOR
+        SELECT`+orderColumns(parts)+`
+        FROM public.orders o`+where+`
+        ORDER BY o.date_created, o.order_uid`, args...)
+	if err != nil {
+		return fmt.Errorf("declare cursor failed: %w", err)
+	}
+
+	for {
+		n, err := fetchOrders(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// fetchOrders передаёт fn очередную порцию курсора и возвращает её размер.
+func fetchOrders(ctx context.Context, tx pgx.Tx, fn func(*models.Order) error) (int, error) {
+	rows, err := tx.Query(ctx, "FETCH FORWARD "+strconv.Itoa(exportFetchSize)+" FROM orders_export")
+	if err != nil {
+		return 0, fmt.Errorf("fetch orders failed: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return n, err
+		}
+		if err = fn(order); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		return n, fmt.Errorf("rows iteration failed: %w", err)
+	}
+	return n, nil
+}
+
+// orderFilterSQL строит WHERE по фильтру выгрузки.
+func orderFilterSQL(f ports.OrderFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.CustomerIDs) > 0 {
+		add("o.customer_id = ANY($%d)", f.CustomerIDs)
+	}
+	if len(f.DeliveryServices) > 0 {
+		add("o.delivery_service = ANY($%d)", f.DeliveryServices)
+	}
+	if len(f.Locales) > 0 {
+		add("o.locale = ANY($%d)", f.Locales)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("o.date_created >= $%d", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add("o.date_created < $%d", f.CreatedTo)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "\n        WHERE " + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-test-task/internal/models"
+)
+
+// OrdersChannel — канал NOTIFY, в который репозиторий пишет изменения заказов.
+const OrdersChannel = "orders_changed"
+
+type ChangeOp string
+
+const (
+	OrderUpserted ChangeOp = "upsert"
+	OrderDeleted  ChangeOp = "delete"
+)
+
+// OrderChange — полезная нагрузка уведомления. Summary есть только у
+// нового снимка заказа (SaveOrder) — по нему строится живая лента.
+type OrderChange struct {
+	Op      ChangeOp             `json:"op"`
+	UID     string               `json:"uid"`
+	Summary *models.OrderSummary `json:"summary,omitempty"`
+}
+
+func ParseOrderChange(payload string) (OrderChange, error) {
+	var ch OrderChange
+	if err := json.Unmarshal([]byte(payload), &ch); err != nil {
+		return ch, fmt.Errorf("parse order change: %w", err)
+	}
+	if ch.UID == "" || (ch.Op != OrderUpserted && ch.Op != OrderDeleted) {
+		return ch, fmt.Errorf("parse order change: bad payload %q", payload)
+	}
+	return ch, nil
+}
+
+// notifyOrderChange ставит уведомление в транзакцию: Postgres доставит его
+// слушателям только после COMMIT, а при откате не доставит вовсе.
+func notifyOrderChange(ctx context.Context, tx pgx.Tx, ch OrderChange) error {
+	payload, _ := json.Marshal(ch)
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, OrdersChannel, string(payload)); err != nil {
+		return fmt.Errorf("notify order change failed: %w", err)
+	}
+	return nil
+}
+
+// Listener держит отдельное соединение (не из пула) с LISTEN на OrdersChannel.
+// После каждого переподключения вызывается OnResync: уведомления, пришедшие
+// пока соединения не было, потеряны, и кэш нужно сверить с БД целиком.
+type Listener struct {
+	connString string
+	OnChange   func(ctx context.Context, ch OrderChange)
+	OnResync   func(ctx context.Context)
+}
+
+func NewListener(connString string, onChange func(context.Context, OrderChange), onResync func(context.Context)) *Listener {
+	return &Listener{connString: connString, OnChange: onChange, OnResync: onResync}
+}
+
+// Пауза перед переподключением растёт от listenBackoffMin до listenBackoffMax
+// и сбрасывается, как только LISTEN снова прошёл.
+const (
+	listenBackoffMin = 200 * time.Millisecond
+	listenBackoffMax = 10 * time.Second
+)
+
+func (l *Listener) Run(ctx context.Context) {
+	backoff := listenBackoffMin
+	connected := false
+	for {
+		listened, err := l.listen(ctx, connected)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			backoff = listenBackoffMin
+		}
+		connected = true
+		log.Printf("[db-listen] connection lost: %v (retry in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenBackoffMax)
+	}
+}
+
+// listen возвращает listened = true, если соединение дошло до LISTEN.
+func (l *Listener) listen(ctx context.Context, resync bool) (listened bool, err error) {
+	conn, err := pgx.Connect(ctx, l.connString)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{OrdersChannel}.Sanitize()); err != nil {
+		return false, err
+	}
+	log.Printf("[db-listen] listening on %q", OrdersChannel)
+
+	if resync && l.OnResync != nil {
+		l.OnResync(ctx)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		ch, err := ParseOrderChange(n.Payload)
+		if err != nil {
+			log.Printf("[db-listen] %v", err)
+			continue
+		}
+		if l.OnChange != nil {
+			l.OnChange(ctx, ch)
+		}
+	}
+}
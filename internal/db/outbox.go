@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// EventOrderStored — тип события в outbox после сохранения снимка заказа.
+const EventOrderStored = "order.stored"
+
+// EnableOutbox включает запись в outbox (таблица — миграция 0003_outbox.sql):
+// SaveOrder будет добавлять событие order.stored в той же транзакции.
+func (r *Repository) EnableOutbox() {
+	r.outbox = true
+}
+
+func insertOutbox(ctx context.Context, tx pgx.Tx, aggregateID, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal outbox payload: %w", err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`,
+		aggregateID, eventType, data)
+	if err != nil {
+		return fmt.Errorf("insert outbox failed: %w", err)
+	}
+	return nil
+}
+
+func orderStored(order models.Order) models.OrderStoredEvent {
+	return models.OrderStoredEvent{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+		DateCreated: order.DateCreated,
+		StoredAt:    time.Now().UTC(),
+	}
+}
+
+// ProcessOutbox держит выбранные строки под FOR UPDATE SKIP LOCKED до конца
+// транзакции, поэтому relay на нескольких репликах не отправляют одно и то же,
+// а следующая запись заказа не уйдёт раньше предыдущей.
+func (r *Repository) ProcessOutbox(ctx context.Context, limit int, publish func(context.Context, []ports.OutboxRecord) []error, retryAt func(int) time.Time) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT o.id, o.event_id::text, o.aggregate_id, o.event_type, o.payload, o.created_at, o.attempts
+		FROM outbox o
+		WHERE o.next_attempt_at <= now()
+		  AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.aggregate_id = o.aggregate_id AND p.id < o.id)
+		ORDER BY o.id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select outbox failed: %w", err)
+	}
+	recs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ports.OutboxRecord, error) {
+		var rec ports.OutboxRecord
+		err := row.Scan(&rec.ID, &rec.EventID, &rec.AggregateID, &rec.EventType, &rec.Payload, &rec.CreatedAt, &rec.Attempts)
+		return rec, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan outbox failed: %w", err)
+	}
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	errs := publish(ctx, recs)
+	var sent []int64
+	for i, rec := range recs {
+		if errs[i] == nil {
+			sent = append(sent, rec.ID)
+			continue
+		}
+		_, err = tx.Exec(ctx, `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1`,
+			rec.ID, retryAt(rec.Attempts+1), errs[i].Error())
+		if err != nil {
+			return 0, fmt.Errorf("reschedule outbox failed: %w", err)
+		}
+	}
+	if len(sent) > 0 {
+		if _, err = tx.Exec(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, sent); err != nil {
+			return 0, fmt.Errorf("delete sent outbox failed: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit outbox failed: %w", err)
+	}
+	return len(sent), nil
+}
@@ -2,22 +2,126 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 	"wb-test-task/config"
 	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Repository struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	outbox   bool // писать события в outbox, см. EnableOutbox
+	webhooks bool // ставить вебхуки в очередь, см. EnableWebhooks
+
+	// OnStored вызывается после коммита нового заказа на этом инстансе
+	// (живая лента без LISTEN/NOTIFY)
+	OnStored func(models.OrderSummary)
 }
 
-func NewPostgresPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)
+// ConnString возвращает DB_URL или собирает URL из отдельных полей; пароль
+// и прочие значения экранируются, так что пробелы и кавычки ничего не ломают.
+// Пути к клиентским сертификатам добавляются, если их нет в самом DSN.
+func ConnString(cfg *config.Config) string {
+	dsn := cfg.DBURL
+	if dsn == "" {
+		u := url.URL{
+			Scheme: "postgres",
+			User:   url.User(cfg.DBUser),
+			Host:   net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+			Path:   "/" + cfg.DBName,
+		}
+		if cfg.DBPassword != "" {
+			u.User = url.UserPassword(cfg.DBUser, cfg.DBPassword)
+		}
+		u.RawQuery = url.Values{"sslmode": {cfg.DBSSLMode}}.Encode()
+		dsn = u.String()
+	}
+	return withParams(dsn, [][2]string{
+		{"sslrootcert", cfg.DBSSLRootCert},
+		{"sslcert", cfg.DBSSLCert},
+		{"sslkey", cfg.DBSSLKey},
+	})
+}
 
-	pool, err := pgxpool.New(ctx, connStr)
+// withParams дописывает непустые параметры в DSN любого из двух форматов.
+func withParams(dsn string, params [][2]string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn // ошибку разбора покажет pgx
+		}
+		q := u.Query()
+		for _, p := range params {
+			if p[1] != "" && !q.Has(p[0]) {
+				q.Set(p[0], p[1])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	for _, p := range params {
+		if p[1] != "" && !strings.Contains(dsn, p[0]+"=") {
+			dsn += " " + p[0] + "='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p[1]) + "'"
+		}
+	}
+	return dsn
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// PoolConfig разбирает DSN и накладывает настройки пула из конфига.
+// simple_protocol и exec нужны за PgBouncer в transaction-режиме.
+func PoolConfig(cfg *config.Config) (*pgxpool.Config, error) {
+	pcfg, err := pgxpool.ParseConfig(ConnString(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("parse database config: %w", err)
+	}
+	if cfg.DBPoolMaxConns > 0 {
+		pcfg.MaxConns = int32(cfg.DBPoolMaxConns)
+	}
+	if cfg.DBPoolMinConns > 0 {
+		pcfg.MinConns = int32(cfg.DBPoolMinConns)
+	}
+	if cfg.DBPoolHealthCheckPeriod > 0 {
+		pcfg.HealthCheckPeriod = cfg.DBPoolHealthCheckPeriod
+	}
+	if cfg.DBPoolMaxConnLifetime > 0 {
+		pcfg.MaxConnLifetime = cfg.DBPoolMaxConnLifetime
+	}
+	if cfg.DBPoolMaxConnIdleTime > 0 {
+		pcfg.MaxConnIdleTime = cfg.DBPoolMaxConnIdleTime
+	}
+	if cfg.DBStatementCacheMode != "" {
+		mode, ok := queryExecModes[strings.ToLower(cfg.DBStatementCacheMode)]
+		if !ok {
+			return nil, fmt.Errorf("unknown statement cache mode %q", cfg.DBStatementCacheMode)
+		}
+		pcfg.ConnConfig.DefaultQueryExecMode = mode
+	}
+	return pcfg, nil
+}
+
+func NewPostgresPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	pcfg, err := PoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, pcfg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create connection pool: %w", err)
 	}
@@ -33,22 +137,46 @@ func NewRepository(pool *pgxpool.Pool) *Repository {
 	return &Repository{pool: pool}
 }
 
-func (r *Repository) SaveOrder(ctx context.Context, order models.Order) error {
+// ClassifyWriteError помечает ошибки записи, которые не исправит повтор:
+// нарушение уникальности — ports.ErrOrderExists, остальные нарушения
+// ограничений (класс 23) и ошибки данных (класс 22) — ports.ErrOrderRejected.
+func ClassifyWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch {
+	case pgErr.Code == "23505":
+		return fmt.Errorf("%w: %w", ports.ErrOrderExists, err)
+	case strings.HasPrefix(pgErr.Code, "23"), strings.HasPrefix(pgErr.Code, "22"):
+		return fmt.Errorf("%w: %w", ports.ErrOrderRejected, err)
+	}
+	return err
+}
+
+func (r *Repository) SaveOrder(ctx context.Context, order models.Order) (err error) {
+	defer func() { err = ClassifyWriteError(err) }()
+
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction failed: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	updatedAt := order.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now().UTC()
+	}
+
 	// Сохранение основной информации о заказе
 	_, err = tx.Exec(ctx, `
 		INSERT INTO orders (order_uid, track_number, entry, locale, 
 			internal_signature, customer_id, delivery_service, 
-			shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			shardkey, sm_id, date_created, oof_shard, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.ShardKey, order.SMID, order.DateCreated, order.OOFShard)
+		order.ShardKey, order.SMID, order.DateCreated, order.OOFShard, updatedAt)
 	if err != nil {
 		return fmt.Errorf("insert order failed: %w", err)
 	}
@@ -92,10 +220,138 @@ func (r *Repository) SaveOrder(ctx context.Context, order models.Order) error {
 		}
 	}
 
+	if r.outbox {
+		if err = insertOutbox(ctx, tx, order.OrderUID, EventOrderStored, orderStored(order)); err != nil {
+			return err
+		}
+	}
+	if r.webhooks {
+		if err = enqueueWebhooks(ctx, tx, EventOrderStored, orderStored(order)); err != nil {
+			return err
+		}
+	}
+
+	summary := models.NewOrderSummary(order)
+	if err = notifyOrderChange(ctx, tx, OrderChange{Op: OrderUpserted, UID: order.OrderUID, Summary: &summary}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if r.OnStored != nil {
+		r.OnStored(summary)
+	}
+	return nil
+}
+
+// DeleteOrder удаляет заказ со всеми дочерними записями и оповещает остальные инстансы.
+func (r *Repository) DeleteOrder(ctx context.Context, orderUID string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"items", "payments", "deliveries"} {
+		if _, err = tx.Exec(ctx, `DELETE FROM public.`+table+` WHERE order_uid = $1`, orderUID); err != nil {
+			return fmt.Errorf("delete %s failed: %w", table, err)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM public.orders WHERE order_uid = $1`, orderUID)
+	if err != nil {
+		return fmt.Errorf("delete order failed: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete order %s: %w", orderUID, ports.ErrOrderNotFound)
+	}
+
+	if r.webhooks {
+		event := models.OrderDeletedEvent{OrderUID: orderUID, DeletedAt: time.Now().UTC()}
+		if err = enqueueWebhooks(ctx, tx, EventOrderDeleted, event); err != nil {
+			return err
+		}
+	}
+
+	if err = notifyOrderChange(ctx, tx, OrderChange{Op: OrderDeleted, UID: orderUID}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UpdateItemsStatus меняет статус товаров заказа (всех, если chrtIDs пуст).
+func (r *Repository) UpdateItemsStatus(ctx context.Context, orderUID string, status int, chrtIDs []int) error {
+	event := models.OrderUpdatedEvent{OrderUID: orderUID, Status: &status, ChrtIDs: chrtIDs}
+	return r.updateOrder(ctx, event, func(tx pgx.Tx) (int64, error) {
+		tag, err := tx.Exec(ctx, `
+			UPDATE public.items SET status = $2
+			WHERE order_uid = $1 AND (cardinality($3::int[]) = 0 OR chrt_id = ANY($3))`,
+			orderUID, status, chrtIDs)
+		if err != nil {
+			return 0, fmt.Errorf("update items status failed: %w", err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
+
+// UpdateTrackNumber назначает трек-номер заказу и всем его товарам.
+func (r *Repository) UpdateTrackNumber(ctx context.Context, orderUID, trackNumber string) error {
+	event := models.OrderUpdatedEvent{OrderUID: orderUID, TrackNumber: trackNumber}
+	return r.updateOrder(ctx, event, func(tx pgx.Tx) (int64, error) {
+		tag, err := tx.Exec(ctx, `UPDATE public.orders SET track_number = $2 WHERE order_uid = $1`, orderUID, trackNumber)
+		if err != nil {
+			return 0, fmt.Errorf("update order track number failed: %w", err)
+		}
+		if _, err = tx.Exec(ctx, `UPDATE public.items SET track_number = $2 WHERE order_uid = $1`, orderUID, trackNumber); err != nil {
+			return 0, fmt.Errorf("update items track number failed: %w", err)
+		}
+		return tag.RowsAffected(), nil
+	})
+}
+
+// updateOrder выполняет update в транзакции, ставит event в очередь вебхуков
+// и оповещает остальные инстансы; ноль затронутых строк — заказа нет.
+func (r *Repository) updateOrder(ctx context.Context, event models.OrderUpdatedEvent, update func(pgx.Tx) (int64, error)) error {
+	orderUID := event.OrderUID
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	n, err := update(tx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("update order %s: %w", orderUID, ports.ErrOrderNotFound)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE public.orders SET updated_at = now() WHERE order_uid = $1`, orderUID); err != nil {
+		return fmt.Errorf("touch order failed: %w", err)
+	}
+
+	if r.webhooks {
+		event.UpdatedAt = time.Now().UTC()
+		if err = enqueueWebhooks(ctx, tx, EventOrderUpdated, event); err != nil {
+			return err
+		}
+	}
+
+	if err = notifyOrderChange(ctx, tx, OrderChange{Op: OrderUpserted, UID: orderUID}); err != nil {
+		return err
+	}
+
 	return tx.Commit(ctx)
 }
 
 func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	return r.GetOrderParts(ctx, orderUID, ports.AllOrderParts)
+}
+
+// GetOrderParts читает заголовок заказа и только запрошенные связанные сущности.
+func (r *Repository) GetOrderParts(ctx context.Context, orderUID string, parts ports.OrderParts) (*models.Order, error) {
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("begin transaction failed: %w", err)
@@ -109,40 +365,74 @@ func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*models.Ord
         SELECT 
             order_uid, track_number, entry, locale, 
             internal_signature, customer_id, delivery_service, 
-            shardkey, sm_id, date_created, oof_shard
+            shardkey, sm_id, date_created, oof_shard, updated_at
         FROM public.orders 
         WHERE order_uid = $1`, orderUID).
 		Scan(
 			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 			&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
 			&order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+			&order.UpdatedAt,
 		)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("select order %s: %w", orderUID, ports.ErrOrderNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("select order failed: %w", err)
 	}
 
 	// 2. Получаем информацию о доставке
-	err = tx.QueryRow(ctx, `
+	if parts.Delivery {
+		if err = selectDelivery(ctx, tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Получаем информацию об оплате
+	if parts.Payment {
+		if err = selectPayment(ctx, tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Получаем товары в заказе
+	if parts.Items {
+		if err = selectItems(ctx, tx, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction failed: %w", err)
+	}
+
+	return &order, nil
+}
+
+func selectDelivery(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	err := tx.QueryRow(ctx, `
         SELECT 
             name, phone, zip, city, address, region, email
         FROM public.deliveries 
-        WHERE order_uid = $1`, orderUID).
+        WHERE order_uid = $1`, order.OrderUID).
 		Scan(
 			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
 			&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
 			&order.Delivery.Email,
 		)
 	if err != nil {
-		return nil, fmt.Errorf("select delivery failed: %w", err)
+		return fmt.Errorf("select delivery failed: %w", err)
 	}
+	return nil
+}
 
-	// 3. Получаем информацию об оплате
-	err = tx.QueryRow(ctx, `
+func selectPayment(ctx context.Context, tx pgx.Tx, order *models.Order) error {
+	err := tx.QueryRow(ctx, `
         SELECT 
             transaction, request_id, currency, provider, amount, 
             payment_dt, bank, delivery_cost, goods_total, custom_fee
         FROM public.payments 
-        WHERE order_uid = $1`, orderUID).
+        WHERE order_uid = $1`, order.OrderUID).
 		Scan(
 			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
 			&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDT,
@@ -150,18 +440,20 @@ func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*models.Ord
 			&order.Payment.CustomFee,
 		)
 	if err != nil {
-		return nil, fmt.Errorf("select payment failed: %w", err)
+		return fmt.Errorf("select payment failed: %w", err)
 	}
+	return nil
+}
 
-	// 4. Получаем товары в заказе
+func selectItems(ctx context.Context, tx pgx.Tx, order *models.Order) error {
 	rows, err := tx.Query(ctx, `
         SELECT 
             chrt_id, track_number, price, rid, name, 
             sale, size, total_price, nm_id, brand, status
         FROM public.items 
-        WHERE order_uid = $1`, orderUID)
+        WHERE order_uid = $1`, order.OrderUID)
 	if err != nil {
-		return nil, fmt.Errorf("select items failed: %w", err)
+		return fmt.Errorf("select items failed: %w", err)
 	}
 	defer rows.Close()
 
@@ -174,20 +466,15 @@ func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*models.Ord
 			&item.NMID, &item.Brand, &item.Status,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("scan item failed: %w", err)
+			return fmt.Errorf("scan item failed: %w", err)
 		}
 		order.Items = append(order.Items, item)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows iteration failed: %w", err)
-	}
-
-	if err = tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("commit transaction failed: %w", err)
+		return fmt.Errorf("rows iteration failed: %w", err)
 	}
-
-	return &order, nil
+	return nil
 }
 
 // Получить все заказы из БД
@@ -204,7 +491,7 @@ func (r *Repository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 		SELECT 
             order_uid, track_number, entry, locale, 
             internal_signature, customer_id, delivery_service, 
-            shardkey, sm_id, date_created, oof_shard
+            shardkey, sm_id, date_created, oof_shard, updated_at
         FROM public.orders `)
 
 	if err != nil {
@@ -217,6 +504,7 @@ func (r *Repository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 			&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
 			&order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
+			&order.UpdatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan order failed: %w", err)
 		}
@@ -234,3 +522,37 @@ func (r *Repository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 
 	return orders, nil
 }
+
+// GetOrderUIDs возвращает UID заказов, созданных не раньше since, от новых к старым.
+// limit <= 0 означает «без ограничения».
+func (r *Repository) GetOrderUIDs(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	var lim *int
+	if limit > 0 {
+		lim = &limit
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT order_uid
+		FROM public.orders
+		WHERE date_created >= $1
+		ORDER BY date_created DESC
+		LIMIT $2`, since, lim)
+	if err != nil {
+		return nil, fmt.Errorf("select order uids failed: %w", err)
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err = rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("scan order uid failed: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration failed: %w", err)
+	}
+	return uids, nil
+}
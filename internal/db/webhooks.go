@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// Типы событий для вебхуков; order.stored тот же, что и в outbox.
+const (
+	EventOrderUpdated = "order.updated"
+	EventOrderDeleted = "order.deleted"
+)
+
+// EnableWebhooks включает постановку вебхуков в очередь (таблицы — миграция
+// 0004_webhooks.sql): изменения заказа добавляют по доставке на каждую
+// подходящую подписку в той же транзакции.
+func (r *Repository) EnableWebhooks() {
+	r.webhooks = true
+}
+
+// enqueueWebhooks ставит событие в очередь всем активным подпискам на этот тип;
+// event_id общий, чтобы получатель мог сопоставить доставки одного события.
+func enqueueWebhooks(ctx context.Context, tx pgx.Tx, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		WITH e AS (SELECT gen_random_uuid() AS id)
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT s.id, e.id, $1, $2 FROM webhook_subscriptions s, e
+		WHERE s.active AND $1 = ANY (s.event_types)`, eventType, data)
+	if err != nil {
+		return fmt.Errorf("enqueue webhooks failed: %w", err)
+	}
+	return nil
+}
+
+const subscriptionColumns = `id, url, event_types, secret, active, created_at, updated_at`
+
+func scanSubscription(row pgx.Row) (models.WebhookSubscription, error) {
+	var s models.WebhookSubscription
+	err := row.Scan(&s.ID, &s.URL, &s.EventTypes, &s.Secret, &s.Active, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+func (r *Repository) CreateSubscription(ctx context.Context, s *models.WebhookSubscription) error {
+	row := r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (url, event_types, secret, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+subscriptionColumns, s.URL, s.EventTypes, s.Secret, s.Active)
+	created, err := scanSubscription(row)
+	if err != nil {
+		return fmt.Errorf("create webhook subscription: %w", err)
+	}
+	*s = created
+	return nil
+}
+
+func (r *Repository) Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookSubscription, error) {
+		return scanSubscription(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func (r *Repository) Subscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	s, err := scanSubscription(r.pool.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("webhook subscription %d: %w", id, ports.ErrSubscriptionNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *Repository) UpdateSubscription(ctx context.Context, s *models.WebhookSubscription) error {
+	row := r.pool.QueryRow(ctx, `
+		UPDATE webhook_subscriptions
+		SET url = $2, event_types = $3, secret = $4, active = $5, updated_at = now()
+		WHERE id = $1
+		RETURNING `+subscriptionColumns, s.ID, s.URL, s.EventTypes, s.Secret, s.Active)
+	updated, err := scanSubscription(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("webhook subscription %d: %w", s.ID, ports.ErrSubscriptionNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("update webhook subscription: %w", err)
+	}
+	*s = updated
+	return nil
+}
+
+// DeleteSubscription удаляет подписку вместе с её журналом доставок.
+func (r *Repository) DeleteSubscription(ctx context.Context, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook subscription %d: %w", id, ports.ErrSubscriptionNotFound)
+	}
+	return nil
+}
+
+func (r *Repository) Deliveries(ctx context.Context, subscriptionID int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, subscription_id, event_id::text, event_type, payload, status, attempts,
+			COALESCE(last_status_code, 0), COALESCE(last_error, ''), next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	ds, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		var d models.WebhookDelivery
+		err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	return ds, nil
+}
+
+func (r *Repository) Redeliver(ctx context.Context, subscriptionID, deliveryID int64) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $1 AND subscription_id = $2`, deliveryID, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("redeliver webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook delivery %d of subscription %d: %w", deliveryID, subscriptionID, ports.ErrDeliveryNotFound)
+	}
+	return nil
+}
+
+// ProcessDeliveries, как и ProcessOutbox, держит строки под FOR UPDATE SKIP LOCKED,
+// так что реплики не шлют одну доставку дважды. Доставки выключенных подписок
+// ждут, пока подписку не включат.
+func (r *Repository) ProcessDeliveries(ctx context.Context, limit int, send func(context.Context, []models.WebhookDelivery) []ports.WebhookAttempt) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction failed: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT d.id, d.subscription_id, d.event_id::text, d.event_type, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.created_at, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND s.active
+		ORDER BY d.id
+		LIMIT $1
+		FOR UPDATE OF d SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select webhook deliveries failed: %w", err)
+	}
+	ds, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookDelivery, error) {
+		var d models.WebhookDelivery
+		err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.CreatedAt, &d.URL, &d.Secret)
+		return d, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan webhook deliveries failed: %w", err)
+	}
+	if len(ds) == 0 {
+		return 0, nil
+	}
+
+	results := send(ctx, ds)
+	delivered := 0
+	for i, d := range ds {
+		res := results[i]
+		attempts := 0
+		if res.Counted {
+			attempts = 1
+		}
+		if res.Status == models.WebhookDelivered {
+			delivered++
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $2, attempts = attempts + $3,
+				last_status_code = COALESCE(NULLIF($4, 0), last_status_code),
+				last_error = CASE WHEN $3 = 0 THEN last_error ELSE NULLIF($5, '') END,
+				next_attempt_at = $6,
+				delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+			WHERE id = $1`, d.ID, res.Status, attempts, res.StatusCode, res.Error, res.NextAttemptAt)
+		if err != nil {
+			return 0, fmt.Errorf("update webhook delivery failed: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit webhook deliveries failed: %w", err)
+	}
+	return delivered, nil
+}
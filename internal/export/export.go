@@ -0,0 +1,281 @@
+// Package export выгружает заказы в CSV или NDJSON потоком, без буферизации
+// всей выборки: строки пишутся по мере чтения из курсора.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/service"
+)
+
+// Форматы и раскладки выгрузки.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	LayoutOrders = "orders" // строка на заказ, без товаров
+	LayoutItems  = "items"  // строка на товар, поля заказа повторяются
+)
+
+// Options — что и как выгружать. Columns — пути вида order_uid, delivery.city,
+// items.price; пусто — все доступные в раскладке. Для NDJSON колонки
+// работают как ?fields=, а раскладка не применяется.
+type Options struct {
+	Format  string
+	Layout  string
+	Columns []string
+	// FlushEvery — сбрасывать вывод каждые N заказов (0 — только в конце);
+	// если приёмник умеет Flush (http.ResponseWriter), сбрасывается и он.
+	FlushEvery int
+}
+
+type column struct {
+	name string
+	part string // "", delivery, payment или items
+	path []int  // индексы полей: в Order, а для товаров — в Item
+}
+
+// columns — все колонки в порядке полей models.Order.
+var columns = orderColumns()
+
+func orderColumns() []column {
+	var out []column
+	t := reflect.TypeOf(models.Order{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == reflect.TypeOf(time.Time{}) {
+			out = append(out, column{name: name, path: []int{i}})
+			continue
+		}
+		for j := 0; j < ft.NumField(); j++ {
+			if sub := jsonName(ft.Field(j)); sub != "" {
+				path := []int{i, j}
+				if f.Type.Kind() == reflect.Slice {
+					path = []int{j}
+				}
+				out = append(out, column{name: name + "." + sub, part: name, path: path})
+			}
+		}
+	}
+	return out
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func (c column) value(o *models.Order, item *models.Item) string {
+	v := reflect.ValueOf(o).Elem()
+	if c.part == "items" {
+		v = reflect.ValueOf(item).Elem()
+	}
+	v = v.FieldByIndex(c.path)
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Writer пишет заказы в выбранном формате.
+type Writer struct {
+	out        io.Writer
+	buf        *bufio.Writer
+	csv        *csv.Writer
+	cols       []column
+	items      bool
+	sel        service.Selection
+	parts      ports.OrderParts
+	flushEvery int
+	written    int
+}
+
+// NewWriter проверяет параметры; для CSV сразу пишет строку заголовка.
+func NewWriter(w io.Writer, opts Options) (*Writer, error) {
+	ew := &Writer{out: w, buf: bufio.NewWriter(w), flushEvery: opts.FlushEvery}
+	switch opts.Format {
+	case FormatNDJSON:
+		if opts.Layout != "" {
+			return nil, errors.New("layout applies to csv only")
+		}
+		sel, err := service.ParseSelection(opts.Columns, nil)
+		if err != nil {
+			return nil, err
+		}
+		ew.sel, ew.parts = sel, sel.Parts()
+		return ew, nil
+	case FormatCSV:
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected csv or ndjson", opts.Format)
+	}
+
+	switch opts.Layout {
+	case "", LayoutOrders:
+	case LayoutItems:
+		ew.items = true
+	default:
+		return nil, fmt.Errorf("unknown layout %q, expected orders or items", opts.Layout)
+	}
+	cols, err := ew.pick(opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+	ew.cols = cols
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.name
+		switch c.part {
+		case "delivery":
+			ew.parts.Delivery = true
+		case "payment":
+			ew.parts.Payment = true
+		}
+	}
+	ew.parts.Items = ew.items
+	ew.csv = csv.NewWriter(ew.buf)
+	if err := ew.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+func (w *Writer) pick(names []string) ([]column, error) {
+	var avail []column
+	for _, c := range columns {
+		if c.part != "items" || w.items {
+			avail = append(avail, c)
+		}
+	}
+	if len(names) == 0 {
+		return avail, nil
+	}
+	out := make([]column, 0, len(names))
+	for _, name := range names {
+		i := indexOf(avail, name)
+		if i < 0 {
+			if strings.HasPrefix(name, "items.") {
+				return nil, fmt.Errorf("column %q needs layout=items", name)
+			}
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		out = append(out, avail[i])
+	}
+	return out, nil
+}
+
+func indexOf(cols []column, name string) int {
+	for i, c := range cols {
+		if c.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parts — какие связанные сущности нужно читать из БД.
+func (w *Writer) Parts() ports.OrderParts { return w.parts }
+
+// ContentType — MIME-тип выгрузки.
+func (w *Writer) ContentType() string {
+	if w.csv != nil {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Write добавляет заказ: одну строку, а в раскладке items — строку на товар.
+func (w *Writer) Write(o *models.Order) error {
+	if err := w.write(o); err != nil {
+		return err
+	}
+	w.written++
+	if w.flushEvery > 0 && w.written%w.flushEvery == 0 {
+		return w.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) write(o *models.Order) error {
+	if w.csv == nil {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		if !w.sel.Empty() {
+			if b, err = w.sel.Project(b); err != nil {
+				return err
+			}
+		}
+		if _, err = w.buf.Write(b); err != nil {
+			return err
+		}
+		return w.buf.WriteByte('\n')
+	}
+
+	if !w.items {
+		return w.csv.Write(w.row(o, nil))
+	}
+	for i := range o.Items {
+		if err := w.csv.Write(w.row(o, &o.Items[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) row(o *models.Order, item *models.Item) []string {
+	rec := make([]string, len(w.cols))
+	for i, c := range w.cols {
+		rec[i] = c.value(o, item)
+	}
+	return rec
+}
+
+// Flush отправляет накопленное в приёмник.
+func (w *Writer) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := w.out.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Run выгружает все заказы под фильтр и возвращает их число. При ошибке
+// буфер не сбрасывается, так что хвост последней порции до приёмника не доходит.
+func Run(ctx context.Context, src ports.OrderExporter, filter ports.OrderFilter, w *Writer) (int, error) {
+	if err := src.ExportOrders(ctx, filter, w.Parts(), w.Write); err != nil {
+		return w.written, err
+	}
+	return w.written, w.Flush()
+}
@@ -0,0 +1,166 @@
+package feed
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"wb-test-task/internal/models"
+)
+
+// ErrTooManyClients — достигнут предел одновременных подписчиков.
+var ErrTooManyClients = errors.New("too many stream clients")
+
+// Event — запись ленты; ID растёт на единицу с каждым заказом и сбрасывается при перезапуске.
+type Event struct {
+	ID      uint64
+	Summary models.OrderSummary
+}
+
+// Filter отбирает заказы по полям сводки; пустой список — без ограничения,
+// несколько значений — любое из них.
+type Filter struct {
+	CustomerIDs      []string
+	DeliveryServices []string
+	Locales          []string
+}
+
+func (f Filter) Match(s models.OrderSummary) bool {
+	return matchAny(f.CustomerIDs, s.CustomerID) &&
+		matchAny(f.DeliveryServices, s.DeliveryService) &&
+		matchAny(f.Locales, s.Locale)
+}
+
+func matchAny(values []string, v string) bool {
+	return len(values) == 0 || slices.Contains(values, v)
+}
+
+// Subscription — подписчик ленты. Канал C закрывается при Unsubscribe или
+// когда подписчик не успевает читать; во втором случае Lagged возвращает true.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	lagged bool // под Hub.mu
+	hub    *Hub
+}
+
+// Lagged сообщает, что подписчик отключён из-за переполнения буфера.
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+// Hub раздаёт сводки новых заказов подписчикам и хранит последние size
+// событий для продолжения по Last-Event-ID. Publish никогда не ждёт: медленный
+// подписчик, у которого заполнился буфер, отключается и переподключается
+// с последнего полученного ID, пока событие ещё есть в кольце.
+type Hub struct {
+	mu           sync.Mutex
+	ring         []Event
+	next         int // куда писать следующее событие
+	seq          uint64
+	subs         map[*Subscription]struct{}
+	clientBuffer int
+	maxClients   int
+}
+
+// NewHub: maxClients <= 0 — без ограничения.
+func NewHub(size, clientBuffer, maxClients int) *Hub {
+	return &Hub{
+		ring:         make([]Event, 0, max(size, 1)),
+		subs:         map[*Subscription]struct{}{},
+		clientBuffer: max(clientBuffer, 1),
+		maxClients:   maxClients,
+	}
+}
+
+func (h *Hub) Publish(s models.OrderSummary) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	ev := Event{ID: h.seq, Summary: s}
+	if len(h.ring) < cap(h.ring) {
+		h.ring = append(h.ring, ev)
+	} else {
+		h.ring[h.next] = ev
+	}
+	h.next = (h.next + 1) % cap(h.ring)
+
+	for sub := range h.subs {
+		if !sub.filter.Match(s) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.lagged = true
+			h.drop(sub)
+		}
+	}
+	return ev
+}
+
+// Subscribe регистрирует подписчика и возвращает события из кольца после
+// lastID (если resume) — атомарно, так что между ними ничего не теряется.
+// gap = true, если часть событий после lastID уже вытеснена из кольца или
+// lastID из прошлой жизни сервиса: тогда отдаётся всё кольцо.
+func (h *Hub) Subscribe(filter Filter, lastID uint64, resume bool) (sub *Subscription, replay []Event, gap bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxClients > 0 && len(h.subs) >= h.maxClients {
+		return nil, nil, false, ErrTooManyClients
+	}
+	ch := make(chan Event, h.clientBuffer)
+	sub = &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+	h.subs[sub] = struct{}{}
+	if !resume {
+		return sub, nil, false, nil
+	}
+
+	events := h.ordered()
+	oldest := h.seq + 1
+	if len(events) > 0 {
+		oldest = events[0].ID
+	}
+	from := lastID + 1
+	if lastID > h.seq || from < oldest {
+		gap = true
+		from = oldest
+	}
+	for _, ev := range events {
+		if ev.ID >= from && filter.Match(ev.Summary) {
+			replay = append(replay, ev)
+		}
+	}
+	return sub, replay, gap, nil
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// Clients возвращает число подключённых подписчиков.
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// ordered возвращает содержимое кольца от старых к новым.
+func (h *Hub) ordered() []Event {
+	if len(h.ring) < cap(h.ring) {
+		return slices.Clone(h.ring)
+	}
+	return append(slices.Clone(h.ring[h.next:]), h.ring[:h.next]...)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"wb-test-task/config"
+	"wb-test-task/internal/tuning"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	tuner *tuning.Tuner
+}
+
+func NewAdminHandler(tuner *tuning.Tuner) *AdminHandler {
+	return &AdminHandler{tuner: tuner}
+}
+
+// GetRuntime показывает текущие значения настроек, которые меняются без перезапуска.
+func (h *AdminHandler) GetRuntime(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"settings":         h.tuner.Settings(),
+		"restart_required": nonNil(h.tuner.Pending()),
+	})
+}
+
+// PatchRuntime принимает {"cache.capacity": 5000, "logging.level": "debug", ...}.
+// Ключи без поддержки горячей замены не применяются и возвращаются в restart_required.
+func (h *AdminHandler) PatchRuntime(c *gin.Context) {
+	dec := json.NewDecoder(c.Request.Body)
+	dec.UseNumber()
+	var body map[string]any
+	if err := dec.Decode(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	changes := make(map[string]string, len(body))
+	for k, v := range body {
+		changes[k] = fmt.Sprint(v)
+	}
+
+	res, err := h.tuner.Update(changes)
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": verr.Problems})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"applied":          nonNil(res.Applied),
+		"restart_required": nonNil(res.RestartRequired),
+		"settings":         h.tuner.Settings(),
+	})
+}
+
+// RequireToken пускает только запросы с заголовком Authorization: Bearer <token>.
+// Пустой token отключает проверку.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			return
+		}
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
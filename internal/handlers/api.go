@@ -3,17 +3,22 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	"wb-test-task/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type OrderHandler struct {
-	svc *service.OrderService
+	svc          *service.OrderService
+	cacheControl string
 }
 
-func NewOrderHandler(svc *service.OrderService) *OrderHandler {
-	return &OrderHandler{svc: svc}
+// NewOrderHandler: cacheControl ставится в Cache-Control успешных ответов (пусто — без заголовка).
+func NewOrderHandler(svc *service.OrderService, cacheControl string) *OrderHandler {
+	return &OrderHandler{svc: svc, cacheControl: cacheControl}
 }
 
 // Хендлер получения заказа по UID
@@ -22,14 +27,25 @@ func (h *OrderHandler) GetOrderByUID(c *gin.Context) {
 
 	uid := c.Param("orderId")
 
-	order, err := h.svc.GetOrderByUID(ctx, uid)
+	// ?fields= и ?include= урезают ответ; без них отдаётся заказ целиком
+	sel, err := service.ParseSelection(queryList(c, "fields"), queryList(c, "include"))
+	if err != nil {
+		respondWithJSON(c.Writer, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.svc.GetOrderSelected(ctx, uid, sel)
 	if err != nil { // Если возникла ошибка, возвращаем HTTP статус 404 и сообщение об ошибке
+		c.Header("Cache-Control", "no-store")
 		respondWithJSON(c.Writer, http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Если все хорошо, возвращаем HTTP статус 200 и заказ
-	respondWithJSON(c.Writer, http.StatusOK, order)
+	// Если все хорошо, возвращаем HTTP статус 200 (или 304) и готовые байты заказа
+	if h.cacheControl != "" {
+		c.Header("Cache-Control", h.cacheControl)
+	}
+	respondWithEncoded(c, http.StatusOK, order)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -37,3 +53,85 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(payload)
 }
+
+// respondWithEncoded отдаёт предсериализованный ответ одной записью,
+// сжатую версию — если клиент её принимает и она есть в кэше. На условный
+// запрос с совпавшим If-None-Match или If-Modified-Since отвечает 304 без тела.
+func respondWithEncoded(c *gin.Context, code int, e *service.EncodedOrder) {
+	w := c.Writer
+	h := w.Header()
+
+	body, etag := e.JSON, e.ETag
+	if e.Gzip != nil {
+		h.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			h.Set("Content-Encoding", "gzip")
+			body, etag = e.Gzip, gzipETag(e.ETag)
+		}
+	}
+	// сильный ETag различает представления, поэтому у сжатого свой
+	h.Set("ETag", etag)
+	var modified time.Time
+	if e.Value != nil {
+		modified = e.Value.UpdatedAt
+	}
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if code == http.StatusOK && notModified(c.Request, e.ETag, modified) {
+		h.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		w.WriteHeaderNow()
+		return
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// notModified проверяет условия запроса по RFC 9110: If-None-Match важнее
+// If-Modified-Since, а время сравнивается с точностью до секунды.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// etagMatches — слабое сравнение для If-None-Match: W/ не учитывается,
+// сжатый и несжатый варианты одного заказа считаются одним и тем же.
+func etagMatches(header, etag string) bool {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimPrefix(strings.TrimSpace(part), "W/")
+		if tag == "*" || tag == etag || tag == gzipETag(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func gzipETag(etag string) string {
+	return strings.TrimSuffix(etag, `"`) + `-gzip"`
+}
+
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), "gzip") {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Статусы заказа в ответе пакетного чтения.
+const (
+	BatchFound    = "found"
+	BatchNotFound = "not_found"
+	BatchError    = "error"
+)
+
+// ndjsonType — формат построчной выдачи: один JSON-объект на строку.
+const ndjsonType = "application/x-ndjson"
+
+// OrderMethods — нестандартные методы коллекции заказов (/api/v1/orders:<метод>).
+var OrderMethods = []string{"batchGet"}
+
+type BatchHandler struct {
+	svc     *service.OrderService
+	maxUIDs int
+}
+
+func NewBatchHandler(svc *service.OrderService, maxUIDs int) *BatchHandler {
+	return &BatchHandler{svc: svc, maxUIDs: maxUIDs}
+}
+
+type batchGetRequest struct {
+	UIDs []string `json:"uids"`
+}
+
+type batchItem struct {
+	OrderUID string          `json:"order_uid"`
+	Status   string          `json:"status"`
+	Order    json.RawMessage `json:"order,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// OrderMethod разбирает /orders:<метод>: gin не умеет статический суффикс
+// после двоеточия, поэтому метод приходит параметром вместе с ним.
+func (h *BatchHandler) OrderMethod(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("method"), ":") {
+	case "batchGet":
+		h.BatchGet(c)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown method " + c.Param("method")})
+	}
+}
+
+// BatchGet отдаёт заказы по списку UID. По умолчанию — один JSON с results
+// в порядке запроса; с Accept: application/x-ndjson — строка на заказ по мере
+// готовности: сначала найденные в кэше, затем прочитанные из БД.
+func (h *BatchHandler) BatchGet(c *gin.Context) {
+	var req batchGetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	if err := h.validate(req.UIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if strings.Contains(c.GetHeader("Accept"), ndjsonType) {
+		c.Header("Content-Type", ndjsonType)
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		// ошибка бывает только при записи — клиент ушёл, дальше читать незачем
+		_ = h.svc.GetOrdersBatch(ctx, req.UIDs, func(res service.BatchResult) error {
+			if err := enc.Encode(newBatchItem(res)); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		})
+		return
+	}
+
+	byUID := make(map[string]batchItem, len(req.UIDs))
+	_ = h.svc.GetOrdersBatch(ctx, req.UIDs, func(res service.BatchResult) error {
+		byUID[res.UID] = newBatchItem(res)
+		return nil
+	})
+	results := make([]batchItem, len(req.UIDs))
+	for i, uid := range req.UIDs {
+		results[i] = byUID[uid]
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (h *BatchHandler) validate(uids []string) error {
+	if len(uids) == 0 {
+		return errors.New("uids must not be empty")
+	}
+	if len(uids) > h.maxUIDs {
+		return fmt.Errorf("too many uids: %d, at most %d per request", len(uids), h.maxUIDs)
+	}
+	for i, uid := range uids {
+		if strings.TrimSpace(uid) == "" {
+			return fmt.Errorf("uids[%d] is empty", i)
+		}
+	}
+	return nil
+}
+
+func newBatchItem(res service.BatchResult) batchItem {
+	item := batchItem{OrderUID: res.UID}
+	switch {
+	case errors.Is(res.Err, ports.ErrOrderNotFound):
+		item.Status = BatchNotFound
+	case res.Err != nil:
+		item.Status, item.Error = BatchError, res.Err.Error()
+	default:
+		item.Status, item.Order = BatchFound, res.Order.JSON
+	}
+	return item
+}
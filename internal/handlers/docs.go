@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocsHandler отдаёт спецификацию OpenAPI и страницу документации.
+type DocsHandler struct {
+	spec []byte
+}
+
+func NewDocsHandler(spec []byte) *DocsHandler {
+	return &DocsHandler{spec: spec}
+}
+
+func (h *DocsHandler) OpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", h.spec)
+}
+
+// Page — страница со списком эндпоинтов и схем; строится в браузере из openapi.json.
+func (h *DocsHandler) Page(c *gin.Context) {
+	c.HTML(http.StatusOK, "docs.html", nil)
+}
+
+// Deprecated помечает устаревший маршрут заголовками Deprecation и Link на замену:
+// к successor дописывается остаток пути после префикса группы.
+func Deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rest := c.Request.URL.Path
+		if i := strings.Index(rest[1:], "/"); i >= 0 {
+			rest = rest[i+1:]
+		} else {
+			rest = ""
+		}
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+rest+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
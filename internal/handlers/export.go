@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wb-test-task/internal/export"
+	"wb-test-task/internal/ports"
+)
+
+// exportFlushEvery — через сколько заказов отправлять накопленное клиенту.
+const exportFlushEvery = 100
+
+// ExportHandler отдаёт выгрузку заказов в CSV или NDJSON.
+type ExportHandler struct {
+	src          ports.OrderExporter
+	writeTimeout time.Duration
+}
+
+func NewExportHandler(src ports.OrderExporter, writeTimeout time.Duration) *ExportHandler {
+	return &ExportHandler{src: src, writeTimeout: writeTimeout}
+}
+
+// ExportOrders — GET /api/v1/orders/export?format=csv|ndjson&layout=orders|items&columns=...
+// Фильтры customer_id, delivery_service, locale — как у ленты, плюс
+// created_from и created_to (RFC 3339, правая граница не включается).
+func (h *ExportHandler) ExportOrders(c *gin.Context) {
+	filter, err := exportFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", export.FormatCSV)
+	out := &deadlineWriter{w: c.Writer, rc: http.NewResponseController(c.Writer), timeout: h.writeTimeout}
+	w, err := export.NewWriter(out, export.Options{
+		Format:     format,
+		Layout:     c.Query("layout"),
+		Columns:    queryList(c, "columns"),
+		FlushEvery: exportFlushEvery,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", w.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+	c.Header("X-Accel-Buffering", "no")
+	n, err := export.Run(c.Request.Context(), h.src, filter, w)
+	if err == nil {
+		return
+	}
+	if !c.Writer.Written() {
+		// gin не перезаписывает Content-Type, поэтому заголовки выгрузки снимаются целиком
+		for _, h := range []string{"Content-Type", "Content-Disposition", "X-Accel-Buffering"} {
+			c.Writer.Header().Del(h)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// статус уже отправлен: клиент получит оборванный файл
+	log.Printf("[export] aborted after %d orders: %v", n, err)
+}
+
+func exportFilter(c *gin.Context) (ports.OrderFilter, error) {
+	f := ports.OrderFilter{
+		CustomerIDs:      queryList(c, "customer_id"),
+		DeliveryServices: queryList(c, "delivery_service"),
+		Locales:          queryList(c, "locale"),
+	}
+	for name, dst := range map[string]*time.Time{"created_from": &f.CreatedFrom, "created_to": &f.CreatedTo} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s: expected RFC 3339 time, got %q", name, v)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
+
+// deadlineWriter продлевает срок записи перед каждой записью: WriteTimeout
+// сервера оборвал бы долгую выгрузку, а клиент, который не читает, отвалится по сроку.
+type deadlineWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	_ = d.rc.SetWriteDeadline(time.Now().Add(d.timeout))
+	return d.w.Write(p)
+}
+
+func (d *deadlineWriter) Flush() {
+	_ = d.rc.SetWriteDeadline(time.Now().Add(d.timeout))
+	_ = d.rc.Flush()
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/tuning"
+)
+
+// ConsumerControl — то, что админке нужно от kafka.Consumer.
+type ConsumerControl interface {
+	Status(ctx context.Context) (kafka.ConsumerStatus, error)
+	ResetOffsets(ctx context.Context, spec kafka.ResetSpec, dryRun bool) ([]kafka.OffsetChange, error)
+}
+
+// KafkaAdminHandler управляет консьюмером: пауза, отставание, сброс смещений.
+// Пауза идёт через tuner, чтобы kafka.paused в /admin/runtime показывал то же самое.
+type KafkaAdminHandler struct {
+	tuner    *tuning.Tuner
+	consumer ConsumerControl
+}
+
+func NewKafkaAdminHandler(tuner *tuning.Tuner, consumer ConsumerControl) *KafkaAdminHandler {
+	return &KafkaAdminHandler{tuner: tuner, consumer: consumer}
+}
+
+// GetConsumer отдаёт состояние консьюмера и отставание по партициям.
+func (h *KafkaAdminHandler) GetConsumer(c *gin.Context) {
+	st, err := h.consumer.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "status": st})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+func (h *KafkaAdminHandler) Pause(c *gin.Context) {
+	h.setPaused(c, true)
+}
+
+func (h *KafkaAdminHandler) Resume(c *gin.Context) {
+	h.setPaused(c, false)
+}
+
+func (h *KafkaAdminHandler) setPaused(c *gin.Context, paused bool) {
+	if !h.tuner.SetPaused(paused) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "kafka consumer is not running"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"paused": paused})
+}
+
+// ResetOffsets принимает {"to": "earliest|latest|timestamp|offset", "timestamp": ..., "offset": ...,
+// "topic": ..., "partition": ...}. С ?dry_run=true только возвращает план.
+func (h *KafkaAdminHandler) ResetOffsets(c *gin.Context) {
+	var spec kafka.ResetSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	plan, err := h.consumer.ResetOffsets(c.Request.Context(), spec, dryRun)
+	switch {
+	case errors.Is(err, kafka.ErrNotPaused):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error() + ": pause it first"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if plan == nil {
+		plan = []kafka.OffsetChange{}
+	}
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "changes": plan})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"wb-test-task/internal/kafka"
+)
+
+// DedupCounters — то, что /metrics нужно от kafka.Deduplicator.
+type DedupCounters interface {
+	Stats() kafka.DedupStats
+}
+
+// MetricsHandler отдаёт счётчики в текстовом формате Prometheus.
+type MetricsHandler struct {
+	dedup DedupCounters
+}
+
+// NewMetricsHandler: dedup может быть nil — тогда счётчиков отсева повторов нет.
+func NewMetricsHandler(dedup DedupCounters) *MetricsHandler {
+	return &MetricsHandler{dedup: dedup}
+}
+
+func (h *MetricsHandler) Metrics(c *gin.Context) {
+	var b strings.Builder
+	if h.dedup != nil {
+		st := h.dedup.Stats()
+		counter(&b, "kafka_dedup_checked_total", "Messages checked for duplicates.", st.Checked)
+		counter(&b, "kafka_dedup_duplicates_total", "Duplicate messages skipped and committed.", st.Duplicates)
+		counter(&b, "kafka_dedup_store_errors_total", "Failed reads or writes of the processed_messages journal.", st.StoreErrors)
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+func counter(b *strings.Builder, name, help string, v int64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PeerHandler отдаёт заказы соседним репликам. Он должен работать поверх
+// сервиса с локальным репозиторием, иначе запрос уйдёт по кольцу дальше.
+type PeerHandler struct {
+	svc *service.OrderService
+}
+
+func NewPeerHandler(svc *service.OrderService) *PeerHandler {
+	return &PeerHandler{svc: svc}
+}
+
+// 404 — только если заказа действительно нет; прочие ошибки — 502,
+// чтобы запросившая реплика сходила в свой репозиторий сама.
+func (h *PeerHandler) GetOrder(c *gin.Context) {
+	order, err := h.svc.GetOrderEncoded(c.Request.Context(), c.Param("orderId"))
+	switch {
+	case errors.Is(err, ports.ErrOrderNotFound):
+		respondWithJSON(c.Writer, http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		respondWithJSON(c.Writer, http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		respondWithEncoded(c, http.StatusOK, order)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wb-test-task/internal/feed"
+)
+
+// StreamHandler отдаёт живую ленту новых заказов как Server-Sent Events.
+type StreamHandler struct {
+	hub          *feed.Hub
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+}
+
+func NewStreamHandler(hub *feed.Hub, heartbeat, writeTimeout time.Duration) *StreamHandler {
+	return &StreamHandler{hub: hub, heartbeat: heartbeat, writeTimeout: writeTimeout}
+}
+
+// StreamOrders — GET /api/v1/orders/stream?customer_id=&delivery_service=&locale=.
+// Каждый фильтр можно повторить или перечислить через запятую. Продолжение —
+// по заголовку Last-Event-ID (его шлёт EventSource при переподключении) или ?last_event_id=.
+//
+// События: order — сводка заказа; gap — часть событий после Last-Event-ID
+// потеряна; overflow — клиент не успевал читать и отключён, переподключение
+// продолжит ленту с последнего полученного id.
+func (h *StreamHandler) StreamOrders(c *gin.Context) {
+	filter := feed.Filter{
+		CustomerIDs:      queryList(c, "customer_id"),
+		DeliveryServices: queryList(c, "delivery_service"),
+		Locales:          queryList(c, "locale"),
+	}
+	lastID, resume, err := lastEventID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, replay, gap, err := h.hub.Subscribe(filter, lastID, resume)
+	if errors.Is(err, feed.ErrTooManyClients) {
+		c.Header("Retry-After", "10")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx не должен копить ответ
+	w.WriteHeader(http.StatusOK)
+
+	// WriteTimeout сервера оборвал бы ленту, поэтому срок ставится на каждую запись:
+	// клиент, который не принимает данные, отваливается по нему.
+	rc := http.NewResponseController(w)
+	send := func(frame string) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+		if _, err := w.WriteString(frame); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !send("retry: 3000\n\n") {
+		return
+	}
+	if gap && !send("event: gap\ndata: {}\n\n") {
+		return
+	}
+	for _, ev := range replay {
+		if !send(orderFrame(ev)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					send("event: overflow\ndata: {\"reason\":\"client is too slow\"}\n\n")
+				}
+				return
+			}
+			if !send(orderFrame(ev)) {
+				return
+			}
+		case <-heartbeat.C:
+			if !send(": ping\n\n") {
+				return
+			}
+		}
+	}
+}
+
+func orderFrame(ev feed.Event) string {
+	data, _ := json.Marshal(ev.Summary)
+	return fmt.Sprintf("id: %d\nevent: order\ndata: %s\n\n", ev.ID, data)
+}
+
+func lastEventID(c *gin.Context) (uint64, bool, error) {
+	v := c.GetHeader("Last-Event-ID")
+	if v == "" {
+		v = c.Query("last_event_id")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid Last-Event-ID %q", v)
+	}
+	return id, true, nil
+}
+
+// queryList собирает значения параметра: ?a=x&a=y и ?a=x,y равнозначны.
+func queryList(c *gin.Context, name string) []string {
+	var out []string
+	for _, v := range c.QueryArray(name) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
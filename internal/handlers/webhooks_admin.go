@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/webhooks"
+)
+
+// CircuitStates — состояние предохранителей подписок (webhooks.Sender).
+type CircuitStates interface {
+	CircuitState(id int64) string
+}
+
+// WebhookAdminHandler управляет подписками на вебхуки и показывает журнал доставок.
+type WebhookAdminHandler struct {
+	store    ports.Webhooks
+	circuits CircuitStates
+}
+
+// NewWebhookAdminHandler: circuits может быть nil, тогда состояние предохранителя не показывается.
+func NewWebhookAdminHandler(store ports.Webhooks, circuits CircuitStates) *WebhookAdminHandler {
+	return &WebhookAdminHandler{store: store, circuits: circuits}
+}
+
+type subscriptionView struct {
+	models.WebhookSubscription
+	Circuit string `json:"circuit,omitempty"`
+	Secret  string `json:"secret,omitempty"` // только в ответе на создание и смену секрета
+}
+
+func (h *WebhookAdminHandler) view(s models.WebhookSubscription) subscriptionView {
+	v := subscriptionView{WebhookSubscription: s}
+	if h.circuits != nil {
+		v.Circuit = h.circuits.CircuitState(s.ID)
+	}
+	return v
+}
+
+type subscriptionRequest struct {
+	URL          *string  `json:"url"`
+	EventTypes   []string `json:"event_types"`
+	Active       *bool    `json:"active"`
+	Secret       *string  `json:"secret"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// CreateSubscription принимает {"url": ..., "event_types": [...], "secret": ..., "active": true}.
+// Без secret он генерируется; секрет возвращается только в этом ответе.
+func (h *WebhookAdminHandler) CreateSubscription(c *gin.Context) {
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	s := models.WebhookSubscription{EventTypes: req.EventTypes, Active: true}
+	if req.URL != nil {
+		s.URL = *req.URL
+	}
+	if req.Active != nil {
+		s.Active = *req.Active
+	}
+	if req.Secret != nil {
+		s.Secret = *req.Secret
+	}
+	if !h.applySecret(c, &s, s.Secret == "") {
+		return
+	}
+	if err := webhooks.ValidateSubscription(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.store.CreateSubscription(c.Request.Context(), &s); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	v := h.view(s)
+	v.Secret = s.Secret
+	c.JSON(http.StatusCreated, v)
+}
+
+func (h *WebhookAdminHandler) ListSubscriptions(c *gin.Context) {
+	subs, err := h.store.Subscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	out := make([]subscriptionView, 0, len(subs))
+	for _, s := range subs {
+		out = append(out, h.view(s))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (h *WebhookAdminHandler) GetSubscription(c *gin.Context) {
+	s, ok := h.subscription(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.view(*s))
+}
+
+// UpdateSubscription меняет переданные поля; {"rotate_secret": true} выдаёт новый секрет.
+func (h *WebhookAdminHandler) UpdateSubscription(c *gin.Context) {
+	s, ok := h.subscription(c)
+	if !ok {
+		return
+	}
+	var req subscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.URL != nil {
+		s.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		s.EventTypes = req.EventTypes
+	}
+	if req.Active != nil {
+		s.Active = *req.Active
+	}
+	newSecret := req.RotateSecret || (req.Secret != nil && *req.Secret != "")
+	if req.Secret != nil && *req.Secret != "" {
+		s.Secret = *req.Secret
+	}
+	if !h.applySecret(c, s, req.RotateSecret) {
+		return
+	}
+	if err := webhooks.ValidateSubscription(s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.store.UpdateSubscription(c.Request.Context(), s); err != nil {
+		h.storeError(c, err)
+		return
+	}
+	v := h.view(*s)
+	if newSecret {
+		v.Secret = s.Secret
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+func (h *WebhookAdminHandler) DeleteSubscription(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteSubscription(c.Request.Context(), id); err != nil {
+		h.storeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries отдаёт журнал доставок подписки; ?status=pending|delivered|failed, ?limit=50.
+func (h *WebhookAdminHandler) ListDeliveries(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", models.WebhookPending, models.WebhookDelivered, models.WebhookFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status " + strconv.Quote(status)})
+		return
+	}
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = n
+	}
+	if _, err := h.store.Subscription(c.Request.Context(), id); err != nil {
+		h.storeError(c, err)
+		return
+	}
+	ds, err := h.store.Deliveries(c.Request.Context(), id, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ds == nil {
+		ds = []models.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, ds)
+}
+
+// Redeliver ставит доставку (обычно failed) в очередь заново.
+func (h *WebhookAdminHandler) Redeliver(c *gin.Context) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathID(c, "delivery_id")
+	if !ok {
+		return
+	}
+	if err := h.store.Redeliver(c.Request.Context(), id, deliveryID); err != nil {
+		h.storeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": models.WebhookPending})
+}
+
+func (h *WebhookAdminHandler) subscription(c *gin.Context) (*models.WebhookSubscription, bool) {
+	id, ok := pathID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	s, err := h.store.Subscription(c.Request.Context(), id)
+	if err != nil {
+		h.storeError(c, err)
+		return nil, false
+	}
+	return s, true
+}
+
+func (h *WebhookAdminHandler) applySecret(c *gin.Context, s *models.WebhookSubscription, generate bool) bool {
+	if !generate {
+		return true
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	s.Secret = secret
+	return true
+}
+
+func (h *WebhookAdminHandler) storeError(c *gin.Context, err error) {
+	if errors.Is(err, ports.ErrSubscriptionNotFound) || errors.Is(err, ports.ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func pathID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + name})
+		return 0, false
+	}
+	return id, true
+}
@@ -2,46 +2,129 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 
-	"wb-test-task/internal/models"
-	"wb-test-task/internal/ports"
-	"wb-test-task/internal/validation"
+	"wb-test-task/config"
 )
 
+// ErrNotPaused — смещения можно сдвигать только у остановленного консьюмера.
+var ErrNotPaused = errors.New("consumer is not paused")
+
+// Пауза между повторами сообщения после временной ошибки растёт от retryBackoffMin до retryBackoffMax.
+const (
+	retryBackoffMin = 300 * time.Millisecond
+	retryBackoffMax = 30 * time.Second
+)
+
+// MessageReader — то, что нужно от kafka.Reader (удобно подменять в тестах).
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
+}
+
 type Consumer struct {
-	reader *kafka.Reader
-	repo   ports.OrderRepository
-	cache  ports.Cache[string, *models.Order]
+	groupID    string
+	topics     []string
+	newReader  func() MessageReader
+	dispatcher *Dispatcher
+	offsets    *GroupOffsets
+
+	mu      sync.Mutex
+	reader  MessageReader // nil после сброса смещений, пока консьюмер на паузе
+	resumed chan struct{} // не nil, пока консьюмер на паузе
+}
+
+// ConsumerConfig — параметры подключения консьюмера к кластеру.
+type ConsumerConfig struct {
+	Brokers []string
+	GroupID string
+	Topics  []string
+	Dialer  *kafka.Dialer // nil — dialer kafka-go по умолчанию, без TLS
+
+	StartOffset    int64 // kafka.FirstOffset или kafka.LastOffset
+	IsolationLevel kafka.IsolationLevel
+	SessionTimeout time.Duration
+	GroupBalancers []kafka.GroupBalancer // по приоритету; пусто — range и round-robin
+}
+
+// NewConsumerConfig собирает параметры консьюмера из конфига сервиса.
+func NewConsumerConfig(cfg *config.Config) (ConsumerConfig, error) {
+	cc := ConsumerConfig{
+		Brokers:        strings.Split(cfg.KafkaBrokers, ","),
+		GroupID:        cfg.KafkaGroupID,
+		Topics:         Topics(cfg),
+		StartOffset:    kafka.FirstOffset,
+		SessionTimeout: cfg.KafkaSessionTimeout,
+	}
+	if strings.EqualFold(cfg.KafkaStartOffset, "latest") {
+		cc.StartOffset = kafka.LastOffset
+	}
+	if strings.EqualFold(cfg.KafkaIsolationLevel, "read_committed") {
+		cc.IsolationLevel = kafka.ReadCommitted
+	}
+	for _, st := range cfg.KafkaRebalanceStrategy {
+		switch strings.ToLower(st) {
+		case "range":
+			cc.GroupBalancers = append(cc.GroupBalancers, kafka.RangeGroupBalancer{})
+		case "roundrobin":
+			cc.GroupBalancers = append(cc.GroupBalancers, kafka.RoundRobinGroupBalancer{})
+		default:
+			return cc, fmt.Errorf("unknown rebalance strategy %q", st)
+		}
+	}
+
+	dialer, err := dialerFromConfig(cfg)
+	if err != nil {
+		return cc, err
+	}
+	cc.Dialer = dialer
+	return cc, nil
 }
 
-func NewConsumer(brokers []string, groupID, topic string, repo ports.OrderRepository, cache ports.Cache[string, *models.Order]) *Consumer {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,
-		GroupID:        groupID,
-		Topic:          topic,
+func NewConsumer(cfg ConsumerConfig, d *Dispatcher) *Consumer {
+	rc := kafka.ReaderConfig{
+		Brokers:        cfg.Brokers,
+		GroupID:        cfg.GroupID,
+		GroupTopics:    cfg.Topics,
+		Dialer:         cfg.Dialer,
+		StartOffset:    cfg.StartOffset,
+		IsolationLevel: cfg.IsolationLevel,
+		SessionTimeout: cfg.SessionTimeout,
+		GroupBalancers: cfg.GroupBalancers,
 		MinBytes:       1,
 		MaxBytes:       10e6,
 		CommitInterval: 0,
-	})
+	}
+	return NewConsumerWithReader(cfg, d, func() MessageReader { return kafka.NewReader(rc) })
+}
+
+// NewConsumerWithReader — то же, что NewConsumer, но reader создаёт newReader:
+// сразу и заново после сброса смещений.
+func NewConsumerWithReader(cfg ConsumerConfig, d *Dispatcher, newReader func() MessageReader) *Consumer {
 	return &Consumer{
-		reader: r,
-		repo:   repo,
-		cache:  cache,
+		groupID:    cfg.GroupID,
+		topics:     cfg.Topics,
+		newReader:  newReader,
+		reader:     newReader(),
+		dispatcher: d,
+		offsets:    &GroupOffsets{Client: NewOffsetClient(cfg), GroupID: cfg.GroupID, Topics: cfg.Topics},
 	}
 }
 
 func (c *Consumer) Run(ctx context.Context) {
-	log.Printf("[kafka] consumer started (group=%q)", c.reader.Config().GroupID)
+	log.Printf("[kafka] consumer started (group=%q, topics=%v, events=%v)",
+		c.groupID, c.topics, c.dispatcher.Registry.Types())
 	defer func() {
-		if err := c.reader.Close(); err != nil {
-			log.Printf("[kafka] reader close: %v", err)
-		}
+		c.detach()
 		log.Printf("[kafka] consumer stopped")
 	}()
 
@@ -52,52 +135,186 @@ func (c *Consumer) Run(ctx context.Context) {
 		default:
 		}
 
-		msg, err := c.reader.FetchMessage(ctx)
+		reader, ch := c.state()
+		if ch != nil {
+			log.Printf("[kafka] consumer paused")
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				log.Printf("[kafka] consumer resumed")
+			}
+			continue
+		}
+
+		msg, err := reader.FetchMessage(ctx)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				log.Printf("[kafka] fetch canceled: %v", err)
 				return
 			}
-			log.Printf("[kafka] fetch: %v", err)
-			time.Sleep(200 * time.Millisecond)
+			if !c.Paused() {
+				log.Printf("[kafka] fetch: %v", err)
+				time.Sleep(200 * time.Millisecond)
+			}
 			continue
 		}
 
-		if err := c.processMessage(ctx, msg); err != nil {
-			log.Printf("[kafka] process: %v", err)
-		}
+		c.processMessage(ctx, reader, msg)
 	}
 }
 
-func (c *Consumer) processMessage(ctx context.Context, msg kafka.Message) error {
-	var order models.Order
+// Pause останавливает чтение новых сообщений; уже полученное сообщение
+// дообрабатывается, а если его приходится повторять — ждёт Resume.
+// Смещения не сдвигаются, пока консьюмер на паузе.
+func (c *Consumer) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+}
 
-	if err := json.Unmarshal(msg.Value, &order); err != nil {
-		log.Printf("[kafka] bad json (partition=%d, offset=%d): %v", msg.Partition, msg.Offset, err)
-		if err2 := c.reader.CommitMessages(ctx, msg); err2 != nil {
-			log.Printf("[kafka] commit after bad json: %v", err2)
-		}
-		return nil
+// Resume продолжает чтение; после сброса смещений консьюмер заново входит в группу.
+func (c *Consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resumed == nil {
+		return
+	}
+	if c.reader == nil {
+		c.reader = c.newReader()
 	}
+	close(c.resumed)
+	c.resumed = nil
+}
 
-	if err := validation.ValidateStruct(order); err != nil {
-		log.Printf("[kafka] validation failed (uid=%s, offset=%d): %v", order.OrderUID, msg.Offset, err)
-		if err2 := c.reader.CommitMessages(ctx, msg); err2 != nil {
-			log.Printf("[kafka] commit after validation error: %v", err2)
-		}
-		return nil
+func (c *Consumer) Paused() bool {
+	_, ch := c.state()
+	return ch != nil
+}
+
+func (c *Consumer) state() (MessageReader, chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reader, c.resumed
+}
+
+// detach закрывает reader, и консьюмер выходит из группы.
+func (c *Consumer) detach() {
+	c.mu.Lock()
+	reader := c.reader
+	c.reader = nil
+	c.mu.Unlock()
+	if reader == nil {
+		return
+	}
+	if err := reader.Close(); err != nil {
+		log.Printf("[kafka] reader close: %v", err)
 	}
+}
 
-	if err := c.repo.SaveOrder(ctx, order); err != nil {
-		log.Printf("[kafka] db save failed (uid=%s, offset=%d): %v", order.OrderUID, msg.Offset, err)
-		time.Sleep(300 * time.Millisecond)
-		return nil
+// ConsumerStatus — состояние консьюмера для админского API.
+type ConsumerStatus struct {
+	GroupID    string         `json:"group_id"`
+	Paused     bool           `json:"paused"`
+	InGroup    bool           `json:"in_group"` // false после сброса смещений до Resume
+	Messages   int64          `json:"messages"`
+	Errors     int64          `json:"errors"`
+	Rebalances int64          `json:"rebalances"`
+	Partitions []PartitionLag `json:"partitions"`
+	TotalLag   int64          `json:"total_lag"`
+	Dedup      *DedupStats    `json:"dedup,omitempty"`
+}
+
+// Status собирает счётчики reader и отставание по партициям (смещения группы у брокера).
+func (c *Consumer) Status(ctx context.Context) (ConsumerStatus, error) {
+	reader, ch := c.state()
+	st := ConsumerStatus{GroupID: c.groupID, Paused: ch != nil, InGroup: reader != nil}
+	if reader != nil {
+		stats := reader.Stats()
+		st.Messages, st.Errors, st.Rebalances = stats.Messages, stats.Errors, stats.Rebalances
+	}
+	if c.dispatcher.Dedup != nil {
+		ds := c.dispatcher.Dedup.Stats()
+		st.Dedup = &ds
+	}
+	lag, err := c.offsets.Lag(ctx)
+	if err != nil {
+		return st, err
+	}
+	st.Partitions = lag
+	for _, l := range lag {
+		st.TotalLag += l.Lag
 	}
+	return st, nil
+}
 
-	c.cache.Set(order.OrderUID, &order)
+// ResetOffsets сдвигает смещения группы. dryRun только показывает план.
+// Для записи консьюмер должен стоять на паузе: он выходит из группы и вернётся
+// в неё при Resume. Реплики с тем же GroupID тоже нужно остановить — иначе
+// брокер отклонит коммит.
+func (c *Consumer) ResetOffsets(ctx context.Context, spec ResetSpec, dryRun bool) ([]OffsetChange, error) {
+	plan, err := c.offsets.Plan(ctx, spec)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	if !c.Paused() {
+		return nil, ErrNotPaused
+	}
+	c.detach()
+	if err := c.offsets.Commit(ctx, plan); err != nil {
+		return nil, err
+	}
+	for _, ch := range plan {
+		log.Printf("[kafka] offset reset %s/%d: %d -> %d", ch.Topic, ch.Partition, ch.From, ch.To)
+	}
+	return plan, nil
+}
+
+// processMessage доводит сообщение до коммита. Reader уже выдаёт следующие
+// сообщения, и их коммит перепрыгнул бы это смещение, поэтому при временной
+// ошибке то же сообщение повторяется с нарастающей паузой, а при блокировке —
+// после Resume. Если за время паузы смещения сбросили, сообщение бросается:
+// новый reader начнёт с закоммиченного смещения.
+func (c *Consumer) processMessage(ctx context.Context, reader MessageReader, msg kafka.Message) {
+	backoff := retryBackoffMin
+	for {
+		switch c.dispatcher.Dispatch(ctx, msg) {
+		case OutcomeCommit:
+			if err := reader.CommitMessages(ctx, msg); err != nil {
+				log.Printf("[kafka] commit offset failed (topic=%s, offset=%d): %v", msg.Topic, msg.Offset, err)
+			}
+			return
+		case OutcomeRetry:
+			log.Printf("[kafka] retry in %s (topic=%s, partition=%d, offset=%d)", backoff, msg.Topic, msg.Partition, msg.Offset)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, retryBackoffMax)
+		case OutcomeBlock:
+			c.Pause()
+		}
+		if !c.awaitResume(ctx, reader, msg) {
+			return
+		}
+	}
+}
 
-	if err := c.reader.CommitMessages(ctx, msg); err != nil {
-		log.Printf("[kafka] commit offset failed (uid=%s, offset=%d): %v", order.OrderUID, msg.Offset, err)
+// awaitResume ждёт снятия паузы перед повтором msg. false — консьюмер
+// останавливается или reader сменился после сброса смещений.
+func (c *Consumer) awaitResume(ctx context.Context, reader MessageReader, msg kafka.Message) bool {
+	if _, ch := c.state(); ch != nil {
+		log.Printf("[kafka] consumer paused, holding message (topic=%s, partition=%d, offset=%d)", msg.Topic, msg.Partition, msg.Offset)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ch:
+			log.Printf("[kafka] consumer resumed")
+		}
 	}
-	return nil
+	current, _ := c.state()
+	return ctx.Err() == nil && current == reader
 }
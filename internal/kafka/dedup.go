@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/ports"
+)
+
+const IdempotencyKeyHeader = "idempotency-key"
+
+// Источники ключа идемпотентности, в порядке из конфига берётся первый непустой.
+const (
+	DedupKeyHeader  = "header"   // заголовок idempotency-key
+	DedupKeyEventID = "event_id" // event_id из конверта
+	DedupKeyMessage = "key"      // ключ сообщения Kafka (подходит, только если он уникален для события)
+	DedupKeyHash    = "hash"     // SHA-256 полезной нагрузки (только для топиков со снимками заказа)
+)
+
+// DedupStats — счётчики для админского API.
+type DedupStats struct {
+	Checked     int64 `json:"checked"`
+	Duplicates  int64 `json:"duplicates"`
+	StoreErrors int64 `json:"store_errors"`
+}
+
+// Deduplicator отсеивает повторы одного события в пределах окна. Недавние
+// ключи держатся в LRU в памяти, полный журнал — в Postgres, чтобы дубли
+// ловились и после перезапуска, и между репликами. При недоступности журнала
+// сообщение обрабатывается: лучше повтор, чем потеря.
+type Deduplicator struct {
+	store   ports.ProcessedMessages
+	recent  *cache.ShardedLRU[struct{}]
+	window  time.Duration
+	sources []string
+
+	checked, duplicates, storeErrors atomic.Int64
+}
+
+// NewDeduplicator: store может быть nil — тогда дубли ловятся только в памяти.
+func NewDeduplicator(store ports.ProcessedMessages, window time.Duration, memorySize int, sources []string) (*Deduplicator, error) {
+	for _, s := range sources {
+		switch s {
+		case DedupKeyHeader, DedupKeyEventID, DedupKeyMessage, DedupKeyHash:
+		default:
+			return nil, fmt.Errorf("unknown dedup key source %q", s)
+		}
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("dedup window must be positive, got %s", window)
+	}
+	recent := cache.NewShardedLRU[struct{}](16, max(memorySize, 16), window)
+	return &Deduplicator{store: store, recent: recent, window: window, sources: sources}, nil
+}
+
+// Key возвращает ключ идемпотентности сообщения; пустая строка — ключа нет.
+// Ключ сообщения и хэш включают топик: в разных топиках это разные события.
+func (d *Deduplicator) Key(msg kafka.Message) string {
+	for _, s := range d.sources {
+		switch s {
+		case DedupKeyHeader:
+			if v := header(msg, IdempotencyKeyHeader); v != "" {
+				return "h:" + v
+			}
+		case DedupKeyEventID:
+			if env, ok, _ := ParseEnvelope(msg.Value); ok && env.EventID != "" {
+				return "e:" + env.EventID
+			}
+		case DedupKeyMessage:
+			if len(msg.Key) > 0 {
+				return "k:" + msg.Topic + ":" + string(msg.Key)
+			}
+		case DedupKeyHash:
+			sum := sha256.Sum256(msg.Value)
+			return "p:" + msg.Topic + ":" + hex.EncodeToString(sum[:])
+		}
+	}
+	return ""
+}
+
+// Seen сообщает, обрабатывалось ли событие с этим ключом в пределах окна.
+func (d *Deduplicator) Seen(ctx context.Context, key string) bool {
+	d.checked.Add(1)
+	if _, ok := d.recent.Get(key); ok {
+		d.duplicates.Add(1)
+		return true
+	}
+	if d.store == nil {
+		return false
+	}
+	seen, err := d.store.SeenSince(ctx, key, time.Now().Add(-d.window))
+	if err != nil {
+		d.storeErrors.Add(1)
+		log.Printf("[dedup] %v", err)
+		return false
+	}
+	if seen {
+		d.recent.Set(key, struct{}{})
+		d.duplicates.Add(1)
+	}
+	return seen
+}
+
+// Mark запоминает успешно обработанное событие.
+func (d *Deduplicator) Mark(ctx context.Context, key string) {
+	d.recent.Set(key, struct{}{})
+	if d.store == nil {
+		return
+	}
+	if err := d.store.MarkProcessed(ctx, key, time.Now()); err != nil {
+		d.storeErrors.Add(1)
+		log.Printf("[dedup] %v", err)
+	}
+}
+
+func (d *Deduplicator) Stats() DedupStats {
+	return DedupStats{Checked: d.checked.Load(), Duplicates: d.duplicates.Load(), StoreErrors: d.storeErrors.Load()}
+}
+
+// RunPurger раз в interval удаляет из журнала записи старше окна.
+func (d *Deduplicator) RunPurger(ctx context.Context, interval time.Duration) {
+	if d.store == nil || interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := d.store.PurgeProcessed(ctx, time.Now().Add(-d.window))
+			if err != nil {
+				log.Printf("[dedup] %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("[dedup] purged %d processed message keys", n)
+			}
+		}
+	}
+}
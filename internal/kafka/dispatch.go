@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// FallbackPolicy — что делать с сообщением неизвестного типа.
+type FallbackPolicy string
+
+const (
+	FallbackSkip  FallbackPolicy = "skip"  // залогировать и закоммитить
+	FallbackDLQ   FallbackPolicy = "dlq"   // переложить в dead-letter топик и закоммитить
+	FallbackBlock FallbackPolicy = "block" // не коммитить и поставить консьюмер на паузу
+)
+
+func ParseFallbackPolicy(s string) (FallbackPolicy, error) {
+	switch p := FallbackPolicy(s); p {
+	case FallbackSkip, FallbackDLQ, FallbackBlock:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown fallback policy %q", s)
+}
+
+// Outcome — что консьюмеру делать со смещением после обработки.
+type Outcome int
+
+const (
+	OutcomeCommit Outcome = iota // обработано (или отброшено) — коммитим
+	OutcomeRetry                 // временная ошибка — не коммитим
+	OutcomeBlock                 // неизвестный тип при политике block — не коммитим и встаём на паузу
+)
+
+// MessageWriter — то, что нужно от kafka.Writer (удобно подменять в тестах).
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Dispatcher находит обработчик по типу события и решает судьбу сообщения.
+type Dispatcher struct {
+	Registry *Registry
+	Fallback FallbackPolicy
+	DLQ      MessageWriter // обязателен при FallbackDLQ; если задан, туда же идут битые сообщения
+	Parking  MessageWriter // события из будущих версий схемы; nil — консьюмер встаёт на паузу
+	Dedup    *Deduplicator // nil — без отсева дублей
+}
+
+func (d *Dispatcher) Dispatch(ctx context.Context, msg kafka.Message) Outcome {
+	eventType, h := d.Registry.Resolve(msg)
+	if h == nil {
+		return d.fallback(ctx, msg, eventType)
+	}
+
+	var key string
+	if d.Dedup != nil {
+		if key = d.Dedup.Key(msg); key != "" && d.Dedup.Seen(ctx, key) {
+			log.Printf("[kafka] skip duplicate %s (topic=%s, partition=%d, offset=%d, key=%s)", eventType, msg.Topic, msg.Partition, msg.Offset, key)
+			return OutcomeCommit
+		}
+	}
+
+	err := h.Handle(ctx, msg)
+	switch {
+	case err == nil:
+		if key != "" {
+			d.Dedup.Mark(ctx, key)
+		}
+		return OutcomeCommit
+	case IsParked(err):
+		return d.park(ctx, msg, eventType, err.Error())
+	case IsPermanent(err):
+		log.Printf("[kafka] drop %s (topic=%s, partition=%d, offset=%d): %v", eventType, msg.Topic, msg.Partition, msg.Offset, err)
+		if d.DLQ != nil {
+			return d.deadLetter(ctx, msg, err.Error())
+		}
+		return OutcomeCommit
+	default:
+		log.Printf("[kafka] %s failed (topic=%s, partition=%d, offset=%d): %v", eventType, msg.Topic, msg.Partition, msg.Offset, err)
+		return OutcomeRetry
+	}
+}
+
+func (d *Dispatcher) fallback(ctx context.Context, msg kafka.Message, eventType string) Outcome {
+	reason := fmt.Sprintf("unknown event type %q", eventType)
+	switch d.Fallback {
+	case FallbackDLQ:
+		if d.DLQ != nil {
+			return d.deadLetter(ctx, msg, reason)
+		}
+	case FallbackBlock:
+		log.Printf("[kafka] %s (topic=%s, partition=%d, offset=%d), pausing consumer", reason, msg.Topic, msg.Partition, msg.Offset)
+		return OutcomeBlock
+	}
+	log.Printf("[kafka] skip %s (topic=%s, partition=%d, offset=%d)", reason, msg.Topic, msg.Partition, msg.Offset)
+	return OutcomeCommit
+}
+
+// park откладывает сообщение, которое этот сервис пока не понимает. В отложенное
+// сообщение дописывается заголовок event-type, поэтому после обновления сервиса
+// топик для отложенных достаточно добавить в KAFKA_TOPICS.
+func (d *Dispatcher) park(ctx context.Context, msg kafka.Message, eventType, reason string) Outcome {
+	if d.Parking == nil {
+		log.Printf("[kafka] cannot handle %s (topic=%s, partition=%d, offset=%d): %s, pausing consumer", eventType, msg.Topic, msg.Partition, msg.Offset, reason)
+		return OutcomeBlock
+	}
+	var extra []kafka.Header
+	if header(msg, EventTypeHeader) == "" {
+		extra = append(extra, kafka.Header{Key: EventTypeHeader, Value: []byte(eventType)})
+	}
+	return d.forward(ctx, d.Parking, "parking", msg, reason, extra...)
+}
+
+// deadLetter перекладывает сообщение как есть, добавляя заголовки с причиной и источником.
+func (d *Dispatcher) deadLetter(ctx context.Context, msg kafka.Message, reason string) Outcome {
+	return d.forward(ctx, d.DLQ, "dlq", msg, reason)
+}
+
+// forward копирует сообщение в w; заголовки <kind>-reason/-topic/-partition/-offset
+// описывают причину и исходное место сообщения.
+func (d *Dispatcher) forward(ctx context.Context, w MessageWriter, kind string, msg kafka.Message, reason string, extra ...kafka.Header) Outcome {
+	headers := append([]kafka.Header(nil), msg.Headers...)
+	headers = append(headers, extra...)
+	headers = append(headers,
+		kafka.Header{Key: kind + "-reason", Value: []byte(reason)},
+		kafka.Header{Key: kind + "-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: kind + "-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: kind + "-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	err := w.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+	if err != nil {
+		log.Printf("[kafka] %s write (topic=%s, offset=%d): %v", kind, msg.Topic, msg.Offset, err)
+		return OutcomeRetry
+	}
+	log.Printf("[kafka] moved to %s (topic=%s, partition=%d, offset=%d): %s", kind, msg.Topic, msg.Partition, msg.Offset, reason)
+	return OutcomeCommit
+}
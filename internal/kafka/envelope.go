@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Envelope — необязательный конверт вокруг события. Сообщение без конверта
+// считается версией 1, так что старые продюсеры продолжают работать.
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	EventID       string          `json:"event_id,omitempty"`
+	ProducedAt    time.Time       `json:"produced_at,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// ParseEnvelope распознаёт конверт: JSON-объект с полями schema_version и payload.
+// ok=false — сообщение без конверта.
+func ParseEnvelope(data []byte) (env Envelope, ok bool, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return env, false, nil
+	}
+	var probe struct {
+		SchemaVersion *int            `json:"schema_version"`
+		EventID       string          `json:"event_id"`
+		ProducedAt    *time.Time      `json:"produced_at"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		// разбор полезной нагрузки сам сообщит о битом JSON
+		return env, false, nil
+	}
+	if probe.SchemaVersion == nil || probe.Payload == nil {
+		return env, false, nil
+	}
+	if *probe.SchemaVersion < 1 {
+		return env, false, fmt.Errorf("envelope: invalid schema_version %d", *probe.SchemaVersion)
+	}
+	env = Envelope{SchemaVersion: *probe.SchemaVersion, EventID: probe.EventID, Payload: probe.Payload}
+	if probe.ProducedAt != nil {
+		env.ProducedAt = *probe.ProducedAt
+	}
+	return env, true, nil
+}
+
+// Upcaster поднимает полезную нагрузку с версии N на N+1.
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// ErrFutureVersion — событие новее, чем умеет этот сервис; такие сообщения
+// откладываются (Park), чтобы обработать их после обновления.
+var ErrFutureVersion = errors.New("schema version is newer than supported")
+
+type parkedError struct{ err error }
+
+func (e parkedError) Error() string { return e.err.Error() }
+func (e parkedError) Unwrap() error { return e.err }
+
+// Park помечает сообщение как отложенное: оно не битое, но обработать его пока нельзя.
+func Park(err error) error {
+	if err == nil {
+		return nil
+	}
+	return parkedError{err}
+}
+
+func IsParked(err error) bool {
+	var p parkedError
+	return errors.As(err, &p)
+}
+
+// upcast снимает конверт и доводит полезную нагрузку до версии current.
+func upcast(msg kafka.Message, current int, upcasters map[int]Upcaster) (kafka.Message, error) {
+	env, ok, err := ParseEnvelope(msg.Value)
+	if err != nil {
+		return msg, Permanent(err)
+	}
+	version, payload := 1, json.RawMessage(msg.Value)
+	if ok {
+		version, payload = env.SchemaVersion, env.Payload
+	}
+	current = max(current, 1)
+	if version > current {
+		return msg, Park(fmt.Errorf("v%d (supported v%d): %w", version, current, ErrFutureVersion))
+	}
+	for v := version; v < current; v++ {
+		up, found := upcasters[v]
+		if !found {
+			return msg, Permanent(fmt.Errorf("no upcaster from v%d", v))
+		}
+		if payload, err = up(payload); err != nil {
+			return msg, Permanent(fmt.Errorf("upcast v%d: %w", v, err))
+		}
+	}
+	msg.Value = payload
+	return msg, nil
+}
@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"wb-test-task/internal/codec"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/schemaregistry"
+)
+
+// OrderSnapshotHandler сохраняет полный снимок заказа и кладёт его в кэш.
+// Снимок может прийти в JSON, Protobuf или Avro — формат определяет decoder.
+func OrderSnapshotHandler(repo ports.OrderRepository, cache ports.Cache[string, *models.Order], decoder *codec.OrderDecoder) Handler {
+	return Event[models.Order]{
+		Decode: func(ctx context.Context, msg kafka.Message) (models.Order, error) {
+			order, err := decoder.Decode(ctx, header(msg, codec.ContentTypeHeader), msg.Value)
+			if errors.Is(err, schemaregistry.ErrUnavailable) {
+				return order, Retryable(err)
+			}
+			return order, err
+		},
+		Apply: func(ctx context.Context, order models.Order) error {
+			// то же время, что запишется в БД, чтобы закэшированная копия давала тот же Last-Modified
+			order.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+			err := repo.SaveOrder(ctx, order)
+			switch {
+			case errors.Is(err, ports.ErrOrderExists):
+				// повтор снимка (ретрай продюсера): заказ уже сохранён, кэш не трогаем
+				log.Printf("[kafka] order %s already stored, skipping snapshot", order.OrderUID)
+				return nil
+			case errors.Is(err, ports.ErrOrderRejected):
+				return Permanent(err)
+			case err != nil:
+				return err
+			}
+			cache.Set(order.OrderUID, &order)
+			return nil
+		},
+	}
+}
+
+// OrderStatusHandler меняет статус товаров; устаревшая запись в кэше удаляется,
+// свежая версия подтянется из БД при следующем чтении.
+func OrderStatusHandler(store ports.OrderUpdater, cache ports.Cache[string, *models.Order]) Handler {
+	return Event[models.OrderStatusEvent]{
+		Version:   2,
+		Upcasters: map[int]Upcaster{1: upcastStatusV1},
+		Apply: func(ctx context.Context, ev models.OrderStatusEvent) error {
+			return evict(cache, ev.OrderUID, store.UpdateItemsStatus(ctx, ev.OrderUID, ev.Status, ev.ChrtIDs))
+		},
+	}
+}
+
+// OrderCancelledHandler удаляет отменённый заказ; повторная отмена не ошибка.
+func OrderCancelledHandler(store ports.OrderUpdater, cache ports.Cache[string, *models.Order]) Handler {
+	return Event[models.OrderCancelledEvent]{
+		Apply: func(ctx context.Context, ev models.OrderCancelledEvent) error {
+			err := store.DeleteOrder(ctx, ev.OrderUID)
+			if errors.Is(err, ports.ErrOrderNotFound) {
+				err = nil
+			}
+			if err == nil {
+				log.Printf("[kafka] order %s cancelled: %s", ev.OrderUID, ev.Reason)
+			}
+			return evict(cache, ev.OrderUID, err)
+		},
+	}
+}
+
+func DeliveryTrackingHandler(store ports.OrderUpdater, cache ports.Cache[string, *models.Order]) Handler {
+	return Event[models.DeliveryTrackingEvent]{
+		Apply: func(ctx context.Context, ev models.DeliveryTrackingEvent) error {
+			return evict(cache, ev.OrderUID, store.UpdateTrackNumber(ctx, ev.OrderUID, ev.TrackNumber))
+		},
+	}
+}
+
+// upcastStatusV1: в v1 товар задавался одним полем chrt_id, в v2 — списком chrt_ids.
+func upcastStatusV1(payload json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	id, ok := fields["chrt_id"]
+	if !ok {
+		return payload, nil
+	}
+	delete(fields, "chrt_id")
+	if _, ok := fields["chrt_ids"]; !ok && string(id) != "null" {
+		fields["chrt_ids"] = json.RawMessage("[" + string(id) + "]")
+	}
+	return json.Marshal(fields)
+}
+
+// evict удаляет заказ из кэша после успешного изменения. Событие для
+// неизвестного заказа повторять бессмысленно — ошибка становится неисправимой.
+func evict(cache ports.Cache[string, *models.Order], uid string, err error) error {
+	if errors.Is(err, ports.ErrOrderNotFound) {
+		return Permanent(err)
+	}
+	if err == nil {
+		cache.Delete(uid)
+	}
+	return err
+}
+
+// RegisterOrderHandlers регистрирует обработчики всех событий заказа.
+func RegisterOrderHandlers(r *Registry, repo ports.OrderRepository, store ports.OrderUpdater, cache ports.Cache[string, *models.Order], decoder *codec.OrderDecoder) {
+	r.Register(EventOrderSnapshot, OrderSnapshotHandler(repo, cache, decoder))
+	r.Register(EventOrderStatus, OrderStatusHandler(store, cache))
+	r.Register(EventOrderCancelled, OrderCancelledHandler(store, cache))
+	r.Register(EventDeliveryTracking, DeliveryTrackingHandler(store, cache))
+}
@@ -0,0 +1,309 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// OffsetClient — часть kafka.Client для работы со смещениями группы (удобно подменять в тестах).
+type OffsetClient interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+	OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error)
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+// NewOffsetClient создаёт клиент к тому же кластеру и с теми же TLS/SASL, что у консьюмера.
+func NewOffsetClient(cc ConsumerConfig) *kafka.Client {
+	return &kafka.Client{Addr: kafka.TCP(cc.Brokers...), Transport: newTransport(cc)}
+}
+
+// PartitionLag — состояние партиции для группы. Committed = -1, если группа
+// ещё ничего не коммитила; тогда отставание считается от начала партиции.
+type PartitionLag struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Committed int64  `json:"committed"`
+	Earliest  int64  `json:"earliest"`
+	Latest    int64  `json:"latest"`
+	Lag       int64  `json:"lag"`
+}
+
+// Куда сдвигать смещения группы.
+const (
+	ResetEarliest  = "earliest"
+	ResetLatest    = "latest"
+	ResetTimestamp = "timestamp"
+	ResetOffset    = "offset"
+)
+
+// ResetSpec описывает сброс смещений. Topic и Partition сужают сброс;
+// пустой Topic — все топики консьюмера, nil Partition — все партиции.
+type ResetSpec struct {
+	To        string    `json:"to"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Offset    int64     `json:"offset,omitempty"`
+	Topic     string    `json:"topic,omitempty"`
+	Partition *int      `json:"partition,omitempty"`
+}
+
+func (s ResetSpec) Validate() error {
+	switch s.To {
+	case ResetEarliest, ResetLatest:
+	case ResetTimestamp:
+		if s.Timestamp.IsZero() {
+			return errors.New("timestamp is required")
+		}
+	case ResetOffset:
+		if s.Offset < 0 {
+			return errors.New("offset must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown reset target %q (earliest, latest, timestamp, offset)", s.To)
+	}
+	if s.Partition != nil && s.Topic == "" {
+		return errors.New("partition requires topic")
+	}
+	return nil
+}
+
+// OffsetChange — одна строка плана сброса.
+type OffsetChange struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	From      int64  `json:"from"`
+	To        int64  `json:"to"`
+}
+
+// GroupOffsets читает и сдвигает смещения группы консьюмера.
+type GroupOffsets struct {
+	Client  OffsetClient
+	GroupID string
+	Topics  []string
+}
+
+// Lag возвращает отставание группы по каждой партиции её топиков.
+func (g *GroupOffsets) Lag(ctx context.Context) ([]PartitionLag, error) {
+	parts, err := g.partitions(ctx, g.Topics)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := g.committed(ctx, parts)
+	if err != nil {
+		return nil, err
+	}
+	first, err := g.listOffsets(ctx, parts, kafka.FirstOffset)
+	if err != nil {
+		return nil, err
+	}
+	last, err := g.listOffsets(ctx, parts, kafka.LastOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []PartitionLag
+	for _, tp := range sortedPartitions(parts) {
+		l := PartitionLag{
+			Topic:     tp.topic,
+			Partition: tp.partition,
+			Committed: committed[tp],
+			Earliest:  first[tp],
+			Latest:    last[tp],
+		}
+		from := l.Committed
+		if from < 0 {
+			from = l.Earliest
+		}
+		l.Lag = max(l.Latest-from, 0)
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// Plan считает новые смещения, ничего не меняя.
+func (g *GroupOffsets) Plan(ctx context.Context, spec ResetSpec) ([]OffsetChange, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	topics := g.Topics
+	if spec.Topic != "" {
+		topics = []string{spec.Topic}
+	}
+	parts, err := g.partitions(ctx, topics)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Partition != nil {
+		found := false
+		for _, p := range parts[spec.Topic] {
+			found = found || p == *spec.Partition
+		}
+		if !found {
+			return nil, fmt.Errorf("topic %s has no partition %d", spec.Topic, *spec.Partition)
+		}
+		parts = map[string][]int{spec.Topic: {*spec.Partition}}
+	}
+
+	committed, err := g.committed(ctx, parts)
+	if err != nil {
+		return nil, err
+	}
+	first, err := g.listOffsets(ctx, parts, kafka.FirstOffset)
+	if err != nil {
+		return nil, err
+	}
+	last, err := g.listOffsets(ctx, parts, kafka.LastOffset)
+	if err != nil {
+		return nil, err
+	}
+	var target map[topicPartition]int64
+	switch spec.To {
+	case ResetEarliest:
+		target = first
+	case ResetLatest:
+		target = last
+	case ResetTimestamp:
+		if target, err = g.listOffsets(ctx, parts, spec.Timestamp.UnixMilli()); err != nil {
+			return nil, err
+		}
+	}
+
+	var plan []OffsetChange
+	for _, tp := range sortedPartitions(parts) {
+		to := spec.Offset
+		if target != nil {
+			to = target[tp]
+		}
+		if to < 0 {
+			// после указанного времени сообщений нет
+			to = last[tp]
+		}
+		// смещение вне хранимого диапазона брокер всё равно сбросит по auto.offset.reset
+		to = min(max(to, first[tp]), last[tp])
+		plan = append(plan, OffsetChange{Topic: tp.topic, Partition: tp.partition, From: committed[tp], To: to})
+	}
+	return plan, nil
+}
+
+// Commit записывает план. Брокер примет его, только если в группе нет активных
+// участников: консьюмеры всех реплик должны быть остановлены.
+func (g *GroupOffsets) Commit(ctx context.Context, plan []OffsetChange) error {
+	topics := map[string][]kafka.OffsetCommit{}
+	for _, c := range plan {
+		topics[c.Topic] = append(topics[c.Topic], kafka.OffsetCommit{Partition: c.Partition, Offset: c.To})
+	}
+	resp, err := g.Client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{GroupID: g.GroupID, GenerationID: -1, Topics: topics})
+	if err != nil {
+		return fmt.Errorf("commit offsets: %w", err)
+	}
+	for topic, parts := range resp.Topics {
+		for _, p := range parts {
+			if p.Error != nil {
+				return fmt.Errorf("commit offsets %s/%d: %w", topic, p.Partition, p.Error)
+			}
+		}
+	}
+	return nil
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+func sortedPartitions(parts map[string][]int) []topicPartition {
+	var out []topicPartition
+	for topic, ps := range parts {
+		for _, p := range ps {
+			out = append(out, topicPartition{topic, p})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].topic != out[j].topic {
+			return out[i].topic < out[j].topic
+		}
+		return out[i].partition < out[j].partition
+	})
+	return out
+}
+
+func (g *GroupOffsets) partitions(ctx context.Context, topics []string) (map[string][]int, error) {
+	resp, err := g.Client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	parts := map[string][]int{}
+	for _, t := range resp.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("metadata %s: %w", t.Name, t.Error)
+		}
+		for _, p := range t.Partitions {
+			parts[t.Name] = append(parts[t.Name], p.ID)
+		}
+	}
+	return parts, nil
+}
+
+func (g *GroupOffsets) committed(ctx context.Context, parts map[string][]int) (map[topicPartition]int64, error) {
+	resp, err := g.Client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: g.GroupID, Topics: parts})
+	if err != nil {
+		return nil, fmt.Errorf("fetch committed offsets: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("fetch committed offsets: %w", resp.Error)
+	}
+	out := map[topicPartition]int64{}
+	for _, tp := range sortedPartitions(parts) {
+		out[tp] = -1
+	}
+	for topic, ps := range resp.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("fetch committed offsets %s/%d: %w", topic, p.Partition, p.Error)
+			}
+			out[topicPartition{topic, p.Partition}] = p.CommittedOffset
+		}
+	}
+	return out, nil
+}
+
+// listOffsets запрашивает по одному смещению на партицию: kafka.FirstOffset,
+// kafka.LastOffset или первое смещение не раньше времени ts (мс); -1 — таких нет.
+func (g *GroupOffsets) listOffsets(ctx context.Context, parts map[string][]int, ts int64) (map[topicPartition]int64, error) {
+	req := &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{}}
+	for topic, ps := range parts {
+		for _, p := range ps {
+			req.Topics[topic] = append(req.Topics[topic], kafka.OffsetRequest{Partition: p, Timestamp: ts})
+		}
+	}
+	resp, err := g.Client.ListOffsets(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("list offsets: %w", err)
+	}
+	out := map[topicPartition]int64{}
+	for topic, ps := range resp.Topics {
+		for _, p := range ps {
+			if p.Error != nil {
+				return nil, fmt.Errorf("list offsets %s/%d: %w", topic, p.Partition, p.Error)
+			}
+			tp := topicPartition{topic, p.Partition}
+			switch ts {
+			case kafka.FirstOffset:
+				out[tp] = p.FirstOffset
+			case kafka.LastOffset:
+				out[tp] = p.LastOffset
+			default:
+				out[tp] = -1
+				for off := range p.Offsets {
+					out[tp] = off
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,195 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/codec"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/schemaregistry"
+	"wb-test-task/internal/validation"
+)
+
+// Типы событий. Тип берётся из заголовка EventTypeHeader, а если его нет —
+// из привязки топика к типу.
+const (
+	EventOrderSnapshot    = "order.snapshot"
+	EventOrderStatus      = "order.status"
+	EventOrderCancelled   = "order.cancelled"
+	EventDeliveryTracking = "delivery.tracking"
+)
+
+const EventTypeHeader = "event-type"
+
+// Handler обрабатывает сообщение одного типа. Ошибку, при которой повтор
+// бесполезен (битый JSON, невалидные данные), нужно обернуть в Permanent.
+type Handler interface {
+	Handle(ctx context.Context, msg kafka.Message) error
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку как неисправимую: сообщение не будет обработано и повторно.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type retryableError struct{ err error }
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// Retryable помечает ошибку разбора как временную (например, недоступен
+// реестр схем): такое сообщение повторяется, а не считается битым.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return retryableError{err}
+}
+
+func IsRetryable(err error) bool {
+	var r retryableError
+	return errors.As(err, &r)
+}
+
+// Event — обработчик из трёх шагов: разбор, проверка, применение.
+// Перед разбором снимается конверт и старые версии поднимаются до Version.
+// Ошибки Decode и Validate считаются неисправимыми, кроме помеченных Retryable.
+type Event[T any] struct {
+	Version   int              // текущая версия схемы события; 0 — 1
+	Upcasters map[int]Upcaster // ключ — версия, с которой upcaster поднимает на следующую
+
+	Decode   func(ctx context.Context, msg kafka.Message) (T, error) // nil — JSON
+	Validate func(ev T) error                                        // nil — теги validate
+	Apply    func(ctx context.Context, ev T) error
+}
+
+func (e Event[T]) Handle(ctx context.Context, msg kafka.Message) error {
+	msg, err := upcast(msg, e.Version, e.Upcasters)
+	if err != nil {
+		return err
+	}
+
+	var ev T
+	if e.Decode != nil {
+		ev, err = e.Decode(ctx, msg)
+	} else {
+		err = json.Unmarshal(msg.Value, &ev)
+	}
+	if IsRetryable(err) {
+		return fmt.Errorf("decode: %w", err)
+	}
+	if err != nil {
+		return Permanent(fmt.Errorf("decode: %w", err))
+	}
+
+	if e.Validate != nil {
+		err = e.Validate(ev)
+	} else {
+		err = validation.ValidateStruct(ev)
+	}
+	if err != nil {
+		return Permanent(fmt.Errorf("validate: %w", err))
+	}
+	return e.Apply(ctx, ev)
+}
+
+// Registry сопоставляет тип события с обработчиком.
+type Registry struct {
+	handlers map[string]Handler
+	topics   map[string]string // топик → тип для сообщений без заголовка
+}
+
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]Handler{}, topics: map[string]string{}}
+}
+
+func (r *Registry) Register(eventType string, h Handler) {
+	r.handlers[eventType] = h
+}
+
+// MapTopic задаёт тип для сообщений топика, пришедших без заголовка event-type.
+func (r *Registry) MapTopic(topic, eventType string) {
+	r.topics[topic] = eventType
+}
+
+// header возвращает значение заголовка сообщения или пустую строку.
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Resolve возвращает тип события и его обработчик; nil — тип неизвестен.
+func (r *Registry) Resolve(msg kafka.Message) (string, Handler) {
+	eventType := header(msg, EventTypeHeader)
+	if eventType == "" {
+		eventType = r.topics[msg.Topic]
+	}
+	return eventType, r.handlers[eventType]
+}
+
+// Types — зарегистрированные типы, для логов.
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for t := range r.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Topics — основной топик со снимками заказов и дополнительные из KAFKA_TOPICS.
+func Topics(cfg *config.Config) []string {
+	topics := []string{cfg.KafkaTopic}
+	for _, t := range cfg.KafkaTopics {
+		name, _, _ := strings.Cut(t, "=")
+		topics = append(topics, name)
+	}
+	return topics
+}
+
+// NewOrderRegistry создаёт реестр с обработчиками заказов и привязками топиков из конфига:
+// основной топик — снимки заказов, "topic=type" в KAFKA_TOPICS — тип по умолчанию для топика.
+func NewOrderRegistry(cfg *config.Config, repo ports.OrderRepository, store ports.OrderUpdater, cache ports.Cache[string, *models.Order]) *Registry {
+	var registry codec.SchemaSource
+	if cfg.SchemaRegistryURL != "" {
+		registry = schemaregistry.New(cfg.SchemaRegistryURL, schemaregistry.Options{
+			Username: cfg.SchemaRegistryUsername,
+			Password: cfg.SchemaRegistryPassword,
+			Timeout:  cfg.SchemaRegistryTimeout,
+		})
+	}
+	r := NewRegistry()
+	RegisterOrderHandlers(r, repo, store, cache, codec.NewOrderDecoder(registry))
+	r.MapTopic(cfg.KafkaTopic, EventOrderSnapshot)
+	for _, t := range cfg.KafkaTopics {
+		if name, eventType, ok := strings.Cut(t, "="); ok {
+			r.MapTopic(name, eventType)
+		}
+	}
+	return r
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"wb-test-task/config"
+)
+
+// TLSConfig собирает клиентский TLS-конфиг: caFile — корневые сертификаты
+// брокера (пусто — системные), certFile/keyFile — клиентский сертификат для mTLS.
+func TLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// SASLMechanism возвращает механизм по имени: plain, scram-sha-256, scram-sha-512.
+func SASLMechanism(mechanism, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(mechanism) {
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism %q", mechanism)
+	}
+}
+
+// NewDialer возвращает dialer для reader'а; nil-аргументы — без TLS и без аутентификации.
+func NewDialer(tlsCfg *tls.Config, mech sasl.Mechanism) *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		TLS:           tlsCfg,
+		SASLMechanism: mech,
+	}
+}
+
+func newTransport(cc ConsumerConfig) *kafka.Transport {
+	transport := &kafka.Transport{}
+	if cc.Dialer != nil {
+		transport.TLS = cc.Dialer.TLS
+		transport.SASL = cc.Dialer.SASLMechanism
+	}
+	return transport
+}
+
+// dialerFromConfig собирает TLS и SASL из конфига; nil — обычное соединение.
+func dialerFromConfig(cfg *config.Config) (*kafka.Dialer, error) {
+	var tlsCfg *tls.Config
+	if cfg.KafkaTLS || cfg.KafkaTLSCA != "" || cfg.KafkaTLSCert != "" || cfg.KafkaTLSInsecure {
+		var err error
+		if tlsCfg, err = TLSConfig(cfg.KafkaTLSCA, cfg.KafkaTLSCert, cfg.KafkaTLSKey, cfg.KafkaTLSInsecure); err != nil {
+			return nil, fmt.Errorf("kafka tls: %w", err)
+		}
+		if cfg.KafkaTLSInsecure {
+			log.Printf("[kafka] TLS certificate verification is disabled")
+		}
+	}
+	var mech sasl.Mechanism
+	if cfg.KafkaSASLMechanism != "" {
+		var err error
+		if mech, err = SASLMechanism(cfg.KafkaSASLMechanism, cfg.KafkaSASLUsername, cfg.KafkaSASLPassword); err != nil {
+			return nil, fmt.Errorf("kafka sasl: %w", err)
+		}
+		if tlsCfg == nil && strings.EqualFold(cfg.KafkaSASLMechanism, "plain") {
+			log.Printf("[kafka] SASL PLAIN without TLS sends the password in clear text")
+		}
+	}
+	if tlsCfg == nil && mech == nil {
+		return nil, nil
+	}
+	return NewDialer(tlsCfg, mech), nil
+}
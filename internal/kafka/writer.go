@@ -0,0 +1,15 @@
+package kafka
+
+import "github.com/segmentio/kafka-go"
+
+// NewWriter создаёт продюсера в тот же кластер и с теми же TLS/SASL, что у консьюмера.
+// Партиция выбирается по ключу сообщения, чтобы сообщения одного заказа шли по порядку.
+func NewWriter(cc ConsumerConfig, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(cc.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		Transport:    newTransport(cc),
+		RequiredAcks: kafka.RequireAll,
+	}
+}
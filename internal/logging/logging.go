@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// level общий для всех обработчиков, поэтому его можно менять на лету.
+var level = new(slog.LevelVar)
+
+// Setup делает slog логгером по умолчанию; стандартный log.Printf идёт через
+// него же с уровнем info.
+func Setup(lvl, format string) error {
+	if err := SetLevel(lvl); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// SetLevel меняет уровень логирования без перезапуска.
+func SetLevel(lvl string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		return fmt.Errorf("unknown log level %q", lvl)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level возвращает текущий уровень ("debug", "info", ...).
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
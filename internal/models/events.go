@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// События, которые приходят в Kafka помимо полных снимков заказа.
+
+// OrderStatusEvent меняет статус товаров заказа; пустой ChrtIDs — всех товаров.
+type OrderStatusEvent struct {
+	OrderUID string `json:"order_uid" validate:"required"`
+	Status   int    `json:"status" validate:"required"`
+	ChrtIDs  []int  `json:"chrt_ids,omitempty" validate:"dive,gt=0"`
+}
+
+// OrderCancelledEvent — заказ отменён и больше не показывается.
+type OrderCancelledEvent struct {
+	OrderUID string `json:"order_uid" validate:"required"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// DeliveryTrackingEvent — заказу и его товарам назначен новый трек-номер.
+type DeliveryTrackingEvent struct {
+	OrderUID    string `json:"order_uid" validate:"required"`
+	TrackNumber string `json:"track_number" validate:"required"`
+}
+
+// OrderStoredEvent публикуется через outbox после того, как снимок заказа сохранён в БД.
+type OrderStoredEvent struct {
+	OrderUID    string    `json:"order_uid"`
+	TrackNumber string    `json:"track_number"`
+	CustomerID  string    `json:"customer_id"`
+	DateCreated time.Time `json:"date_created"`
+	StoredAt    time.Time `json:"stored_at"`
+}
+
+// OrderUpdatedEvent — у заказа сменился статус товаров или трек-номер; заполнены только изменённые поля.
+type OrderUpdatedEvent struct {
+	OrderUID    string    `json:"order_uid"`
+	Status      *int      `json:"status,omitempty"`
+	ChrtIDs     []int     `json:"chrt_ids,omitempty"`
+	TrackNumber string    `json:"track_number,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OrderDeletedEvent — заказ удалён (отменён).
+type OrderDeletedEvent struct {
+	OrderUID  string    `json:"order_uid"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// OrderSummary — краткая сводка сохранённого заказа для живой ленты.
+type OrderSummary struct {
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Locale          string    `json:"locale"`
+	City            string    `json:"city"`
+	Amount          int       `json:"amount"`
+	Currency        string    `json:"currency"`
+	ItemsCount      int       `json:"items_count"`
+	DateCreated     time.Time `json:"date_created"`
+}
+
+func NewOrderSummary(o Order) OrderSummary {
+	return OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		CustomerID:      o.CustomerID,
+		DeliveryService: o.DeliveryService,
+		Locale:          o.Locale,
+		City:            o.Delivery.City,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		ItemsCount:      len(o.Items),
+		DateCreated:     o.DateCreated,
+	}
+}
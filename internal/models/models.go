@@ -1,6 +1,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 )
 
@@ -8,8 +9,8 @@ type Order struct {
 	OrderUID          string    `json:"order_uid" db:"order_uid" validate:"required,len=50"`
 	TrackNumber       string    `json:"track_number" db:"track_number" validate:"required,len=50"`
 	Entry             string    `json:"entry" db:"entry" validate:"required"`
-	Delivery          Delivery  `json:"delivery" db:"-" validate:"required,dive"`
-	Payment           Payment   `json:"payment" db:"-" validate:"required,dive"`
+	Delivery          Delivery  `json:"delivery" db:"-" validate:"required"`
+	Payment           Payment   `json:"payment" db:"-" validate:"required"`
 	Items             []Item    `json:"items" db:"-" validate:"required,min=1,dive"`
 	Locale            string    `json:"locale" db:"locale" validate:"required,oneof=ru en"`
 	InternalSignature string    `json:"internal_signature" db:"internal_signature" validate:"required"`
@@ -19,10 +20,23 @@ type Order struct {
 	SMID              int       `json:"sm_id" db:"sm_id" validate:"required"`
 	DateCreated       time.Time `json:"date_created" db:"date_created" validate:"required"`
 	OOFShard          string    `json:"oof_shard" db:"oof_shard" validate:"required"`
+
+	// UpdatedAt — время последнего изменения в БД; в JSON не входит, отдаётся заголовком Last-Modified
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+// Clone возвращает копию заказа, не разделяющую с ним память.
+func (o *Order) Clone() *Order {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	c.Items = slices.Clone(o.Items)
+	return &c
 }
 
 type Delivery struct {
-	OrderUID string `json:"-" db:"order_uid" validate:"required, len=50"`
+	OrderUID string `json:"-" db:"order_uid"`
 	Name     string `json:"name" db:"name" validate:"required"`
 	Phone    string `json:"phone" db:"phone" validate:"required,e164|len=20"`
 	Zip      string `json:"zip" db:"zip" validate:"required"`
@@ -47,7 +61,7 @@ type Payment struct {
 }
 
 type Item struct {
-	OrderUID    string `json:"-" db:"order_uid" validate:"required,len=50"`
+	OrderUID    string `json:"-" db:"order_uid"`
 	ChrtID      int    `json:"chrt_id" db:"chrt_id" validate:"required,gt=0"`
 	TrackNumber string `json:"track_number" db:"track_number" validate:"required"`
 	Price       int    `json:"price" db:"price" validate:"required,gte=0"`
@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/ports"
+)
+
+// Config — параметры relay; нулевые значения заменяются значениями по умолчанию.
+type Config struct {
+	Topic        string
+	BatchSize    int           // по умолчанию 100
+	PollInterval time.Duration // по умолчанию 1s
+	MinBackoff   time.Duration // задержка первого повтора, дальше удваивается; по умолчанию 1s
+	MaxBackoff   time.Duration // по умолчанию 5m
+}
+
+// Relay перекладывает события из outbox в Kafka. Доставка at-least-once:
+// запись удаляется только после подтверждения брокера, поэтому при сбое
+// между отправкой и удалением событие уйдёт ещё раз с тем же event_id.
+// Ключ сообщения — UID заказа, так что события одного заказа попадают
+// в одну партицию и идут по порядку.
+type Relay struct {
+	store  ports.Outbox
+	writer kafka.MessageWriter
+	cfg    Config
+}
+
+func NewRelay(store ports.Outbox, writer kafka.MessageWriter, cfg Config) *Relay {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(5*time.Minute, cfg.MinBackoff)
+	}
+	return &Relay{store: store, writer: writer, cfg: cfg}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	log.Printf("[outbox] relay started (topic=%s)", r.cfg.Topic)
+	t := time.NewTicker(r.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		// полная пачка — скорее всего есть ещё, забираем без ожидания
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[outbox] %v", err)
+			}
+			if err != nil || n < r.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("[outbox] relay stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Flush отправляет одну пачку и возвращает число отправленных событий.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	return r.store.ProcessOutbox(ctx, r.cfg.BatchSize, r.publish, r.retryAt)
+}
+
+func (r *Relay) publish(ctx context.Context, recs []ports.OutboxRecord) []error {
+	errs := make([]error, len(recs))
+	msgs := make([]kafkago.Message, 0, len(recs))
+	idx := make([]int, 0, len(recs))
+	for i, rec := range recs {
+		msg, err := message(rec)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		msgs = append(msgs, msg)
+		idx = append(idx, i)
+	}
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	err := r.writer.WriteMessages(ctx, msgs...)
+	var werrs kafkago.WriteErrors
+	switch {
+	case err == nil:
+	case errors.As(err, &werrs) && len(werrs) == len(msgs):
+		for j, i := range idx {
+			errs[i] = werrs[j]
+		}
+	default:
+		for _, i := range idx {
+			errs[i] = err
+		}
+	}
+	for i, rec := range recs {
+		if errs[i] != nil {
+			log.Printf("[outbox] publish %s %s (attempt %d): %v", rec.EventType, rec.AggregateID, rec.Attempts+1, errs[i])
+		}
+	}
+	return errs
+}
+
+// message упаковывает запись в конверт; event_id одинаков при повторах,
+// поэтому получатели могут отсеять дубли по нему или по idempotency-key.
+func message(rec ports.OutboxRecord) (kafkago.Message, error) {
+	value, err := json.Marshal(kafka.Envelope{
+		SchemaVersion: 1,
+		EventID:       rec.EventID,
+		ProducedAt:    rec.CreatedAt,
+		Payload:       rec.Payload,
+	})
+	if err != nil {
+		return kafkago.Message{}, err
+	}
+	return kafkago.Message{
+		Key:   []byte(rec.AggregateID),
+		Value: value,
+		Headers: []kafkago.Header{
+			{Key: kafka.EventTypeHeader, Value: []byte(rec.EventType)},
+			{Key: kafka.IdempotencyKeyHeader, Value: []byte(rec.EventID)},
+		},
+	}, nil
+}
+
+// retryAt — экспоненциальная задержка: MinBackoff, 2×, 4×, … но не больше MaxBackoff.
+func (r *Relay) retryAt(attempts int) time.Time {
+	d := r.cfg.MinBackoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return time.Now().Add(min(d, r.cfg.MaxBackoff))
+}
@@ -0,0 +1,123 @@
+package peers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// OrderPath — внутренний эндпоинт, через который реплики отдают друг другу заказы.
+const OrderPath = "/internal/peers/orders/"
+
+// Repository — ports.OrderRepository в духе groupcache: промах по UID
+// сначала идёт к реплике-владельцу ключа, и только если это мы сами
+// или владелец недоступен — в локальный репозиторий (Postgres).
+// Одновременные промахи по одному UID схлопываются в один запрос.
+type Repository struct {
+	local   ports.OrderRepository
+	ring    *Ring
+	self    string
+	client  *http.Client
+	timeout time.Duration
+	group   singleflight.Group
+}
+
+// NewRepository: self и peers — базовые URL реплик (http://host:port), self входит в кольцо.
+func NewRepository(local ports.OrderRepository, self string, peers []string, timeout time.Duration) *Repository {
+	self = strings.TrimRight(self, "/")
+	all := []string{self}
+	for _, p := range peers {
+		if p = strings.TrimRight(strings.TrimSpace(p), "/"); p != "" && p != self {
+			all = append(all, p)
+		}
+	}
+	if timeout <= 0 {
+		timeout = 500 * time.Millisecond
+	}
+	return &Repository{
+		local:   local,
+		ring:    NewRing(0, all...),
+		self:    self,
+		client:  &http.Client{},
+		timeout: timeout,
+	}
+}
+
+func (r *Repository) SaveOrder(ctx context.Context, o models.Order) error {
+	return r.local.SaveOrder(ctx, o)
+}
+
+// GetOrder: общий запрос не зависит от отмены контекста того, кто его начал,
+// иначе ушедший клиент оборвал бы его и всем остальным. Каждый вызывающий
+// при этом ждёт результата не дольше своего ctx.
+func (r *Repository) GetOrder(ctx context.Context, uid string) (*models.Order, error) {
+	shared := context.WithoutCancel(ctx)
+	ch := r.group.DoChan(uid, func() (any, error) {
+		owner := r.ring.Owner(uid)
+		if owner == "" || owner == r.self {
+			return r.local.GetOrder(shared, uid)
+		}
+
+		fetchCtx, cancel := context.WithTimeout(shared, r.timeout)
+		defer cancel()
+		o, err := r.fetch(fetchCtx, owner, uid)
+		if err == nil {
+			return o, nil
+		}
+		if errors.Is(err, ports.ErrOrderNotFound) {
+			return nil, err
+		}
+		log.Printf("[peers] %s unavailable for %s, falling back to local repo: %v", owner, uid, err)
+		return r.local.GetOrder(shared, uid)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*models.Order), nil
+	}
+}
+
+func (r *Repository) fetch(ctx context.Context, peer, uid string) (*models.Order, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+OrderPath+url.PathEscape(uid), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("peer %s: %w", peer, ports.ErrOrderNotFound)
+	default:
+		return nil, fmt.Errorf("peer status %d", resp.StatusCode)
+	}
+
+	var o models.Order
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return nil, fmt.Errorf("decode peer response: %w", err)
+	}
+	// UpdatedAt в JSON не входит, реплика передаёт его в Last-Modified
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		o.UpdatedAt = t
+	}
+	return &o, nil
+}
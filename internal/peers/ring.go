@@ -0,0 +1,44 @@
+package peers
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Ring — консистентное хеширование с виртуальными узлами:
+// при добавлении/удалении реплики переезжает лишь ~1/N ключей.
+type Ring struct {
+	replicas int
+	hashes   []uint32
+	owners   map[uint32]string
+}
+
+func NewRing(replicas int, peers ...string) *Ring {
+	if replicas <= 0 {
+		replicas = 50
+	}
+	r := &Ring{replicas: replicas, owners: make(map[uint32]string)}
+	for _, p := range peers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + p))
+			r.hashes = append(r.hashes, h)
+			r.owners[h] = p
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// Owner возвращает реплику, отвечающую за ключ, или "" для пустого кольца.
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
@@ -2,11 +2,132 @@ package ports
 
 import (
 	"context"
+	"errors"
+	"time"
 	"wb-test-task/internal/models"
 )
 
+// ErrOrderNotFound возвращают репозитории, когда заказа с таким UID нет.
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderExists — заказ с таким UID уже сохранён (например, повтор снимка).
+var ErrOrderExists = errors.New("order already exists")
+
+// ErrOrderRejected — БД отвергла данные заказа (ограничения, формат значений);
+// повтор той же записи не поможет.
+var ErrOrderRejected = errors.New("order rejected by database")
+
 type OrderRepository interface {
 	SaveOrder(ctx context.Context, o models.Order) error
 	GetOrder(ctx context.Context, uid string) (*models.Order, error)
 	// PreloadCache(ctx context.Context) ([]models.Order, error) // больше не нужен
 }
+
+// OrderParts — какие связанные сущности загружать вместе с заголовком заказа.
+type OrderParts struct {
+	Delivery bool
+	Payment  bool
+	Items    bool
+}
+
+// AllOrderParts — заказ целиком.
+var AllOrderParts = OrderParts{Delivery: true, Payment: true, Items: true}
+
+// PartialOrderRepository реализуют репозитории, умеющие не читать лишнее.
+// Незагруженные части остаются нулевыми.
+type PartialOrderRepository interface {
+	GetOrderParts(ctx context.Context, uid string, parts OrderParts) (*models.Order, error)
+}
+
+// BatchOrderRepository реализуют репозитории, читающие много заказов за один
+// запрос. Отсутствующих заказов в результате нет.
+type BatchOrderRepository interface {
+	GetOrders(ctx context.Context, uids []string) (map[string]*models.Order, error)
+}
+
+// OrderFilter отбирает заказы для выгрузки; пустое поле не ограничивает.
+// Внутри списка значения объединяются через ИЛИ, между полями — через И.
+type OrderFilter struct {
+	CustomerIDs      []string
+	DeliveryServices []string
+	Locales          []string
+	CreatedFrom      time.Time // date_created >= CreatedFrom
+	CreatedTo        time.Time // date_created < CreatedTo
+}
+
+// OrderExporter читает подходящие заказы потоком, не собирая их в памяти.
+// Заказы идут по date_created, затем по UID; ошибка fn прерывает чтение.
+type OrderExporter interface {
+	ExportOrders(ctx context.Context, filter OrderFilter, parts OrderParts, fn func(*models.Order) error) error
+}
+
+// OrderUpdater — точечные изменения заказа по событиям из Kafka.
+// Для несуществующего заказа методы возвращают ErrOrderNotFound.
+type OrderUpdater interface {
+	UpdateItemsStatus(ctx context.Context, uid string, status int, chrtIDs []int) error
+	UpdateTrackNumber(ctx context.Context, uid, trackNumber string) error
+	DeleteOrder(ctx context.Context, uid string) error
+}
+
+// ProcessedMessages — журнал обработанных сообщений Kafka для отсева дублей.
+type ProcessedMessages interface {
+	// SeenSince сообщает, обрабатывалось ли сообщение с ключом key после since.
+	SeenSince(ctx context.Context, key string, since time.Time) (bool, error)
+	MarkProcessed(ctx context.Context, key string, at time.Time) error
+	// PurgeProcessed удаляет записи старше before и возвращает их число.
+	PurgeProcessed(ctx context.Context, before time.Time) (int64, error)
+}
+
+// OutboxRecord — событие, записанное в outbox в одной транзакции с изменением заказа.
+type OutboxRecord struct {
+	ID          int64
+	EventID     string
+	AggregateID string // UID заказа: события одного заказа отправляются по порядку
+	EventType   string
+	Payload     []byte
+	CreatedAt   time.Time
+	Attempts    int
+}
+
+// Outbox — очередь исходящих событий в БД.
+type Outbox interface {
+	// ProcessOutbox берёт до limit готовых к отправке записей (у каждого заказа —
+	// только самую раннюю) и передаёт их publish. Записи, для которых publish вернул
+	// nil, удаляются, остальным назначается повтор в retryAt(attempts).
+	// Возвращает число отправленных записей.
+	ProcessOutbox(ctx context.Context, limit int, publish func(ctx context.Context, recs []OutboxRecord) []error, retryAt func(attempts int) time.Time) (int, error)
+}
+
+// ErrSubscriptionNotFound возвращают хранилища вебхуков для неизвестного ID.
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+// ErrDeliveryNotFound — у подписки нет доставки с таким ID.
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// WebhookAttempt — итог попытки доставки. Counted = false, если запрос не
+// отправлялся (открыт предохранитель): такая попытка не увеличивает счётчик.
+type WebhookAttempt struct {
+	Status        string // models.WebhookPending / WebhookDelivered / WebhookFailed
+	StatusCode    int
+	Error         string
+	Counted       bool
+	NextAttemptAt time.Time
+}
+
+// Webhooks — подписки на вебхуки и очередь их доставок.
+type Webhooks interface {
+	CreateSubscription(ctx context.Context, s *models.WebhookSubscription) error
+	Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	Subscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, s *models.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id int64) error
+
+	// Deliveries возвращает последние limit доставок подписки, новые первыми;
+	// пустой status — все статусы.
+	Deliveries(ctx context.Context, subscriptionID int64, status string, limit int) ([]models.WebhookDelivery, error)
+	// Redeliver возвращает доставку в очередь с обнулённым счётчиком попыток.
+	Redeliver(ctx context.Context, subscriptionID, deliveryID int64) error
+	// ProcessDeliveries берёт до limit доставок, которым пора уйти, передаёт их
+	// send и записывает результаты. Возвращает число доставленных.
+	ProcessDeliveries(ctx context.Context, limit int, send func(ctx context.Context, ds []models.WebhookDelivery) []WebhookAttempt) (int, error)
+}
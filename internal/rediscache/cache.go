@@ -0,0 +1,98 @@
+package rediscache
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"wb-test-task/internal/models"
+)
+
+// Cache — ports.Cache поверх Redis. Интерфейс порта не возвращает ошибок,
+// поэтому сбои Redis логируются и трактуются как промах.
+type Cache struct {
+	client  *Client
+	prefix  string
+	ttl     atomic.Int64
+	timeout time.Duration
+}
+
+func New(client *Client, prefix string, ttl time.Duration) *Cache {
+	c := &Cache{client: client, prefix: prefix, timeout: 200 * time.Millisecond}
+	c.ttl.Store(int64(ttl))
+	return c
+}
+
+func (c *Cache) SetTTL(ttl time.Duration) { c.ttl.Store(int64(ttl)) }
+
+func (c *Cache) key(uid string) string { return c.prefix + uid }
+
+func (c *Cache) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+func (c *Cache) Get(uid string) (*models.Order, bool) {
+	raw, ok := c.getRaw(uid)
+	if !ok {
+		return nil, false
+	}
+	o, err := DecodeOrder(raw)
+	if err != nil {
+		log.Printf("[redis] decode %s: %v", uid, err)
+		return nil, false
+	}
+	return o, true
+}
+
+// getRaw возвращает закодированный заказ как есть.
+func (c *Cache) getRaw(uid string) ([]byte, bool) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	reply, err := c.client.Do(ctx, "GET", c.key(uid))
+	if err != nil {
+		log.Printf("[redis] get %s: %v", uid, err)
+		return nil, false
+	}
+	raw, ok := reply.(string)
+	if !ok {
+		return nil, false
+	}
+	return []byte(raw), true
+}
+
+func (c *Cache) Set(uid string, o *models.Order) {
+	c.set(uid, o)
+}
+
+// Add записывает заказ, только если ключа ещё нет (SET NX), — чтобы
+// прочитанная из БД копия не затёрла более свежую запись консьюмера.
+func (c *Cache) Add(uid string, o *models.Order) {
+	c.set(uid, o, "NX")
+}
+
+func (c *Cache) set(uid string, o *models.Order, opts ...any) {
+	if o == nil {
+		return
+	}
+	ctx, cancel := c.ctx()
+	defer cancel()
+
+	args := []any{"SET", c.key(uid), EncodeOrder(o)}
+	if ttl := time.Duration(c.ttl.Load()); ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	args = append(args, opts...)
+	if _, err := c.client.Do(ctx, args...); err != nil {
+		log.Printf("[redis] set %s: %v", uid, err)
+	}
+}
+
+func (c *Cache) Delete(uid string) {
+	ctx, cancel := c.ctx()
+	defer cancel()
+	if _, err := c.client.Do(ctx, "DEL", c.key(uid)); err != nil {
+		log.Printf("[redis] del %s: %v", uid, err)
+	}
+}
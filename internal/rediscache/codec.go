@@ -0,0 +1,191 @@
+package rediscache
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"wb-test-task/internal/models"
+)
+
+// Компактная бинарная кодировка заказа: байт версии, затем поля в порядке
+// объявления в models. Строки — uvarint-длина + байты, числа — varint.
+// Добавляя поле, повышайте codecVersion: старые значения тогда просто не декодируются
+// и считаются промахом.
+const codecVersion = 2
+
+var errCorrupt = errors.New("rediscache: corrupt order encoding")
+
+func EncodeOrder(o *models.Order) []byte {
+	e := encoder{buf: make([]byte, 0, 512)}
+	e.buf = append(e.buf, codecVersion)
+
+	e.str(o.OrderUID)
+	e.str(o.TrackNumber)
+	e.str(o.Entry)
+
+	d := &o.Delivery
+	e.str(d.Name)
+	e.str(d.Phone)
+	e.str(d.Zip)
+	e.str(d.City)
+	e.str(d.Address)
+	e.str(d.Region)
+	e.str(d.Email)
+
+	p := &o.Payment
+	e.str(p.Transaction)
+	e.str(p.RequestID)
+	e.str(p.Currency)
+	e.str(p.Provider)
+	e.int(int64(p.Amount))
+	e.int(p.PaymentDT)
+	e.str(p.Bank)
+	e.int(int64(p.DeliveryCost))
+	e.int(int64(p.GoodsTotal))
+	e.int(int64(p.CustomFee))
+
+	e.int(int64(len(o.Items)))
+	for i := range o.Items {
+		it := &o.Items[i]
+		e.int(int64(it.ChrtID))
+		e.str(it.TrackNumber)
+		e.int(int64(it.Price))
+		e.str(it.RID)
+		e.str(it.Name)
+		e.int(int64(it.Sale))
+		e.str(it.Size)
+		e.int(int64(it.TotalPrice))
+		e.int(int64(it.NMID))
+		e.str(it.Brand)
+		e.int(int64(it.Status))
+	}
+
+	e.str(o.Locale)
+	e.str(o.InternalSignature)
+	e.str(o.CustomerID)
+	e.str(o.DeliveryService)
+	e.str(o.ShardKey)
+	e.int(int64(o.SMID))
+	e.int(o.DateCreated.UnixNano())
+	e.str(o.OOFShard)
+	e.time(o.UpdatedAt)
+	return e.buf
+}
+
+func DecodeOrder(b []byte) (*models.Order, error) {
+	if len(b) == 0 || b[0] != codecVersion {
+		return nil, errCorrupt
+	}
+	d := decoder{buf: b[1:]}
+	o := &models.Order{}
+
+	o.OrderUID = d.str()
+	o.TrackNumber = d.str()
+	o.Entry = d.str()
+
+	o.Delivery.Name = d.str()
+	o.Delivery.Phone = d.str()
+	o.Delivery.Zip = d.str()
+	o.Delivery.City = d.str()
+	o.Delivery.Address = d.str()
+	o.Delivery.Region = d.str()
+	o.Delivery.Email = d.str()
+
+	o.Payment.Transaction = d.str()
+	o.Payment.RequestID = d.str()
+	o.Payment.Currency = d.str()
+	o.Payment.Provider = d.str()
+	o.Payment.Amount = int(d.int())
+	o.Payment.PaymentDT = d.int()
+	o.Payment.Bank = d.str()
+	o.Payment.DeliveryCost = int(d.int())
+	o.Payment.GoodsTotal = int(d.int())
+	o.Payment.CustomFee = int(d.int())
+
+	n := d.int()
+	if n < 0 || n > int64(len(d.buf)) {
+		return nil, errCorrupt
+	}
+	o.Items = make([]models.Item, n)
+	for i := range o.Items {
+		it := &o.Items[i]
+		it.ChrtID = int(d.int())
+		it.TrackNumber = d.str()
+		it.Price = int(d.int())
+		it.RID = d.str()
+		it.Name = d.str()
+		it.Sale = int(d.int())
+		it.Size = d.str()
+		it.TotalPrice = int(d.int())
+		it.NMID = int(d.int())
+		it.Brand = d.str()
+		it.Status = int(d.int())
+	}
+
+	o.Locale = d.str()
+	o.InternalSignature = d.str()
+	o.CustomerID = d.str()
+	o.DeliveryService = d.str()
+	o.ShardKey = d.str()
+	o.SMID = int(d.int())
+	o.DateCreated = time.Unix(0, d.int()).UTC()
+	o.OOFShard = d.str()
+	o.UpdatedAt = d.time()
+
+	if d.err || len(d.buf) != 0 {
+		return nil, errCorrupt
+	}
+	return o, nil
+}
+
+type encoder struct{ buf []byte }
+
+func (e *encoder) str(s string) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) int(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+// time пишет нулевое время как 0: UnixNano для него не определён.
+func (e *encoder) time(t time.Time) {
+	if t.IsZero() {
+		e.int(0)
+		return
+	}
+	e.int(t.UnixNano())
+}
+
+type decoder struct {
+	buf []byte
+	err bool
+}
+
+func (d *decoder) str() string {
+	n, k := binary.Uvarint(d.buf)
+	if k <= 0 || uint64(len(d.buf)-k) < n {
+		d.err, d.buf = true, nil
+		return ""
+	}
+	s := string(d.buf[k : k+int(n)])
+	d.buf = d.buf[k+int(n):]
+	return s
+}
+
+func (d *decoder) int() int64 {
+	v, k := binary.Varint(d.buf)
+	if k <= 0 {
+		d.err, d.buf = true, nil
+		return 0
+	}
+	d.buf = d.buf[k:]
+	return v
+}
+
+func (d *decoder) time() time.Time {
+	if v := d.int(); v != 0 {
+		return time.Unix(0, v).UTC()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,220 @@
+package rediscache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Минимальный клиент протокола RESP2: ровно то, что нужно кэшу
+// (GET/SET/DEL/PUBLISH/SUBSCRIBE), без внешних зависимостей.
+
+// Error — ответ сервера вида "-ERR ...".
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+type Options struct {
+	Addr        string
+	Password    string
+	DB          int
+	PoolSize    int
+	DialTimeout time.Duration
+}
+
+type Client struct {
+	opts Options
+	pool chan *conn
+}
+
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 8
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 3 * time.Second
+	}
+	return &Client{opts: opts, pool: make(chan *conn, opts.PoolSize)}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{Timeout: c.opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis dial: %w", err)
+	}
+	cn := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	if c.opts.Password != "" {
+		if _, err := cn.do(ctx, "AUTH", c.opts.Password); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.do(ctx, "SELECT", c.opts.DB); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+// Do выполняет команду на соединении из пула. Ответ — string, int64, []any или nil.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	var cn *conn
+	select {
+	case cn = <-c.pool:
+	default:
+		var err error
+		if cn, err = c.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := cn.do(ctx, args...)
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		cn.nc.Close()
+		return nil, err
+	}
+
+	select {
+	case c.pool <- cn:
+	default:
+		cn.nc.Close()
+	}
+	return reply, err
+}
+
+// Subscribe держит отдельное соединение и вызывает fn на каждое сообщение канала,
+// пока не отменён ctx или не порвалось соединение.
+func (c *Client) Subscribe(ctx context.Context, channel string, fn func(payload string)) error {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer cn.nc.Close()
+	stop := context.AfterFunc(ctx, func() { cn.nc.Close() })
+	defer stop()
+
+	if err := cn.write("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	for {
+		reply, err := cn.read()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		msg, ok := reply.([]any)
+		if !ok || len(msg) != 3 {
+			continue
+		}
+		if kind, _ := msg[0].(string); kind == "message" {
+			payload, _ := msg[2].(string)
+			fn(payload)
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.nc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (cn *conn) do(ctx context.Context, args ...any) (any, error) {
+	if dl, ok := ctx.Deadline(); ok {
+		_ = cn.nc.SetDeadline(dl)
+		defer cn.nc.SetDeadline(time.Time{})
+	}
+	if err := cn.write(args...); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+func (cn *conn) write(args ...any) error {
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		var b []byte
+		switch v := a.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", a)
+		}
+		fmt.Fprintf(cn.w, "$%d\r\n", len(b))
+		cn.w.Write(b)
+		cn.w.WriteString("\r\n")
+	}
+	return cn.w.Flush()
+}
+
+func (cn *conn) read() (any, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("redis: short reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = cn.read(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,169 @@
+package rediscache
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// Tiered — двухуровневый кэш: L1 в процессе, L2 в Redis, общий для реплик.
+// Каждое изменение (Set, Delete) публикуется в канал Redis; остальные реплики
+// сбрасывают свою L1-копию, если её содержимое отличается от опубликованного
+// отпечатка. Заполнение из БД (Fill) ничего не публикует.
+type Tiered struct {
+	l1       ports.Cache[string, *models.Order]
+	l2       *Cache
+	client   *Client
+	channel  string
+	instance string
+}
+
+// deletedMark — отпечаток в сообщении об удалении: сбрасываем копию безусловно.
+const deletedMark = "-"
+
+func NewTiered(l1 ports.Cache[string, *models.Order], l2 *Cache, client *Client, channel, instance string) *Tiered {
+	return &Tiered{l1: l1, l2: l2, client: client, channel: channel, instance: instance}
+}
+
+func (t *Tiered) Get(uid string) (*models.Order, bool) {
+	if o, ok := t.l1.Get(uid); ok && o != nil {
+		return o, true
+	}
+	o, ok := t.l2.Get(uid)
+	if !ok {
+		return nil, false
+	}
+	t.l1.Set(uid, o)
+	return o, true
+}
+
+// Set записывает изменение. Если в L2 уже то же значение (повтор события),
+// реплики его видели — ни запись в Redis, ни публикация не нужны.
+func (t *Tiered) Set(uid string, o *models.Order) {
+	t.l1.Set(uid, o)
+	sum := fingerprint(o)
+	if raw, ok := t.l2.getRaw(uid); ok && checksum(raw) == sum {
+		return
+	}
+	t.l2.Set(uid, o)
+	t.publish(uid, sum)
+}
+
+// Fill кладёт заказ, прочитанный из БД (прогрев, промах, перечитывание после
+// LISTEN): в L2 — только если там пусто, без оповещения реплик.
+func (t *Tiered) Fill(uid string, o *models.Order) {
+	t.l1.Set(uid, o)
+	t.l2.Add(uid, o)
+}
+
+func (t *Tiered) Delete(uid string) {
+	t.l1.Delete(uid)
+	t.l2.Delete(uid)
+	t.publish(uid, deletedMark)
+}
+
+// GetEncoded пробрасывает предсериализованные ответы L1 (cache.EncodedCache),
+// подтягивая заказ из L2 при промахе.
+func (t *Tiered) GetEncoded(uid string) (*cache.Encoded[*models.Order], bool) {
+	src, ok := t.l1.(interface {
+		GetEncoded(string) (*cache.Encoded[*models.Order], bool)
+	})
+	if !ok {
+		return nil, false
+	}
+	if e, ok := src.GetEncoded(uid); ok {
+		return e, true
+	}
+	if _, ok := t.Get(uid); !ok {
+		return nil, false
+	}
+	return src.GetEncoded(uid)
+}
+
+func (t *Tiered) Snapshot() []cache.SnapshotEntry[*models.Order] {
+	if s, ok := t.l1.(cache.Snapshotter[*models.Order]); ok {
+		return s.Snapshot()
+	}
+	return nil
+}
+
+func (t *Tiered) Restore(entries []cache.SnapshotEntry[*models.Order]) {
+	if s, ok := t.l1.(cache.Snapshotter[*models.Order]); ok {
+		s.Restore(entries)
+	}
+}
+
+// Resize и SetTTL меняют только локальный L1; TTL в Redis задаётся при записи.
+func (t *Tiered) Resize(capacity int) {
+	if c, ok := t.l1.(cache.Tunable); ok {
+		c.Resize(capacity)
+	}
+}
+
+func (t *Tiered) SetTTL(ttl time.Duration) {
+	if c, ok := t.l1.(cache.Tunable); ok {
+		c.SetTTL(ttl)
+	}
+	t.l2.SetTTL(ttl)
+}
+
+func (t *Tiered) publish(uid, sum string) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.l2.timeout)
+	defer cancel()
+	msg := t.instance + " " + uid + " " + sum
+	if _, err := t.client.Do(ctx, "PUBLISH", t.channel, msg); err != nil {
+		log.Printf("[redis] publish invalidation %s: %v", uid, err)
+	}
+}
+
+// Listen слушает канал инвалидаций до отмены ctx, переподключаясь при обрыве.
+func (t *Tiered) Listen(ctx context.Context) {
+	backoff := 100 * time.Millisecond
+	for {
+		err := t.client.Subscribe(ctx, t.channel, t.handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[redis] invalidation subscription lost: %v (retry in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+func (t *Tiered) handle(msg string) {
+	parts := strings.SplitN(msg, " ", 3)
+	if len(parts) != 3 || parts[0] == t.instance {
+		return
+	}
+	uid, sum := parts[1], parts[2]
+	if sum != deletedMark {
+		if o, ok := t.l1.Get(uid); !ok || fingerprint(o) == sum {
+			return
+		}
+	}
+	t.l1.Delete(uid)
+}
+
+func fingerprint(o *models.Order) string {
+	if o == nil {
+		return deletedMark
+	}
+	return checksum(EncodeOrder(o))
+}
+
+func checksum(raw []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(raw)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -2,15 +2,24 @@ package routes
 
 import (
 	"net/http"
+	"time"
+	apispec "wb-test-task/api"
+	"wb-test-task/internal/feed"
 	"wb-test-task/internal/handlers"
+	"wb-test-task/internal/peers"
+	"wb-test-task/internal/ports"
 	"wb-test-task/internal/service"
+	"wb-test-task/internal/tuning"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitRoutes(r *gin.Engine, svc *service.OrderService) *gin.Engine {
+// InitRoutes регистрирует страницу интерфейса и публичный API; cacheControl —
+// значение Cache-Control для ответов с заказом.
+func InitRoutes(r *gin.Engine, svc *service.OrderService, cacheControl string) *gin.Engine {
 
-	h := handlers.NewOrderHandler(svc)
+	h := handlers.NewOrderHandler(svc, cacheControl)
+	docs := handlers.NewDocsHandler(apispec.OpenAPI)
 
 	api := r.Group("/") // инициализируем роутер для API
 	{
@@ -19,10 +28,108 @@ func InitRoutes(r *gin.Engine, svc *service.OrderService) *gin.Engine {
 		})
 	}
 
-	order := r.Group("/order") // инициализируем роутер для заказов
+	v1 := r.Group("/api/v1") // версионированный API, контракт — api/openapi.json
 	{
-		order.GET("/:orderId", h.GetOrderByUID) // хендлер для поиска заказа по UID
+		v1.GET("/orders/:orderId", h.GetOrderByUID)
+		v1.GET("/openapi.json", docs.OpenAPI)
+		v1.GET("/docs", docs.Page)
 	}
 
+	// старый адрес оставлен для совместимости
+	order := r.Group("/order", handlers.Deprecated("/api/v1/orders"))
+	{
+		order.GET("/:orderId", h.GetOrderByUID)
+	}
+
+	return r
+}
+
+// InitStreamRoutes регистрирует живую ленту новых заказов (SSE).
+func InitStreamRoutes(r *gin.Engine, hub *feed.Hub, heartbeat, writeTimeout time.Duration) *gin.Engine {
+	h := handlers.NewStreamHandler(hub, heartbeat, writeTimeout)
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/orders/stream", h.StreamOrders)
+	}
+	return r
+}
+
+// InitBatchRoutes регистрирует пакетное чтение заказов: POST /api/v1/orders:batchGet.
+func InitBatchRoutes(r *gin.Engine, svc *service.OrderService, maxUIDs int) *gin.Engine {
+	h := handlers.NewBatchHandler(svc, maxUIDs)
+	v1 := r.Group("/api/v1")
+	{
+		v1.POST("/orders:method", h.OrderMethod)
+	}
+	return r
+}
+
+// InitExportRoutes регистрирует выгрузку заказов: GET /api/v1/orders/export.
+func InitExportRoutes(r *gin.Engine, src ports.OrderExporter, writeTimeout time.Duration) *gin.Engine {
+	h := handlers.NewExportHandler(src, writeTimeout)
+	v1 := r.Group("/api/v1")
+	{
+		v1.GET("/orders/export", h.ExportOrders)
+	}
+	return r
+}
+
+// InitHealthRoutes регистрирует пробы для оркестратора: /healthz отвечает,
+// пока процесс жив, /readyz — только после прогрева кэша.
+func InitHealthRoutes(r *gin.Engine, ready func() bool) *gin.Engine {
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/readyz", func(c *gin.Context) {
+		if !ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "warming up"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+	return r
+}
+
+// InitMetricsRoutes регистрирует /metrics для Prometheus. dedup может быть nil.
+func InitMetricsRoutes(r *gin.Engine, dedup handlers.DedupCounters) *gin.Engine {
+	h := handlers.NewMetricsHandler(dedup)
+	r.GET("/metrics", h.Metrics)
+	return r
+}
+
+// InitPeerRoutes регистрирует внутренний эндпоинт распределённого кэша.
+// localSvc должен читать из локального репозитория, а не из peers.Repository.
+func InitPeerRoutes(r *gin.Engine, localSvc *service.OrderService) *gin.Engine {
+	h := handlers.NewPeerHandler(localSvc)
+	r.GET(peers.OrderPath+":orderId", h.GetOrder)
+	return r
+}
+
+// InitAdminRoutes регистрирует служебные эндпоинты под /admin, защищённые токеном.
+// consumer может быть nil — тогда эндпоинтов /admin/kafka нет; hooks — тогда нет /admin/webhooks.
+func InitAdminRoutes(r *gin.Engine, tuner *tuning.Tuner, consumer handlers.ConsumerControl, hooks ports.Webhooks, circuits handlers.CircuitStates, token string) *gin.Engine {
+	h := handlers.NewAdminHandler(tuner)
+	admin := r.Group("/admin", handlers.RequireToken(token))
+	{
+		admin.GET("/runtime", h.GetRuntime)
+		admin.PATCH("/runtime", h.PatchRuntime)
+	}
+	if consumer != nil {
+		k := handlers.NewKafkaAdminHandler(tuner, consumer)
+		admin.GET("/kafka/consumer", k.GetConsumer)
+		admin.POST("/kafka/consumer/pause", k.Pause)
+		admin.POST("/kafka/consumer/resume", k.Resume)
+		admin.POST("/kafka/consumer/offsets/reset", k.ResetOffsets)
+	}
+	if hooks != nil {
+		w := handlers.NewWebhookAdminHandler(hooks, circuits)
+		admin.GET("/webhooks", w.ListSubscriptions)
+		admin.POST("/webhooks", w.CreateSubscription)
+		admin.GET("/webhooks/:id", w.GetSubscription)
+		admin.PATCH("/webhooks/:id", w.UpdateSubscription)
+		admin.DELETE("/webhooks/:id", w.DeleteSubscription)
+		admin.GET("/webhooks/:id/deliveries", w.ListDeliveries)
+		admin.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", w.Redeliver)
+	}
 	return r
 }
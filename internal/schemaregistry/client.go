@@ -0,0 +1,115 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы схем в ответах Confluent Schema Registry; пустой schemaType означает Avro.
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+)
+
+var (
+	// ErrNotFound — схемы с таким ID нет; повтор не поможет.
+	ErrNotFound = errors.New("schema not found")
+	// ErrUnavailable — реестр не ответил или ответил 5xx; имеет смысл повторить позже.
+	ErrUnavailable = errors.New("schema registry unavailable")
+)
+
+type Schema struct {
+	ID     int
+	Type   string
+	Schema string
+}
+
+type Options struct {
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client читает схемы по ID. Схема с данным ID в реестре неизменна,
+// поэтому найденные схемы кэшируются навсегда.
+type Client struct {
+	baseURL string
+	opts    Options
+	http    *http.Client
+
+	mu    sync.RWMutex
+	cache map[int]*Schema
+}
+
+func New(baseURL string, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		opts:    opts,
+		http:    &http.Client{Timeout: opts.Timeout},
+		cache:   make(map[int]*Schema),
+	}
+}
+
+// SchemaByID возвращает схему из кэша или запрашивает GET /schemas/ids/{id}.
+func (c *Client) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	c.mu.RLock()
+	s, ok := c.cache[id]
+	c.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/schemas/ids/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("schema %d: %w: %v", id, ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("schema %d: %w", id, ErrNotFound)
+	case resp.StatusCode >= 500:
+		return nil, fmt.Errorf("schema %d: %w: status %d", id, ErrUnavailable, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("schema %d: status %d: %s", id, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("schema %d: decode response: %w", id, err)
+	}
+	s = &Schema{ID: id, Type: body.SchemaType, Schema: body.Schema}
+	if s.Type == "" {
+		s.Type = TypeAvro
+	}
+
+	c.mu.Lock()
+	c.cache[id] = s
+	c.mu.Unlock()
+	return s, nil
+}
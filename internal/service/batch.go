@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// BatchResult — ответ по одному UID из пакета: либо Order, либо Err
+// (ports.ErrOrderNotFound, если заказа нет).
+type BatchResult struct {
+	UID   string
+	Order *EncodedOrder
+	Err   error
+}
+
+// GetOrdersBatch отдаёт заказы пакетом: сначала из кэша, затем все промахи
+// одним запросом к репозиторию. Результаты передаются emit по мере готовности,
+// по одному на каждый UID запроса, включая повторы; ошибка emit прерывает работу.
+func (s *OrderService) GetOrdersBatch(ctx context.Context, uids []string, emit func(BatchResult) error) error {
+	var unique, misses []string
+	pending := map[string]int{}
+	for _, uid := range uids {
+		if pending[uid]++; pending[uid] == 1 {
+			unique = append(unique, uid)
+		}
+	}
+	for _, uid := range unique {
+		e, ok, err := s.cached(uid)
+		if !ok {
+			misses = append(misses, uid)
+			continue
+		}
+		if err := emitAll(emit, pending, BatchResult{UID: uid, Order: e, Err: err}); err != nil {
+			return err
+		}
+	}
+	if len(misses) == 0 {
+		return nil
+	}
+
+	batch, ok := s.repo.(ports.BatchOrderRepository)
+	if !ok {
+		// например, peers.Repository: каждый заказ читается у своего владельца
+		for _, uid := range misses {
+			res := BatchResult{UID: uid}
+			res.Order, res.Err = s.GetOrderEncoded(ctx, uid)
+			if err := emitAll(emit, pending, res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	orders, err := batch.GetOrders(ctx, misses)
+	if err != nil {
+		err = fmt.Errorf("get orders from db: %w", err)
+	}
+	for _, uid := range misses {
+		res := BatchResult{UID: uid, Err: err}
+		if err == nil {
+			res.Order, res.Err = s.storeLoaded(uid, orders[uid])
+		}
+		if err := emitAll(emit, pending, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cached ищет заказ в кэше; ok = false — промах. Без готового JSON
+// (обычный кэш или Tiered поверх обычного L1) заказ кодируется на месте.
+func (s *OrderService) cached(uid string) (*EncodedOrder, bool, error) {
+	if src, hasEncoded := s.cache.(encodedSource); hasEncoded {
+		if e, ok := src.GetEncoded(uid); ok {
+			return e, true, nil
+		}
+	}
+	order, ok := s.cache.Get(uid)
+	if !ok || order == nil {
+		return nil, false, nil
+	}
+	e, err := cache.Encode(order, false)
+	if err != nil {
+		return nil, true, fmt.Errorf("encode order: %w", err)
+	}
+	return e, true, nil
+}
+
+// storeLoaded кладёт прочитанный из БД заказ в кэш и кодирует его.
+func (s *OrderService) storeLoaded(uid string, order *models.Order) (*EncodedOrder, error) {
+	if order == nil {
+		return nil, fmt.Errorf("get order from db: select order %s: %w", uid, ports.ErrOrderNotFound)
+	}
+	cache.Fill(s.cache, uid, order)
+	if src, hasEncoded := s.cache.(encodedSource); hasEncoded {
+		if e, ok := src.GetEncoded(uid); ok {
+			return e, nil
+		}
+	}
+	e, err := cache.Encode(order, false)
+	if err != nil {
+		return nil, fmt.Errorf("encode order: %w", err)
+	}
+	return e, nil
+}
+
+// emitAll отдаёт результат столько раз, сколько UID повторялся в запросе.
+func emitAll(emit func(BatchResult) error, pending map[string]int, res BatchResult) error {
+	for ; pending[res.UID] > 0; pending[res.UID]-- {
+		if err := emit(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// IncludeParts — связанные сущности, которые можно перечислить в ?include=.
+var IncludeParts = []string{"delivery", "payment", "items"}
+
+// fieldTree — дерево выбранных путей; nil-узел означает «поле целиком».
+type fieldTree map[string]fieldTree
+
+// Selection — какие поля заказа отдать: ?fields= ограничивает ответ путями
+// вида payment.amount, ?include= добавляет связанные сущности целиком.
+// Только с include в ответ попадают все поля заголовка и перечисленные сущности.
+type Selection struct {
+	tree fieldTree
+}
+
+// orderFields — допустимые пути по JSON-тегам models.Order, по сущностям.
+var orderFields = jsonFields(reflect.TypeOf(models.Order{}))
+
+func jsonFields(t reflect.Type) map[string]map[string]bool {
+	out := map[string]map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == reflect.TypeOf(time.Time{}) {
+			out[name] = nil
+			continue
+		}
+		out[name] = map[string]bool{}
+		for j := 0; j < ft.NumField(); j++ {
+			sub, _, _ := strings.Cut(ft.Field(j).Tag.Get("json"), ",")
+			if sub != "" && sub != "-" {
+				out[name][sub] = true
+			}
+		}
+	}
+	return out
+}
+
+// ParseSelection проверяет пути и имена сущностей. Пустая выборка — заказ целиком.
+func ParseSelection(fields, include []string) (Selection, error) {
+	if len(fields) == 0 && len(include) == 0 {
+		return Selection{}, nil
+	}
+	tree := fieldTree{}
+	for _, name := range include {
+		if orderFields[name] == nil {
+			return Selection{}, fmt.Errorf("unknown include %q, expected one of %s", name, strings.Join(IncludeParts, ", "))
+		}
+		tree[name] = nil
+	}
+	if len(fields) == 0 {
+		for name, sub := range orderFields {
+			if sub == nil {
+				tree[name] = nil
+			}
+		}
+	}
+	for _, path := range fields {
+		root, leaf, nested := strings.Cut(path, ".")
+		sub, ok := orderFields[root]
+		if !ok || nested && !sub[leaf] {
+			return Selection{}, fmt.Errorf("unknown field %q", path)
+		}
+		node, seen := tree[root]
+		switch {
+		case !nested:
+			tree[root] = nil
+		case seen && node == nil:
+			// сущность уже выбрана целиком
+		default:
+			if node == nil {
+				node = fieldTree{}
+				tree[root] = node
+			}
+			node[leaf] = nil
+		}
+	}
+	return Selection{tree: tree}, nil
+}
+
+// Empty сообщает, что выборки нет и нужен заказ целиком.
+func (s Selection) Empty() bool { return s.tree == nil }
+
+// Parts — какие связанные сущности нужны для ответа.
+func (s Selection) Parts() ports.OrderParts {
+	if s.Empty() {
+		return ports.AllOrderParts
+	}
+	_, delivery := s.tree["delivery"]
+	_, payment := s.tree["payment"]
+	_, items := s.tree["items"]
+	return ports.OrderParts{Delivery: delivery, Payment: payment, Items: items}
+}
+
+// Project оставляет в JSON заказа только выбранные поля. Ключи идут в том же
+// порядке, что и в полном ответе; невыбранные значения не разбираются.
+func (s Selection) Project(full []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := project(&buf, full, s.tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// project дописывает в buf значение raw, урезанное до tree: объекты — по ключам
+// дерева, массивы — поэлементно, остальное копируется как есть.
+func project(buf *bytes.Buffer, raw []byte, tree fieldTree) error {
+	raw = bytes.TrimSpace(raw)
+	if tree == nil || len(raw) == 0 || raw[0] != '{' && raw[0] != '[' {
+		buf.Write(raw)
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if raw[0] == '[' {
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			var el json.RawMessage
+			if err := dec.Decode(&el); err != nil {
+				return err
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := project(buf, el, tree); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	buf.WriteByte('{')
+	first := true
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+		sub, ok := tree[key]
+		if !ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		if err := project(buf, val, sub); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// GetOrderSelected отдаёт заказ, урезанный до выборки. Попадание в кэш
+// проецируется из готового JSON; при промахе из БД читаются только нужные
+// части, и такой неполный заказ в кэш не кладётся.
+func (s *OrderService) GetOrderSelected(ctx context.Context, orderUID string, sel Selection) (*EncodedOrder, error) {
+	if sel.Empty() {
+		return s.GetOrderEncoded(ctx, orderUID)
+	}
+
+	var (
+		order *models.Order
+		full  []byte
+	)
+	if src, hasEncoded := s.cache.(encodedSource); hasEncoded {
+		if e, ok := src.GetEncoded(orderUID); ok {
+			order, full = e.Value, e.JSON
+		}
+	}
+	// GetEncoded промахивается и тогда, когда кэш просто не хранит готовый JSON
+	// (Tiered поверх обычного L1), поэтому заказ ищется и обычным Get
+	if order == nil {
+		if o, ok := s.cache.Get(orderUID); ok {
+			order = o
+		}
+	}
+	if order == nil {
+		partial, canSkip := s.repo.(ports.PartialOrderRepository)
+		parts := sel.Parts()
+		if !canSkip || parts == ports.AllOrderParts {
+			e, err := s.GetOrderEncoded(ctx, orderUID)
+			if err != nil {
+				return nil, err
+			}
+			order, full = e.Value, e.JSON
+		} else {
+			o, err := partial.GetOrderParts(ctx, orderUID, parts)
+			if err != nil {
+				return nil, fmt.Errorf("get order from db: %w", err)
+			}
+			order = o
+		}
+	}
+
+	if full == nil {
+		b, err := json.Marshal(order)
+		if err != nil {
+			return nil, fmt.Errorf("encode order: %w", err)
+		}
+		full = b
+	}
+	body, err := sel.Project(full)
+	if err != nil {
+		return nil, fmt.Errorf("project order: %w", err)
+	}
+	e, err := cache.Encode(json.RawMessage(body), false)
+	if err != nil {
+		return nil, fmt.Errorf("encode order: %w", err)
+	}
+	return &EncodedOrder{Value: order, JSON: e.JSON, ETag: e.ETag}, nil
+}
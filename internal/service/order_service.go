@@ -3,10 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"wb-test-task/internal/cache"
 	"wb-test-task/internal/models"
 	"wb-test-task/internal/ports"
 )
 
+// EncodedOrder — заказ вместе с готовым JSON-ответом.
+type EncodedOrder = cache.Encoded[*models.Order]
+
+// encodedSource реализуют кэши, хранящие предсериализованные ответы (cache.EncodedCache).
+type encodedSource interface {
+	GetEncoded(key string) (*EncodedOrder, bool)
+}
+
 type OrderService struct {
 	repo  ports.OrderRepository
 	cache ports.Cache[string, *models.Order]
@@ -25,6 +34,33 @@ func (s *OrderService) GetOrderByUID(ctx context.Context, orderUID string) (*mod
 	if err != nil {
 		return nil, fmt.Errorf("get order from db: %w", err)
 	}
-	s.cache.Set(orderUID, order)
+	cache.Fill(s.cache, orderUID, order)
 	return order, nil
 }
+
+// GetOrderEncoded возвращает заказ в сериализованном виде. Если кэш хранит
+// готовые ответы, попадание обходится без маршалинга; иначе заказ кодируется на лету.
+func (s *OrderService) GetOrderEncoded(ctx context.Context, orderUID string) (*EncodedOrder, error) {
+	src, hasEncoded := s.cache.(encodedSource)
+	if hasEncoded {
+		if e, ok := src.GetEncoded(orderUID); ok {
+			return e, nil
+		}
+	}
+
+	order, err := s.GetOrderByUID(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasEncoded {
+		if e, ok := src.GetEncoded(orderUID); ok {
+			return e, nil
+		}
+	}
+	e, err := cache.Encode(order, false)
+	if err != nil {
+		return nil, fmt.Errorf("encode order: %w", err)
+	}
+	return e, nil
+}
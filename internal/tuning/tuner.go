@@ -0,0 +1,174 @@
+package tuning
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/logging"
+)
+
+// Pausable — консьюмер, которого можно приостановить.
+type Pausable interface {
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// Result — что из изменений применено сразу, а что вступит в силу только после перезапуска.
+type Result struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Tuner применяет изменения конфига к работающему сервису. Менять на лету
+// можно только ключи с тегом runtime; остальные попадают в RestartRequired.
+type Tuner struct {
+	mu       sync.Mutex
+	boot     *config.Config // конфиг, с которым процесс стартовал
+	current  *config.Config // boot + применённые runtime-изменения
+	pending  []string       // ключи из последней перезагрузки, ждущие перезапуска
+	cache    cache.Tunable
+	consumer Pausable
+}
+
+// NewTuner принимает стартовый конфиг; cache и consumer могут быть nil.
+func NewTuner(cfg *config.Config, c cache.Tunable, consumer Pausable) *Tuner {
+	cur := *cfg
+	return &Tuner{boot: cfg, current: &cur, cache: c, consumer: consumer}
+}
+
+// Current возвращает действующий конфиг.
+func (t *Tuner) Current() *config.Config {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cur := *t.current
+	return &cur
+}
+
+// Pending — ключи, изменённые после старта, которые вступят в силу только после перезапуска.
+func (t *Tuner) Pending() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.pending...)
+}
+
+func (t *Tuner) restartKeys(next *config.Config) []string {
+	var keys []string
+	for _, key := range config.Diff(t.boot, next) {
+		if !config.Runtime(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Update применяет изменения в виде ключ → значение (например, от админского API).
+func (t *Tuner) Update(changes map[string]string) (Result, error) {
+	next, err := t.Current().With(changes)
+	if err != nil {
+		return Result{}, err
+	}
+	return t.Reload(next), nil
+}
+
+// Reload сравнивает next с действующим конфигом и применяет то, что можно.
+func (t *Tuner) Reload(next *config.Config) Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var res Result
+	restart := map[string]bool{}
+	for _, key := range t.restartKeys(next) {
+		restart[key] = true
+	}
+	for _, key := range config.Diff(t.current, next) {
+		if !config.Runtime(key) {
+			continue
+		}
+		if !t.apply(key, next) {
+			restart[key] = true
+			continue
+		}
+		res.Applied = append(res.Applied, key)
+	}
+	for key := range restart {
+		res.RestartRequired = append(res.RestartRequired, key)
+	}
+	sort.Strings(res.Applied)
+	sort.Strings(res.RestartRequired)
+	t.pending = res.RestartRequired
+	return res
+}
+
+func (t *Tuner) apply(key string, next *config.Config) bool {
+	switch key {
+	case "cache.capacity":
+		if t.cache == nil {
+			return false
+		}
+		t.cache.Resize(next.CacheCapacity)
+		t.current.CacheCapacity = next.CacheCapacity
+	case "cache.ttl":
+		if t.cache == nil {
+			return false
+		}
+		t.cache.SetTTL(next.CacheTTL)
+		t.current.CacheTTL = next.CacheTTL
+	case "logging.level":
+		if err := logging.SetLevel(next.LogLevel); err != nil {
+			return false
+		}
+		t.current.LogLevel = next.LogLevel
+	case "kafka.paused":
+		if t.consumer == nil {
+			return false
+		}
+		if next.KafkaPaused {
+			t.consumer.Pause()
+		} else {
+			t.consumer.Resume()
+		}
+		t.current.KafkaPaused = next.KafkaPaused
+	default:
+		return false
+	}
+	log.Printf("[tuning] %s = %s", key, next.Value(key))
+	return true
+}
+
+// SetPaused ставит консьюмер на паузу или снимает её, даже если kafka.paused
+// в конфиге уже такой: консьюмер мог встать на паузу сам (необработанное событие),
+// и Update этого бы не заметил. false — консьюмера нет.
+func (t *Tuner) SetPaused(paused bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.consumer == nil {
+		return false
+	}
+	if paused {
+		t.consumer.Pause()
+	} else {
+		t.consumer.Resume()
+	}
+	t.current.KafkaPaused = paused
+	log.Printf("[tuning] kafka.paused = %t", paused)
+	return true
+}
+
+// Settings — текущие значения runtime-ключей для админского API.
+func (t *Tuner) Settings() map[string]any {
+	cur := t.Current()
+	paused := cur.KafkaPaused
+	if t.consumer != nil {
+		paused = t.consumer.Paused()
+	}
+	return map[string]any{
+		"cache.capacity": cur.CacheCapacity,
+		"cache.ttl":      cur.CacheTTL.String(),
+		"logging.level":  logging.Level(),
+		"kafka.paused":   paused,
+	}
+}
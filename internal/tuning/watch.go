@@ -0,0 +1,71 @@
+package tuning
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"wb-test-task/config"
+)
+
+// Watch раз в interval проверяет время изменения files и при изменении
+// перечитывает конфиг через load. Ошибочный конфиг не применяется целиком.
+func Watch(ctx context.Context, interval time.Duration, t *Tuner, load func() (*config.Config, error), files ...string) {
+	if interval <= 0 {
+		return
+	}
+	seen := modTimes(files)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := modTimes(files)
+		if equalTimes(seen, now) {
+			continue
+		}
+		seen = now
+
+		next, err := load()
+		if err != nil {
+			log.Printf("[tuning] config reload rejected: %v", err)
+			continue
+		}
+		res := t.Reload(next)
+		if len(res.Applied) > 0 {
+			log.Printf("[tuning] config reloaded, applied: %s", strings.Join(res.Applied, ", "))
+		}
+		if len(res.RestartRequired) > 0 {
+			log.Printf("[tuning] restart required for: %s", strings.Join(res.RestartRequired, ", "))
+		}
+	}
+}
+
+// modTimes возвращает время изменения каждого файла; отсутствующий файл — нулевое время.
+func modTimes(files []string) []time.Time {
+	out := make([]time.Time, len(files))
+	for i, f := range files {
+		if f == "" {
+			continue
+		}
+		if st, err := os.Stat(f); err == nil {
+			out[i] = st.ModTime()
+		}
+	}
+	return out
+}
+
+func equalTimes(a, b []time.Time) bool {
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"sync"
+	"time"
+)
+
+// Состояния предохранителя подписки.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// Breaker — предохранитель на каждую подписку. После threshold неудач подряд
+// доставки подписки откладываются на cooldown без запросов; затем уходит одна
+// пробная, и по её итогу предохранитель закрывается или снова размыкается.
+// Состояние в памяти: у каждой реплики своё, после перезапуска всё закрыто.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	circuits map[int64]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewBreaker: threshold <= 0 выключает предохранитель.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, circuits: map[int64]*circuit{}}
+}
+
+// Allow сообщает, можно ли сейчас слать подписке id; если нельзя — до какого времени отложить.
+func (b *Breaker) Allow(id int64, now time.Time) (bool, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[id]
+	if c == nil || c.openUntil.IsZero() {
+		return true, time.Time{}
+	}
+	if now.Before(c.openUntil) {
+		return false, c.openUntil
+	}
+	if c.probing {
+		// пробная доставка ещё в пути
+		return false, now.Add(b.cooldown)
+	}
+	c.probing = true
+	return true, time.Time{}
+}
+
+func (b *Breaker) Success(id int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, id)
+}
+
+func (b *Breaker) Failure(id int64, now time.Time) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[id]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[id] = c
+	}
+	c.failures++
+	if c.probing || c.failures >= b.threshold {
+		c.openUntil = now.Add(b.cooldown)
+		c.probing = false
+	}
+}
+
+// State возвращает CircuitClosed, CircuitOpen или CircuitHalfOpen.
+func (b *Breaker) State(id int64, now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[id]
+	switch {
+	case c == nil || c.openUntil.IsZero():
+		return CircuitClosed
+	case now.Before(c.openUntil):
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+// EventTypes — события, на которые можно подписаться.
+var EventTypes = []string{db.EventOrderStored, db.EventOrderUpdated, db.EventOrderDeleted}
+
+// ValidateSubscription проверяет адрес и типы событий подписки.
+func ValidateSubscription(s *models.WebhookSubscription) error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q is not an http(s) URL", s.URL)
+	}
+	if len(s.EventTypes) == 0 {
+		return errors.New("event_types must not be empty")
+	}
+	for _, t := range s.EventTypes {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("unknown event type %q (%v)", t, EventTypes)
+		}
+	}
+	return nil
+}
+
+// Config — параметры отправки; нулевые значения заменяются значениями по умолчанию.
+type Config struct {
+	BatchSize        int           // по умолчанию 50
+	PollInterval     time.Duration // по умолчанию 1s
+	Timeout          time.Duration // на один запрос, по умолчанию 10s
+	MinBackoff       time.Duration // задержка первого повтора, дальше удваивается; по умолчанию 10s
+	MaxBackoff       time.Duration // по умолчанию 1h
+	MaxAttempts      int           // после стольких неудач доставка считается failed; по умолчанию 10
+	BreakerThreshold int           // неудач подряд до размыкания; 0 — без предохранителя
+	BreakerCooldown  time.Duration // по умолчанию 1m
+	Client           *http.Client  // nil — свой клиент с Timeout и без редиректов
+}
+
+// Body — тело запроса вебхука.
+type Body struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sender доставляет вебхуки из очереди в БД. Ответ 2xx — доставлено, всё
+// остальное (включая редиректы и таймауты) — повтор с экспоненциальной
+// задержкой. Доставки одной подписки внутри пачки уходят по порядку.
+type Sender struct {
+	store   ports.Webhooks
+	client  *http.Client
+	breaker *Breaker
+	cfg     Config
+}
+
+func NewSender(store ports.Webhooks, cfg Config) *Sender {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 50
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(time.Hour, cfg.MinBackoff)
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = time.Minute
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: cfg.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return &Sender{
+		store:   store,
+		client:  client,
+		breaker: NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		cfg:     cfg,
+	}
+}
+
+func (s *Sender) Run(ctx context.Context) {
+	log.Printf("[webhooks] sender started")
+	t := time.NewTicker(s.cfg.PollInterval)
+	defer t.Stop()
+	for {
+		for {
+			n, err := s.Flush(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[webhooks] %v", err)
+			}
+			if err != nil || n < s.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("[webhooks] sender stopped")
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Flush отправляет одну пачку и возвращает число доставленных вебхуков.
+func (s *Sender) Flush(ctx context.Context) (int, error) {
+	return s.store.ProcessDeliveries(ctx, s.cfg.BatchSize, s.send)
+}
+
+// CircuitState — состояние предохранителя подписки для админского API.
+func (s *Sender) CircuitState(id int64) string {
+	return s.breaker.State(id, time.Now())
+}
+
+// send шлёт подписки параллельно, а доставки одной подписки — по очереди,
+// чтобы предохранитель срабатывал внутри пачки.
+func (s *Sender) send(ctx context.Context, ds []models.WebhookDelivery) []ports.WebhookAttempt {
+	results := make([]ports.WebhookAttempt, len(ds))
+	groups := map[int64][]int{}
+	for i, d := range ds {
+		groups[d.SubscriptionID] = append(groups[d.SubscriptionID], i)
+	}
+	var wg sync.WaitGroup
+	for _, idx := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range idx {
+				results[i] = s.deliver(ctx, ds[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *Sender) deliver(ctx context.Context, d models.WebhookDelivery) ports.WebhookAttempt {
+	now := time.Now()
+	if ok, until := s.breaker.Allow(d.SubscriptionID, now); !ok {
+		return ports.WebhookAttempt{Status: models.WebhookPending, NextAttemptAt: until}
+	}
+
+	code, err := s.post(ctx, d)
+	if err != nil && ctx.Err() != nil {
+		// остановка сервиса — не вина получателя
+		return ports.WebhookAttempt{Status: models.WebhookPending, NextAttemptAt: now}
+	}
+	res := ports.WebhookAttempt{StatusCode: code, Counted: true}
+	attempts := d.Attempts + 1
+	switch {
+	case err == nil:
+		s.breaker.Success(d.SubscriptionID)
+		res.Status = models.WebhookDelivered
+		res.NextAttemptAt = now
+		return res
+	case attempts >= s.cfg.MaxAttempts:
+		res.Status = models.WebhookFailed
+		res.NextAttemptAt = now
+	default:
+		res.Status = models.WebhookPending
+		res.NextAttemptAt = s.retryAt(attempts)
+	}
+	s.breaker.Failure(d.SubscriptionID, now)
+	res.Error = err.Error()
+	log.Printf("[webhooks] deliver %s #%d to subscription %d (attempt %d): %v",
+		d.EventType, d.ID, d.SubscriptionID, attempts, err)
+	return res
+}
+
+// post отправляет подписанный запрос и возвращает код ответа.
+func (s *Sender) post(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(Body{ID: d.EventID, Type: d.EventType, CreatedAt: d.CreatedAt, Data: d.Payload})
+	if err != nil {
+		return 0, fmt.Errorf("marshal webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, d.EventID)
+	req.Header.Set(EventTypeHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(d.Secret, now, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryAt — экспоненциальная задержка: MinBackoff, 2×, 4×, … но не больше MaxBackoff.
+func (s *Sender) retryAt(attempts int) time.Time {
+	d := s.cfg.MinBackoff
+	for i := 1; i < attempts && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return time.Now().Add(min(d, s.cfg.MaxBackoff))
+}
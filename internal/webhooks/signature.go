@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки запроса вебхука.
+const (
+	EventIDHeader    = "X-Webhook-Id"       // event_id, одинаков при повторах — по нему получатель отсеивает дубли
+	EventTypeHeader  = "X-Webhook-Event"    // order.stored, order.updated, order.deleted
+	DeliveryHeader   = "X-Webhook-Delivery" // ID доставки для поиска в журнале
+	TimestampHeader  = "X-Webhook-Timestamp"
+	SignatureHeader  = "X-Webhook-Signature"
+	signaturePrefix  = "sha256="
+	secretPrefix     = "whsec_"
+	secretRandomSize = 32
+)
+
+var (
+	ErrBadSignature = errors.New("webhook signature mismatch")
+	ErrStale        = errors.New("webhook timestamp outside tolerance")
+)
+
+// Sign возвращает значение X-Webhook-Signature: HMAC-SHA256 секрета подписки
+// от "<unix-время>.<тело>". Время входит в подпись, чтобы перехваченный
+// запрос нельзя было повторить позже.
+func Sign(secret string, ts time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify — проверка на стороне получателя: подпись совпадает, а время
+// отличается от now не больше чем на tolerance (0 — не проверять).
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad %s %q", TimestampHeader, timestamp)
+	}
+	ts := time.Unix(sec, 0)
+	if tolerance > 0 && (now.Sub(ts) > tolerance || ts.Sub(now) > tolerance) {
+		return ErrStale
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// NewSecret генерирует секрет подписки.
+func NewSecret() (string, error) {
+	b := make([]byte, secretRandomSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
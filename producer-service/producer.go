@@ -2,24 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	kafka "github.com/segmentio/kafka-go"
 )
 
 const (
-	topic              = "orders"
-	kafkaBrokerAddress = "localhost:29093"
+	defaultTopic   = "orders"
+	defaultBrokers = "localhost:29093"
 )
 
 func main() {
+	dialer, err := newDialer()
+	if err != nil {
+		log.Fatalf("kafka: %v", err)
+	}
 	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{kafkaBrokerAddress},
-		Topic:   topic,
+		Brokers: strings.Split(getenv("KAFKA_BROKERS", defaultBrokers), ","),
+		Topic:   getenv("KAFKA_TOPIC", defaultTopic),
+		Dialer:  dialer,
 	})
 	defer writer.Close()
 
@@ -29,12 +37,12 @@ func main() {
 		log.Fatal(err)
 	}
 
-	sendMsgsToKafka(writer, ordersDir)
+	sendMsgsToKafka(writer, ordersDir, getenv("KAFKA_ENVELOPE", "false") == "true")
 
 	fmt.Println("Продюсер отправил файлы!")
 }
 
-func sendMsgsToKafka(writer *kafka.Writer, dir []os.DirEntry) {
+func sendMsgsToKafka(writer *kafka.Writer, dir []os.DirEntry, envelope bool) {
 
 	for i, orderJSON := range dir {
 		if orderJSON.IsDir() {
@@ -52,6 +60,12 @@ func sendMsgsToKafka(writer *kafka.Writer, dir []os.DirEntry) {
 			log.Fatalf("Файл №%v не содержит валидный JSON", i)
 		}
 
+		if envelope {
+			if jsonData, err = wrap(jsonData); err != nil {
+				log.Fatalf("Файл №%v: %v", i, err)
+			}
+		}
+
 		msg := kafka.Message{
 			Value: jsonData,
 			Time:  time.Now(),
@@ -66,3 +80,17 @@ func sendMsgsToKafka(writer *kafka.Writer, dir []os.DirEntry) {
 		time.Sleep(200 * time.Millisecond)
 	}
 }
+
+// wrap кладёт заказ в конверт с версией схемы (KAFKA_ENVELOPE=true).
+func wrap(payload []byte) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{
+		"schema_version": 1,
+		"event_id":       hex.EncodeToString(id),
+		"produced_at":    time.Now().UTC(),
+		"payload":        json.RawMessage(payload),
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Настройки подключения читаются из тех же переменных, что и у сервиса
+// (KAFKA_TLS*, KAFKA_SASL_*), чтобы оба работали с одним защищённым кластером.
+// Продюсер — отдельный модуль, поэтому код не общий с internal/kafka.
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// secretEnv читает KEY или файл из KEY_FILE.
+func secretEnv(key string) (string, error) {
+	if v := os.Getenv(key); v != "" {
+		return v, nil
+	}
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", nil
+}
+
+func newDialer() (*kafka.Dialer, error) {
+	dialer := &kafka.Dialer{Timeout: 10 * time.Second, DualStack: true}
+
+	ca, cert, key := os.Getenv("KAFKA_TLS_CA"), os.Getenv("KAFKA_TLS_CERT"), os.Getenv("KAFKA_TLS_KEY")
+	enabled, _ := strconv.ParseBool(os.Getenv("KAFKA_TLS"))
+	insecure, _ := strconv.ParseBool(os.Getenv("KAFKA_TLS_INSECURE_SKIP_VERIFY"))
+	if enabled || insecure || ca != "" || cert != "" {
+		cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+		if ca != "" {
+			pem, err := os.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("read CA: %w", err)
+			}
+			cfg.RootCAs = x509.NewCertPool()
+			if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", ca)
+			}
+		}
+		if cert != "" || key != "" {
+			pair, err := tls.LoadX509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("load client certificate: %w", err)
+			}
+			cfg.Certificates = []tls.Certificate{pair}
+		}
+		dialer.TLS = cfg
+	}
+
+	if mechanism := os.Getenv("KAFKA_SASL_MECHANISM"); mechanism != "" {
+		password, err := secretEnv("KAFKA_SASL_PASSWORD")
+		if err != nil {
+			return nil, err
+		}
+		user := os.Getenv("KAFKA_SASL_USERNAME")
+		var mech sasl.Mechanism
+		switch strings.ToLower(mechanism) {
+		case "plain":
+			mech = plain.Mechanism{Username: user, Password: password}
+		case "scram-sha-256":
+			mech, err = scram.Mechanism(scram.SHA256, user, password)
+		case "scram-sha-512":
+			mech, err = scram.Mechanism(scram.SHA512, user, password)
+		default:
+			err = fmt.Errorf("unknown SASL mechanism %q", mechanism)
+		}
+		if err != nil {
+			return nil, err
+		}
+		dialer.SASLMechanism = mech
+	}
+	return dialer, nil
+}
@@ -0,0 +1,191 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/routes"
+	"wb-test-task/internal/service"
+)
+
+// batchRepo отвечает на GetOrders из stored и запоминает каждый вызов.
+type batchRepo struct {
+	mockRepo
+	stored map[string]*models.Order
+	calls  [][]string
+	err    error
+}
+
+func (b *batchRepo) GetOrders(ctx context.Context, uids []string) (map[string]*models.Order, error) {
+	b.calls = append(b.calls, uids)
+	if b.err != nil {
+		return nil, b.err
+	}
+	out := map[string]*models.Order{}
+	for _, uid := range uids {
+		if o, ok := b.stored[uid]; ok {
+			out[uid] = o
+		}
+	}
+	return out, nil
+}
+
+type batchResponse struct {
+	Results []struct {
+		OrderUID string        `json:"order_uid"`
+		Status   string        `json:"status"`
+		Order    *models.Order `json:"order"`
+		Error    string        `json:"error"`
+	} `json:"results"`
+}
+
+func batchRouter(svc *service.OrderService, max int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return routes.InitBatchRoutes(gin.New(), svc, max)
+}
+
+func postBatch(r http.Handler, body, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders:batchGet", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBatchGet_CacheFirstThenOneQuery(t *testing.T) {
+	c := newEncodedCache(t, true)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	repo := &batchRepo{stored: map[string]*models.Order{
+		"uid-2": sampleOrder("uid-2", 2),
+		"uid-3": sampleOrder("uid-3", 1),
+	}}
+	r := batchRouter(service.NewOrderService(repo, c), 10)
+
+	w := postBatch(r, `{"uids":["uid-3","uid-1","nope","uid-2","uid-3"]}`, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	var got []string
+	for _, res := range resp.Results {
+		got = append(got, res.OrderUID+":"+res.Status)
+	}
+	assert.Equal(t, []string{"uid-3:found", "uid-1:found", "nope:not_found", "uid-2:found", "uid-3:found"}, got, "порядок запроса, повторы тоже")
+	assert.Len(t, resp.Results[3].Order.Items, 2)
+	assert.Nil(t, resp.Results[2].Order)
+
+	spec := loadSpec(t)
+	var raw any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+	assert.Empty(t, checkSchema(spec, lookupRef(spec, "#/components/schemas/BatchGetResponse"), raw, "$"))
+
+	assert.Equal(t, [][]string{{"uid-3", "nope", "uid-2"}}, repo.calls, "промахи одним запросом, без повторов")
+	assert.Zero(t, repo.callsGet)
+
+	_, ok := c.GetEncoded("uid-2")
+	assert.True(t, ok, "прочитанное из БД попадает в кэш")
+	postBatch(r, `{"uids":["uid-2","uid-3"]}`, "")
+	assert.Len(t, repo.calls, 1)
+}
+
+func TestBatchGet_RepoErrorPerUID(t *testing.T) {
+	c := newEncodedCache(t, false)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	repo := &batchRepo{err: errors.New("connection refused")}
+	r := batchRouter(service.NewOrderService(repo, c), 10)
+
+	w := postBatch(r, `{"uids":["uid-1","uid-2"]}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "found", resp.Results[0].Status, "кэш отвечает и без БД")
+	assert.Equal(t, "error", resp.Results[1].Status)
+	assert.Contains(t, resp.Results[1].Error, "connection refused")
+}
+
+func TestBatchGet_FallsBackToSingleReads(t *testing.T) {
+	repo := &mockRepo{getOrderRes: sampleOrder("uid-1", 1)}
+	r := batchRouter(service.NewOrderService(repo, newMockCache()), 10)
+
+	w := postBatch(r, `{"uids":["uid-1","uid-1"]}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, repo.callsGet, "репозиторий без GetOrders читается по одному UID")
+	assert.Equal(t, 2, strings.Count(w.Body.String(), `"status":"found"`))
+}
+
+func TestBatchGet_CacheWithoutEncodedL1(t *testing.T) {
+	c := plainTiered(t)
+	c.Set("uid-1", sampleOrder("uid-1", 2))
+	repo := &batchRepo{stored: map[string]*models.Order{"uid-2": sampleOrder("uid-2", 1)}}
+	r := batchRouter(service.NewOrderService(repo, c), 10)
+
+	w := postBatch(r, `{"uids":["uid-1","uid-2"]}`, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "found", resp.Results[0].Status)
+	assert.Len(t, resp.Results[0].Order.Items, 2)
+	assert.Equal(t, "found", resp.Results[1].Status)
+	assert.Equal(t, [][]string{{"uid-2"}}, repo.calls, "попадание в кэш не идёт в БД")
+
+	postBatch(r, `{"uids":["uid-1","uid-2"]}`, "")
+	assert.Len(t, repo.calls, 1, "прочитанное из БД тоже обслуживается кэшем")
+}
+
+func TestBatchGet_NDJSON(t *testing.T) {
+	c := newEncodedCache(t, false)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	repo := &batchRepo{stored: map[string]*models.Order{"uid-2": sampleOrder("uid-2", 1)}}
+	r := batchRouter(service.NewOrderService(repo, c), 10)
+
+	w := postBatch(r, `{"uids":["uid-2","uid-1","nope"]}`, "application/x-ndjson")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var lines []string
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var item struct {
+			OrderUID string `json:"order_uid"`
+			Status   string `json:"status"`
+		}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &item), sc.Text())
+		lines = append(lines, item.OrderUID+":"+item.Status)
+	}
+	assert.Equal(t, []string{"uid-1:found", "uid-2:found", "nope:not_found"}, lines, "сначала попадания в кэш")
+}
+
+func TestBatchGet_Validation(t *testing.T) {
+	r := batchRouter(service.NewOrderService(&batchRepo{}, newMockCache()), 2)
+
+	for body, want := range map[string]string{
+		`{"uids":[]}`:            "must not be empty",
+		`{"uids":["a","b","c"]}`: "too many uids",
+		`{"uids":["a"," "]}`:     "uids[1] is empty",
+		`{"uids":"a"}`:           "invalid JSON",
+	} {
+		w := postBatch(r, body, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), want, body)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders:batchDelete", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/cache"
+)
+
+func TestCachePolicies_SetGetDelete(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 4, 100, time.Minute)
+			require.NoError(t, err)
+
+			c.Set("a", 1)
+			c.Set("a", 2)
+			got, ok := c.Get("a")
+			require.True(t, ok)
+			assert.Equal(t, 2, got)
+
+			c.Delete("a")
+			_, ok = c.Get("a")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestCachePolicies_TTL(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 2, 10, 10*time.Millisecond)
+			require.NoError(t, err)
+			c.Set("k", 1)
+			time.Sleep(20 * time.Millisecond)
+			_, ok := c.Get("k")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestCachePolicies_Capacity(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 1, 10, time.Minute)
+			require.NoError(t, err)
+			for i := 0; i < 100; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+			cnt := 0
+			for i := 0; i < 100; i++ {
+				if _, ok := c.Get(fmt.Sprint(i)); ok {
+					cnt++
+				}
+			}
+			assert.LessOrEqual(t, cnt, 10)
+		})
+	}
+}
+
+// Нулевая ёмкость — без ограничений у всех политик.
+func TestCachePolicies_ZeroCapacityIsUnbounded(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 4, 0, time.Minute)
+			require.NoError(t, err)
+			for i := 0; i < 500; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+			assert.Equal(t, 500, countPresent(c, 500))
+
+			c.(cache.Tunable).Resize(40)
+			assert.LessOrEqual(t, countPresent(c, 500), 40, "после Resize ёмкость снова ограничена")
+		})
+	}
+}
+
+// Удаление и Resize не должны ломать учёт ёмкости.
+func TestCachePolicies_CapacityAfterDelete(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 1, 10, time.Minute)
+			require.NoError(t, err)
+			size := func() int { return len(c.(cache.Snapshotter[int]).Snapshot()) }
+
+			// "0" — единственный редкий ключ, остальные прочитаны по разу
+			for i := 0; i < 10; i++ {
+				c.Set(fmt.Sprint(i), i)
+				if i > 0 {
+					c.Get(fmt.Sprint(i))
+				}
+			}
+			// удаление опустошает самую редкую группу; вытеснение сразу после
+			// него должно найти следующую, а не упереться в пустую
+			c.Delete("0")
+			done := make(chan struct{})
+			go func() {
+				c.(cache.Tunable).Resize(5)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("resize after delete does not evict")
+			}
+			assert.Equal(t, 5, size())
+
+			for i := 10; i < 40; i++ {
+				c.Set(fmt.Sprint(i), i)
+				c.Delete(fmt.Sprint(i - 5))
+				c.Set(fmt.Sprint(i+100), i)
+				assert.LessOrEqual(t, size(), 5, "after set %d", i)
+			}
+		})
+	}
+}
+
+// Горячий ключ не должен вымываться потоком разовых ключей.
+// Промах обрабатываем как сервис: кладём значение обратно в кэш.
+func TestCachePolicies_FrequencyAware(t *testing.T) {
+	for _, p := range []cache.Policy{cache.PolicyLFU, cache.Policy2Q, cache.PolicyTinyLFU} {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 1, 20, time.Minute)
+			require.NoError(t, err)
+			lookup := func(k string) {
+				if _, ok := c.Get(k); !ok {
+					c.Set(k, 1)
+				}
+			}
+			for i := 0; i < 50; i++ {
+				lookup("hot")
+			}
+			misses := 0
+			for i := 0; i < 200; i++ {
+				lookup(fmt.Sprintf("cold-%d", i))
+				if _, ok := c.Get("hot"); !ok {
+					misses++
+					c.Set("hot", 1)
+				}
+			}
+			assert.LessOrEqual(t, misses, 1)
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := cache.ParsePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, cache.PolicyLRU, p)
+
+	p, err = cache.ParsePolicy(" TinyLFU ")
+	require.NoError(t, err)
+	assert.Equal(t, cache.PolicyTinyLFU, p)
+
+	_, err = cache.ParsePolicy("mru")
+	assert.Error(t, err)
+}
+
+// BenchmarkCacheReplay прогоняет трассу ключей через все стратегии и
+// выводит долю попаданий (метрика hit%). Записанную трассу (по ключу на строку)
+// можно передать через CACHE_TRACE, иначе генерируется zipf-распределение:
+//
+//	CACHE_TRACE=trace.txt go test ./test/unit -run '^$' -bench CacheReplay
+func BenchmarkCacheReplay(b *testing.B) {
+	trace := loadTrace(b)
+	for _, p := range cache.Policies {
+		b.Run(string(p), func(b *testing.B) {
+			var hits, total int
+			for n := 0; n < b.N; n++ {
+				c, err := cache.New[struct{}](p, 16, 1000, time.Hour)
+				require.NoError(b, err)
+				for _, k := range trace {
+					total++
+					if _, ok := c.Get(k); ok {
+						hits++
+						continue
+					}
+					c.Set(k, struct{}{})
+				}
+			}
+			b.ReportMetric(100*float64(hits)/float64(total), "hit%")
+		})
+	}
+}
+
+func loadTrace(b *testing.B) []string {
+	if path := os.Getenv("CACHE_TRACE"); path != "" {
+		f, err := os.Open(path)
+		require.NoError(b, err)
+		defer f.Close()
+
+		var keys []string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if k := strings.TrimSpace(sc.Text()); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		require.NoError(b, sc.Err())
+		return keys
+	}
+
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.1, 1, 100_000)
+	keys := make([]string, 100_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("order-%d", z.Uint64())
+	}
+	return keys
+}
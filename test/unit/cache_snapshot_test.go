@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+)
+
+func TestCacheSnapshot_RoundTrip(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			src, err := cache.New[*models.Order](p, 4, 100, time.Minute)
+			require.NoError(t, err)
+			src.Set("uid-1", sampleOrder("uid-1", 2))
+			src.Set("uid-2", sampleOrder("uid-2", 1))
+
+			path := filepath.Join(t.TempDir(), "cache.snapshot")
+			n, err := cache.SaveSnapshot(path, src.(cache.Snapshotter[*models.Order]))
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			dst, err := cache.New[*models.Order](p, 4, 100, time.Minute)
+			require.NoError(t, err)
+			n, err = cache.LoadSnapshot(path, dst.(cache.Snapshotter[*models.Order]))
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			got, ok := dst.Get("uid-1")
+			require.True(t, ok)
+			assert.Equal(t, sampleOrder("uid-1", 2), got)
+		})
+	}
+}
+
+func TestCacheSnapshot_KeepsRecencyAndExpiry(t *testing.T) {
+	src := cache.NewShardedLRU[int](1, 3, time.Minute)
+	src.Set("a", 1)
+	src.Set("b", 2)
+	src.Set("c", 3)
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	_, err := cache.SaveSnapshot[int](path, src)
+	require.NoError(t, err)
+
+	dst := cache.NewShardedLRU[int](1, 3, time.Minute)
+	_, err = cache.LoadSnapshot[int](path, dst)
+	require.NoError(t, err)
+
+	// «a» — самый старый, его и вытеснит следующий ключ
+	dst.Set("d", 4)
+	_, ok := dst.Get("a")
+	assert.False(t, ok)
+	_, ok = dst.Get("b")
+	assert.True(t, ok)
+
+	short := cache.NewShardedLRU[int](1, 3, 10*time.Millisecond)
+	short.Set("x", 1)
+	_, err = cache.SaveSnapshot[int](path, short)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	n, err := cache.LoadSnapshot[int](path, cache.NewShardedLRU[int](1, 3, time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestCacheSnapshot_Encoded(t *testing.T) {
+	src := newEncodedCache(t, true)
+	src.Set("uid-1", sampleOrder("uid-1", 1))
+
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	_, err := cache.SaveSnapshot[*models.Order](path, src)
+	require.NoError(t, err)
+
+	dst := newEncodedCache(t, true)
+	_, err = cache.LoadSnapshot[*models.Order](path, dst)
+	require.NoError(t, err)
+
+	e, ok := dst.GetEncoded("uid-1")
+	require.True(t, ok)
+	assert.NotEmpty(t, e.Gzip)
+}
+
+func TestCacheSnapshot_RejectsCorruptFile(t *testing.T) {
+	src := cache.NewShardedLRU[int](1, 3, time.Minute)
+	src.Set("a", 1)
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	_, err := cache.SaveSnapshot[int](path, src)
+	require.NoError(t, err)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	corrupt := append([]byte(nil), raw...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, corrupt, 0o644))
+	_, err = cache.LoadSnapshot[int](path, cache.NewShardedLRU[int](1, 3, time.Minute))
+	assert.ErrorIs(t, err, cache.ErrSnapshotChecksum)
+
+	future := append([]byte(nil), raw...)
+	binary.BigEndian.PutUint16(future[4:6], 99)
+	require.NoError(t, os.WriteFile(path, future, 0o644))
+	_, err = cache.LoadSnapshot[int](path, cache.NewShardedLRU[int](1, 3, time.Minute))
+	assert.ErrorIs(t, err, cache.ErrSnapshotVersion)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o644))
+	_, err = cache.LoadSnapshot[int](path, cache.NewShardedLRU[int](1, 3, time.Minute))
+	assert.ErrorIs(t, err, cache.ErrSnapshotFormat)
+}
@@ -0,0 +1,218 @@
+package unit
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/codec"
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/schemaregistry"
+)
+
+const (
+	avroSchemaID  = 1
+	protoSchemaID = 2
+)
+
+// fakeRegistry — Schema Registry в памяти: отдаёт схемы по ID и считает запросы.
+type fakeRegistry struct {
+	srv      *httptest.Server
+	requests atomic.Int32
+	failing  atomic.Bool
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	avsc, err := os.ReadFile("../../api/avro/order.avsc")
+	require.NoError(t, err)
+	proto, err := os.ReadFile("../../api/proto/order.proto")
+	require.NoError(t, err)
+	schemas := map[string]map[string]string{
+		"/schemas/ids/1": {"schema": string(avsc)}, // schemaType не указан — Avro
+		"/schemas/ids/2": {"schema": string(proto), "schemaType": "PROTOBUF"},
+	}
+
+	r := &fakeRegistry{}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		if r.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s, ok := schemas[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+// avroOrder кодирует заказ по api/avro/order.avsc.
+func avroOrder(o *models.Order) []byte {
+	var b []byte
+	long := func(v int64) { b = binary.AppendVarint(b, v) }
+	str := func(s string) { long(int64(len(s))); b = append(b, s...) }
+
+	str(o.OrderUID)
+	str(o.TrackNumber)
+	str(o.Entry)
+	d := o.Delivery
+	for _, s := range []string{d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email} {
+		str(s)
+	}
+	p := o.Payment
+	str(p.Transaction)
+	str(p.RequestID)
+	str(p.Currency)
+	str(p.Provider)
+	long(int64(p.Amount))
+	long(p.PaymentDT)
+	str(p.Bank)
+	long(int64(p.DeliveryCost))
+	long(int64(p.GoodsTotal))
+	long(int64(p.CustomFee))
+	long(int64(len(o.Items)))
+	for _, it := range o.Items {
+		long(int64(it.ChrtID))
+		str(it.TrackNumber)
+		long(int64(it.Price))
+		str(it.RID)
+		str(it.Name)
+		long(int64(it.Sale))
+		str(it.Size)
+		long(int64(it.TotalPrice))
+		long(int64(it.NMID))
+		str(it.Brand)
+		long(int64(it.Status))
+	}
+	long(0)
+	str(o.Locale)
+	long(1) // ветка "string" в ["null","string"]
+	str(o.InternalSignature)
+	str(o.CustomerID)
+	str(o.DeliveryService)
+	str(o.ShardKey)
+	long(int64(o.SMID))
+	long(o.DateCreated.UnixMilli())
+	str(o.OOFShard)
+	return b
+}
+
+func codecOrder() *models.Order {
+	o := sampleOrder("uid-codec", 2)
+	o.InternalSignature = "sig"
+	o.Payment.RequestID = "req-1"
+	return o
+}
+
+func TestCodec_ParseContentType(t *testing.T) {
+	for ct, want := range map[string]codec.Format{
+		"application/json; charset=utf-8": codec.FormatJSON,
+		"application/x-protobuf":          codec.FormatProtobuf,
+		"application/vnd.google.protobuf": codec.FormatProtobuf,
+		"avro/binary":                     codec.FormatAvro,
+	} {
+		got, err := codec.ParseContentType(ct)
+		require.NoError(t, err, ct)
+		assert.Equal(t, want, got, ct)
+	}
+	_, err := codec.ParseContentType("text/xml")
+	assert.Error(t, err)
+}
+
+func TestCodec_DecodesWireFormatFromRegistry(t *testing.T) {
+	reg := newFakeRegistry(t)
+	dec := codec.NewOrderDecoder(schemaregistry.New(reg.srv.URL, schemaregistry.Options{}))
+	want := codecOrder()
+
+	got, err := dec.Decode(context.Background(), "", codec.WireFormat(avroSchemaID, avroOrder(want)))
+	require.NoError(t, err)
+	assert.Equal(t, *want, got)
+
+	// Protobuf-сериализатор Confluent пишет индексы сообщения; 0 — первое сообщение файла
+	proto := append([]byte{0}, codec.MarshalOrderProto(want)...)
+	got, err = dec.Decode(context.Background(), "", codec.WireFormat(protoSchemaID, proto))
+	require.NoError(t, err)
+	assert.Equal(t, *want, got)
+
+	// схемы неизменны и кэшируются: повторные сообщения не ходят в реестр
+	_, err = dec.Decode(context.Background(), "", codec.WireFormat(avroSchemaID, avroOrder(want)))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), reg.requests.Load())
+}
+
+func TestCodec_DetectsFormatByHeader(t *testing.T) {
+	dec := codec.NewOrderDecoder(nil)
+	want := codecOrder()
+
+	got, err := dec.Decode(context.Background(), "application/x-protobuf", codec.MarshalOrderProto(want))
+	require.NoError(t, err)
+	assert.Equal(t, *want, got)
+
+	data, err := json.Marshal(want)
+	require.NoError(t, err)
+	got, err = dec.Decode(context.Background(), "", data)
+	require.NoError(t, err)
+	assert.Equal(t, want.OrderUID, got.OrderUID)
+
+	_, err = dec.Decode(context.Background(), "", codec.MarshalOrderProto(want))
+	assert.Error(t, err, "бинарные данные без заголовка не разбираются как JSON")
+
+	_, err = dec.Decode(context.Background(), "avro/binary", avroOrder(want))
+	assert.Error(t, err, "Avro без ID схемы не разобрать")
+
+	_, err = dec.Decode(context.Background(), "", codec.WireFormat(avroSchemaID, avroOrder(want)))
+	assert.Error(t, err, "wire format без реестра")
+}
+
+func TestCodec_RegistryErrors(t *testing.T) {
+	reg := newFakeRegistry(t)
+	client := schemaregistry.New(reg.srv.URL, schemaregistry.Options{})
+
+	_, err := client.SchemaByID(context.Background(), 42)
+	assert.True(t, errors.Is(err, schemaregistry.ErrNotFound))
+
+	reg.failing.Store(true)
+	_, err = client.SchemaByID(context.Background(), avroSchemaID)
+	assert.True(t, errors.Is(err, schemaregistry.ErrUnavailable))
+
+	reg.failing.Store(false)
+	s, err := client.SchemaByID(context.Background(), protoSchemaID)
+	require.NoError(t, err)
+	assert.Equal(t, schemaregistry.TypeProtobuf, s.Type)
+	assert.True(t, strings.Contains(s.Schema, "message Order"))
+}
+
+func TestCodec_SnapshotHandlerRetriesWhileRegistryIsDown(t *testing.T) {
+	reg := newFakeRegistry(t)
+	cfg := &config.Config{KafkaTopic: "orders", SchemaRegistryURL: reg.srv.URL}
+	r := kafka.NewOrderRegistry(cfg, &mockRepo{}, newFakeUpdater(), newMockCache())
+	_, h := r.Resolve(kafkago.Message{Topic: "orders"})
+	require.NotNil(t, h)
+
+	reg.failing.Store(true)
+	err := h.Handle(context.Background(), kafkago.Message{Topic: "orders", Value: codec.WireFormat(avroSchemaID, avroOrder(codecOrder()))})
+	require.Error(t, err)
+	assert.False(t, kafka.IsPermanent(err), "недоступный реестр — повод повторить, а не выбросить сообщение")
+
+	reg.failing.Store(false)
+	err = h.Handle(context.Background(), kafkago.Message{Topic: "orders", Value: codec.WireFormat(42, []byte{1})})
+	assert.True(t, kafka.IsPermanent(err), "неизвестная схема не появится от повторов")
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/handlers"
+	"wb-test-task/internal/service"
+)
+
+var orderModified = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func conditionalRouter(t *testing.T, withGzip bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	c := newEncodedCache(t, withGzip)
+	o := sampleOrder("uid-1", 1)
+	o.UpdatedAt = orderModified.Add(300 * time.Millisecond)
+	c.Set("uid-1", o)
+
+	r := gin.New()
+	h := handlers.NewOrderHandler(service.NewOrderService(&mockRepo{getOrderErr: context.Canceled}, c), "public, max-age=30")
+	r.GET("/order/:orderId", h.GetOrderByUID)
+	return r
+}
+
+func getOrder(r http.Handler, uid string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/order/"+uid, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConditionalGet_ValidatorsOnOK(t *testing.T) {
+	r := conditionalRouter(t, false)
+
+	w := getOrder(r, "uid-1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, "Wed, 01 May 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "public, max-age=30", w.Header().Get("Cache-Control"))
+
+	w = getOrder(r, "missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"), "404 не кэшируется")
+}
+
+func TestConditionalGet_IfNoneMatch(t *testing.T) {
+	r := conditionalRouter(t, false)
+	etag := getOrder(r, "uid-1", nil).Header().Get("ETag")
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		w := getOrder(r, "uid-1", map[string]string{"If-None-Match": inm})
+		assert.Equal(t, http.StatusNotModified, w.Code, inm)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	}
+
+	w := getOrder(r, "uid-1", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"order_uid":"uid-1"`)
+}
+
+func TestConditionalGet_GzipVariant(t *testing.T) {
+	r := conditionalRouter(t, true)
+	plain := getOrder(r, "uid-1", nil).Header().Get("ETag")
+
+	w := getOrder(r, "uid-1", map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code)
+	gz := w.Header().Get("ETag")
+	assert.NotEqual(t, plain, gz, "у сжатого представления свой ETag")
+
+	// ETag сжатой версии подходит и для несжатой, и наоборот
+	w = getOrder(r, "uid-1", map[string]string{"If-None-Match": gz})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = getOrder(r, "uid-1", map[string]string{"If-None-Match": plain, "Accept-Encoding": "gzip"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, gz, w.Header().Get("ETag"))
+}
+
+func TestConditionalGet_IfModifiedSince(t *testing.T) {
+	r := conditionalRouter(t, false)
+
+	w := getOrder(r, "uid-1", map[string]string{"If-Modified-Since": orderModified.Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNotModified, w.Code, "сравнение с точностью до секунды")
+
+	w = getOrder(r, "uid-1", map[string]string{"If-Modified-Since": orderModified.Add(time.Hour).Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = getOrder(r, "uid-1", map[string]string{"If-Modified-Since": orderModified.Add(-time.Second).Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusOK, w.Code, "заказ изменился позже")
+
+	w = getOrder(r, "uid-1", map[string]string{"If-Modified-Since": "вчера"})
+	assert.Equal(t, http.StatusOK, w.Code, "непонятная дата игнорируется")
+
+	// If-None-Match важнее If-Modified-Since
+	w = getOrder(r, "uid-1", map[string]string{
+		"If-None-Match":     `"other"`,
+		"If-Modified-Since": orderModified.Add(time.Hour).Format(http.TimeFormat),
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,219 @@
+package unit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/config"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "db")
+	t.Setenv("DB_USER", "user")
+	t.Setenv("DB_PASSWORD", "p@ss word")
+	t.Setenv("DB_NAME", "orders")
+	t.Setenv("KAFKA_BROKERS", "kafka:9092")
+	t.Setenv("KAFKA_TOPIC", "orders")
+}
+
+func TestConfig_EnvOnlyWithoutDotenv(t *testing.T) {
+	setRequiredEnv(t)
+
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "missing.env"))
+	require.NoError(t, err)
+	assert.Equal(t, "8081", cfg.HTTPPort)
+	assert.Equal(t, 5*time.Minute, cfg.CacheTTL, "TTL по умолчанию не должен быть нулевым")
+	assert.Equal(t, 10*time.Second, cfg.HTTPShutdownTimeout)
+	assert.Equal(t, "order-service", cfg.KafkaGroupID)
+	assert.Equal(t, time.Hour, cfg.KafkaDedupWindow)
+	assert.Equal(t, []string{"key", "header", "hash"}, cfg.KafkaDedupKeys, "хэш тела — запасной ключ")
+	assert.NotEmpty(t, cfg.InstanceID)
+}
+
+func TestConfig_ExampleFileIsValid(t *testing.T) {
+	cfg, err := config.Load("../../.env.example")
+	require.NoError(t, err)
+	assert.Equal(t, "8081", cfg.HTTPPort)
+	assert.Equal(t, "orders", cfg.KafkaTopic, "комментарий в строке не попадает в значение")
+	assert.Equal(t, 24*time.Hour, cfg.WarmupWindow)
+	assert.Equal(t, 500*time.Millisecond, cfg.PeerTimeout)
+}
+
+func TestConfig_EnvOverridesDotenv(t *testing.T) {
+	setRequiredEnv(t)
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("CACHE_TTL=1m\nHTTP_PORT=9000\n"), 0o644))
+	t.Setenv("HTTP_PORT", "9100")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, cfg.CacheTTL)
+	assert.Equal(t, "9100", cfg.HTTPPort)
+}
+
+func TestConfig_CollectsAllErrors(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "no-port")
+	t.Setenv("CACHE_TTL", "5")
+	t.Setenv("CACHE_CAPACITY", "many")
+	t.Setenv("CACHE_POLICY", "mru")
+	t.Setenv("HTTP_PORT", "99999")
+
+	_, err := config.Load("")
+	var verr *config.ValidationError
+	require.True(t, errors.As(err, &verr))
+
+	msg := err.Error()
+	for _, want := range []string{"DB_HOST is required", "KAFKA_TOPIC is required", `"no-port" is not host:port`,
+		`CACHE_TTL: "5" is not a duration`, `CACHE_CAPACITY: "many"`, `unknown policy "mru"`, `HTTP_PORT: "99999"`} {
+		assert.Contains(t, msg, want)
+	}
+}
+
+func TestConfig_LegacyKeys(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("CACHE_TTL_MIN", "15")
+	t.Setenv("HTTP_SHUTDOWNTIMEOUT_SEC", "3")
+	t.Setenv("WEB_PORT", "8082")
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, cfg.CacheTTL)
+	assert.Equal(t, 3*time.Second, cfg.HTTPShutdownTimeout)
+	assert.Equal(t, "8082", cfg.HTTPPort)
+
+	// новое имя главнее старого
+	t.Setenv("CACHE_TTL", "2m")
+	cfg, err = config.Load("")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, cfg.CacheTTL)
+}
+
+func TestConfig_RedactedHidesSecrets(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("REDIS_PASSWORD", "redis-secret")
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	out := cfg.Redacted()
+	assert.Contains(t, out, "DB_HOST=db\n")
+	assert.Contains(t, out, "DB_PASSWORD=******\n")
+	assert.Contains(t, out, "CACHE_TTL=5m0s\n")
+	assert.NotContains(t, out, "p@ss word")
+	assert.NotContains(t, out, "redis-secret")
+}
+
+func TestConfig_LayersAndSources(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+db:
+  host: yaml-host
+  user: yaml-user
+  name: orders
+kafka:
+  brokers: yaml-kafka:9092
+  topic: orders
+cache:
+  ttl: 1m
+  capacity: 50
+peers:
+  self: http://a:8081
+  list: [http://a:8081, http://b:8081]
+`), 0o644))
+	envPath := filepath.Join(dir, ".env")
+	require.NoError(t, os.WriteFile(envPath, []byte("CACHE_TTL=2m\nDB_USER=dotenv-user\n"), 0o644))
+	t.Setenv("DB_USER", "env-user")
+
+	flags := config.NewFlagSet("test")
+	require.NoError(t, flags.Parse([]string{"--config", yamlPath, "--env-file", envPath, "--cache.capacity=70"}))
+
+	cfg, src, err := config.LoadWith(config.OptionsFromFlags(flags))
+	require.NoError(t, err)
+
+	assert.Equal(t, "yaml-host", cfg.DBHost)
+	assert.Equal(t, "file "+yamlPath, src["db.host"])
+	assert.Equal(t, 2*time.Minute, cfg.CacheTTL)
+	assert.Equal(t, envPath, src["cache.ttl"])
+	assert.Equal(t, "env-user", cfg.DBUser)
+	assert.Equal(t, "env DB_USER", src["db.user"])
+	assert.Equal(t, 70, cfg.CacheCapacity)
+	assert.Equal(t, "flag --cache.capacity", src["cache.capacity"])
+	assert.Equal(t, []string{"http://a:8081", "http://b:8081"}, cfg.Peers)
+	assert.Equal(t, "default", src["http.port"])
+
+	out := cfg.Describe(src)
+	assert.Contains(t, out, "flag --cache.capacity")
+	assert.Contains(t, out, "db.password")
+}
+
+func TestConfig_ExplicitConfigFileMustExist(t *testing.T) {
+	setRequiredEnv(t)
+	flags := config.NewFlagSet("test")
+	require.NoError(t, flags.Parse([]string{"--config", filepath.Join(t.TempDir(), "nope.yaml")}))
+	_, _, err := config.LoadWith(config.OptionsFromFlags(flags))
+	assert.Error(t, err)
+
+	// файл по умолчанию необязателен
+	_, _, err = config.LoadWith(config.Options{ConfigFile: filepath.Join(t.TempDir(), "config.yaml")})
+	assert.NoError(t, err)
+}
+
+func TestConfig_ErrorsNameTheSource(t *testing.T) {
+	setRequiredEnv(t)
+	flags := config.NewFlagSet("test")
+	require.NoError(t, flags.Parse([]string{"--logging.level=loud", "--redis.db=first"}))
+	cfg, _, err := config.LoadWith(config.OptionsFromFlags(flags))
+	require.Error(t, err)
+	require.NotNil(t, cfg, "частичный конфиг нужен для config print")
+	assert.Contains(t, err.Error(), `unknown level "loud"`)
+	assert.Contains(t, err.Error(), `redis.db/REDIS_DB: "first" is not an integer (from flag --redis.db)`)
+}
+
+func TestConfig_SecretsFromFiles(t *testing.T) {
+	setRequiredEnv(t)
+	dir := t.TempDir()
+	pwd := filepath.Join(dir, "db_password")
+	require.NoError(t, os.WriteFile(pwd, []byte("from 'file'\n"), 0o600))
+	token := filepath.Join(dir, "admin_token")
+	require.NoError(t, os.WriteFile(token, []byte("tok"), 0o600))
+	t.Setenv("DB_PASSWORD", "") // восстановит значение после теста
+	os.Unsetenv("DB_PASSWORD")
+	t.Setenv("DB_PASSWORD_FILE", pwd)
+
+	yamlPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte("admin:\n  token_file: "+token+"\n"), 0o644))
+
+	cfg, src, err := config.LoadWith(config.Options{ConfigFile: yamlPath})
+	require.NoError(t, err)
+	assert.Equal(t, "from 'file'", cfg.DBPassword, "перевод строки в конце файла отбрасывается")
+	assert.Contains(t, src["db.password"], "DB_PASSWORD_FILE")
+	assert.Equal(t, "tok", cfg.AdminToken)
+
+	// _FILE работает только для секретов
+	t.Setenv("DB_HOST_FILE", pwd)
+	cfg, _, err = config.LoadWith(config.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "db", cfg.DBHost)
+
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	_, _, err = config.LoadWith(config.Options{})
+	assert.ErrorContains(t, err, "db.password/DB_PASSWORD: read secret file")
+}
+
+func TestConfig_DBURLReplacesDiscreteFields(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "kafka:9092")
+	t.Setenv("KAFKA_TOPIC", "orders")
+	t.Setenv("DB_URL", "postgres://u:secret@db:5432/orders")
+	t.Setenv("DB_SSLCERT", "client.crt")
+
+	cfg, err := config.Load("")
+	assert.ErrorContains(t, err, "db.sslcert/DB_SSLCERT and db.sslkey/DB_SSLKEY must be set together")
+	assert.NotContains(t, err.Error(), "DB_HOST")
+	assert.NotContains(t, cfg.Redacted(), "secret")
+}
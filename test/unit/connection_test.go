@@ -0,0 +1,178 @@
+package unit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/kafka"
+)
+
+// writeTestCert создаёт самоподписанный сертификат и ключ в dir.
+func writeTestCert(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func TestConnString_EscapesPassword(t *testing.T) {
+	cfg := &config.Config{
+		DBHost: "db", DBPort: "5432", DBUser: "orders", DBName: "orders db",
+		DBPassword: `p@ss word'"\`, DBSSLMode: "disable",
+	}
+	pc, err := pgx.ParseConfig(db.ConnString(cfg))
+	require.NoError(t, err)
+	assert.Equal(t, `p@ss word'"\`, pc.Password)
+	assert.Equal(t, "orders db", pc.Database)
+	assert.Equal(t, "db", pc.Host)
+}
+
+func TestConnString_DSNAndClientCerts(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeTestCert(t, dir)
+
+	for _, dsn := range []string{
+		"postgres://u:pw@db:5432/orders?sslmode=require",
+		"host=db user=u password='p w' dbname=orders sslmode=require",
+	} {
+		cfg := &config.Config{DBURL: dsn, DBSSLCert: cert, DBSSLKey: key}
+		pc, err := pgx.ParseConfig(db.ConnString(cfg))
+		require.NoError(t, err, dsn)
+		require.NotNil(t, pc.TLSConfig, dsn)
+		assert.Len(t, pc.TLSConfig.Certificates, 1, "клиентский сертификат подхвачен: %s", dsn)
+		assert.Equal(t, "orders", pc.Database)
+	}
+}
+
+func TestPoolConfig_AppliesSettings(t *testing.T) {
+	cfg := &config.Config{
+		DBURL:                   "postgres://u@db/orders",
+		DBPoolMaxConns:          20,
+		DBPoolMinConns:          2,
+		DBPoolHealthCheckPeriod: 15 * time.Second,
+		DBPoolMaxConnIdleTime:   time.Minute,
+		DBStatementCacheMode:    "simple_protocol",
+	}
+	pc, err := db.PoolConfig(cfg)
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, pc.MaxConns)
+	assert.EqualValues(t, 2, pc.MinConns)
+	assert.Equal(t, 15*time.Second, pc.HealthCheckPeriod)
+	assert.Equal(t, time.Minute, pc.MaxConnIdleTime)
+	assert.Equal(t, pgx.QueryExecModeSimpleProtocol, pc.ConnConfig.DefaultQueryExecMode)
+}
+
+func TestKafkaTLSConfig(t *testing.T) {
+	cert, key := writeTestCert(t, t.TempDir())
+
+	cfg, err := kafka.TLSConfig(cert, cert, key, false)
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.Len(t, cfg.Certificates, 1)
+
+	_, err = kafka.TLSConfig(key, "", "", false)
+	assert.ErrorContains(t, err, "no certificates")
+	_, err = kafka.TLSConfig("", cert, "", false)
+	assert.Error(t, err)
+}
+
+func TestNewConsumerConfig_SecurityAndGroupOptions(t *testing.T) {
+	cert, key := writeTestCert(t, t.TempDir())
+	cfg := &config.Config{
+		KafkaBrokers:           "k1:9093,k2:9093",
+		KafkaGroupID:           "g",
+		KafkaTopic:             "orders",
+		KafkaTLSCA:             cert,
+		KafkaTLSCert:           cert,
+		KafkaTLSKey:            key,
+		KafkaSASLMechanism:     "SCRAM-SHA-512",
+		KafkaSASLUsername:      "svc",
+		KafkaSASLPassword:      "pw",
+		KafkaStartOffset:       "latest",
+		KafkaIsolationLevel:    "read_committed",
+		KafkaSessionTimeout:    45 * time.Second,
+		KafkaRebalanceStrategy: []string{"roundrobin", "range"},
+	}
+	cc, err := kafka.NewConsumerConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k1:9093", "k2:9093"}, cc.Brokers)
+	require.NotNil(t, cc.Dialer)
+	require.NotNil(t, cc.Dialer.TLS)
+	assert.Len(t, cc.Dialer.TLS.Certificates, 1)
+	assert.Equal(t, "SCRAM-SHA-512", cc.Dialer.SASLMechanism.Name())
+	assert.Equal(t, kafkago.LastOffset, cc.StartOffset)
+	assert.Equal(t, kafkago.ReadCommitted, cc.IsolationLevel)
+	assert.Equal(t, 45*time.Second, cc.SessionTimeout)
+	require.Len(t, cc.GroupBalancers, 2)
+	assert.Equal(t, "roundrobin", cc.GroupBalancers[0].ProtocolName())
+}
+
+func TestNewConsumerConfig_PlainWithoutTLS(t *testing.T) {
+	cc, err := kafka.NewConsumerConfig(&config.Config{
+		KafkaBrokers:       "k:9092",
+		KafkaStartOffset:   "earliest",
+		KafkaSASLMechanism: "plain",
+		KafkaSASLUsername:  "u",
+		KafkaSASLPassword:  "p",
+	})
+	require.NoError(t, err)
+	assert.Nil(t, cc.Dialer.TLS)
+	assert.Equal(t, "PLAIN", cc.Dialer.SASLMechanism.Name())
+	assert.Equal(t, kafkago.FirstOffset, cc.StartOffset)
+
+	// без TLS и SASL остаётся dialer kafka-go по умолчанию
+	cc, err = kafka.NewConsumerConfig(&config.Config{KafkaBrokers: "k:9092"})
+	require.NoError(t, err)
+	assert.Nil(t, cc.Dialer)
+
+	_, err = kafka.NewConsumerConfig(&config.Config{KafkaBrokers: "k:9092", KafkaRebalanceStrategy: []string{"sticky"}})
+	assert.ErrorContains(t, err, "sticky")
+}
+
+func TestConfig_KafkaSecurityValidation(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("KAFKA_SASL_MECHANISM", "gssapi")
+	t.Setenv("KAFKA_START_OFFSET", "middle")
+	t.Setenv("KAFKA_REBALANCE_STRATEGY", "range,sticky")
+
+	_, err := config.Load("")
+	require.Error(t, err)
+	for _, want := range []string{`unknown mechanism "gssapi"`, "KAFKA_SASL_USERNAME is required",
+		`"middle" is not earliest or latest`, `unknown strategy "sticky"`} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
@@ -0,0 +1,192 @@
+package unit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/handlers"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/service"
+)
+
+// sampleOrder повторяет producer-service/orders/order1.json, размножая товары.
+func sampleOrder(uid string, items int) *models.Order {
+	o := &models.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+	}
+	for i := 0; i < items; i++ {
+		o.Items = append(o.Items, models.Item{
+			ChrtID: 9934930 + i, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: fmt.Sprintf("ab4219087a764ae0btest%d", i),
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		})
+	}
+	return o
+}
+
+func newEncodedCache(t testing.TB, withGzip bool) *cache.EncodedCache[*models.Order] {
+	inner, err := cache.New[*cache.Encoded[*models.Order]](cache.PolicyLRU, 4, 100, time.Minute)
+	require.NoError(t, err)
+	return cache.NewEncodedCache(inner, withGzip)
+}
+
+func TestEncodedCache_SetGet(t *testing.T) {
+	c := newEncodedCache(t, true)
+	o := sampleOrder("uid-1", 2)
+	c.Set("uid-1", o)
+
+	got, ok := c.Get("uid-1")
+	require.True(t, ok)
+	assert.Equal(t, o, got)
+	assert.NotSame(t, o, got, "в кэше копия, а не значение вызывающего")
+
+	e, ok := c.GetEncoded("uid-1")
+	require.True(t, ok)
+	assert.Contains(t, string(e.JSON), `"order_uid":"uid-1"`)
+	assert.NotEmpty(t, e.ETag)
+
+	zr, err := gzip.NewReader(bytes.NewReader(e.Gzip))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, e.JSON, plain)
+
+	c.Delete("uid-1")
+	_, ok = c.GetEncoded("uid-1")
+	assert.False(t, ok)
+}
+
+// Изменение исходного заказа после Set не должно расходиться с JSON и ETag.
+func TestEncodedCache_ValueDetachedFromCaller(t *testing.T) {
+	c := newEncodedCache(t, false)
+	o := sampleOrder("uid-1", 2)
+	o.UpdatedAt = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c.Set("uid-1", o)
+
+	o.TrackNumber = "CHANGED"
+	o.Items[0].Name = "changed"
+	o.Items = append(o.Items, o.Items[0])
+
+	e, ok := c.GetEncoded("uid-1")
+	require.True(t, ok)
+	var decoded models.Order
+	require.NoError(t, json.Unmarshal(e.JSON, &decoded))
+	assert.Equal(t, decoded.TrackNumber, e.Value.TrackNumber)
+	assert.Equal(t, decoded.Items, e.Value.Items)
+	assert.Equal(t, "Mascaras", e.Value.Items[0].Name)
+	assert.True(t, o.UpdatedAt.Equal(e.Value.UpdatedAt), "поля вне JSON тоже копируются")
+}
+
+func TestEncode_ETagFollowsContent(t *testing.T) {
+	a, err := cache.Encode(sampleOrder("uid-1", 1), false)
+	require.NoError(t, err)
+	b, err := cache.Encode(sampleOrder("uid-1", 1), false)
+	require.NoError(t, err)
+	c, err := cache.Encode(sampleOrder("uid-1", 2), false)
+	require.NoError(t, err)
+
+	assert.Equal(t, a.ETag, b.ETag)
+	assert.NotEqual(t, a.ETag, c.ETag)
+	assert.Nil(t, a.Gzip)
+}
+
+func TestGetOrderByUID_EncodedGzip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	c := newEncodedCache(t, true)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	h := handlers.NewOrderHandler(service.NewOrderService(&mockRepo{}, c), "")
+	r.GET("/order/:orderId", h.GetOrderByUID)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	req = httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), `"order_uid":"uid-1"`)
+}
+
+func TestGetOrderByUID_EncodedMissLoadsRepo(t *testing.T) {
+	c := newEncodedCache(t, false)
+	repo := &mockRepo{getOrderRes: sampleOrder("uid-2", 1)}
+	svc := service.NewOrderService(repo, c)
+
+	e, err := svc.GetOrderEncoded(t.Context(), "uid-2")
+	require.NoError(t, err)
+	assert.Contains(t, string(e.JSON), `"order_uid":"uid-2"`)
+
+	_, err = svc.GetOrderEncoded(t.Context(), "uid-2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.callsGet)
+}
+
+// BenchmarkGetOrderByUID_CacheHit сравнивает попадание в обычный кэш
+// (маршалинг на каждый запрос) и в кэш готовых ответов.
+func BenchmarkGetOrderByUID_CacheHit(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	order := sampleOrder("uid-1", 20)
+
+	plain := cache.NewShardedLRU[*models.Order](4, 100, time.Hour)
+	cases := []struct {
+		name  string
+		cache ports.Cache[string, *models.Order]
+	}{
+		{"marshal", plain},
+		{"encoded", newEncodedCache(b, false)},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			tc.cache.Set("uid-1", order)
+			r := gin.New()
+			h := handlers.NewOrderHandler(service.NewOrderService(&mockRepo{}, tc.cache), "")
+			r.GET("/order/:orderId", h.GetOrderByUID)
+			req := httptest.NewRequest(http.MethodGet, "/order/uid-1", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/export"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/routes"
+)
+
+// fakeExporter отдаёт orders по одному, как курсор, и падает после failAfter заказов.
+type fakeExporter struct {
+	orders    []*models.Order
+	failAfter int
+	filter    ports.OrderFilter
+	parts     ports.OrderParts
+}
+
+func (f *fakeExporter) ExportOrders(ctx context.Context, filter ports.OrderFilter, parts ports.OrderParts, fn func(*models.Order) error) error {
+	f.filter, f.parts = filter, parts
+	for i, o := range f.orders {
+		if f.failAfter > 0 && i == f.failAfter {
+			return errors.New("connection reset")
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	if f.failAfter < 0 {
+		return errors.New("cursor failed")
+	}
+	return nil
+}
+
+func exportRouter(src ports.OrderExporter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return routes.InitExportRoutes(gin.New(), src, time.Second)
+}
+
+func getExport(r http.Handler, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/export?"+query, nil))
+	return w
+}
+
+func readCSV(t *testing.T, body string) [][]string {
+	recs, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	return recs
+}
+
+func TestExportWriter_CSVOrderLayout(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.Options{Format: export.FormatCSV})
+	require.NoError(t, err)
+	assert.Equal(t, ports.OrderParts{Delivery: true, Payment: true}, w.Parts(), "товары в раскладке orders не читаются")
+
+	n, err := export.Run(context.Background(), &fakeExporter{orders: []*models.Order{sampleOrder("uid-1", 3), sampleOrder("uid-2", 1)}}, ports.OrderFilter{}, w)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	recs := readCSV(t, buf.String())
+	require.Len(t, recs, 3)
+	header := recs[0]
+	assert.Equal(t, []string{"order_uid", "track_number", "entry", "delivery.name"}, header[:4], "порядок полей модели")
+	assert.Contains(t, header, "payment.amount")
+	assert.NotContains(t, header, "items.chrt_id")
+	row := map[string]string{}
+	for i, h := range header {
+		row[h] = recs[1][i]
+	}
+	assert.Equal(t, "uid-1", row["order_uid"])
+	assert.Equal(t, "Kiryat Mozkin", row["delivery.city"])
+	assert.Equal(t, "1817", row["payment.amount"])
+	assert.Equal(t, "2021-11-26T06:22:19Z", row["date_created"])
+}
+
+func TestExportWriter_CSVItemLayoutWithColumns(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.Options{
+		Format: export.FormatCSV, Layout: export.LayoutItems,
+		Columns: []string{"order_uid", "items.chrt_id", "items.price"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ports.OrderParts{Items: true}, w.Parts())
+
+	_, err = export.Run(context.Background(), &fakeExporter{orders: []*models.Order{sampleOrder("uid-1", 2), sampleOrder("uid-2", 1)}}, ports.OrderFilter{}, w)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"order_uid", "items.chrt_id", "items.price"},
+		{"uid-1", "9934930", "453"},
+		{"uid-1", "9934931", "453"},
+		{"uid-2", "9934930", "453"},
+	}, readCSV(t, buf.String()))
+}
+
+func TestExportWriter_NDJSONColumns(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(&buf, export.Options{Format: export.FormatNDJSON, Columns: []string{"order_uid", "payment.amount"}})
+	require.NoError(t, err)
+	assert.Equal(t, ports.OrderParts{Payment: true}, w.Parts())
+
+	_, err = export.Run(context.Background(), &fakeExporter{orders: []*models.Order{sampleOrder("uid-1", 1), sampleOrder("uid-2", 1)}}, ports.OrderFilter{}, w)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"order_uid":"uid-2","payment":{"amount":1817}}`, lines[1])
+}
+
+func TestExportWriter_RejectsBadOptions(t *testing.T) {
+	for _, tc := range []struct {
+		opts export.Options
+		want string
+	}{
+		{export.Options{Format: "xml"}, "unknown format"},
+		{export.Options{Format: export.FormatCSV, Layout: "flat"}, "unknown layout"},
+		{export.Options{Format: export.FormatCSV, Columns: []string{"nope"}}, `unknown column "nope"`},
+		{export.Options{Format: export.FormatCSV, Columns: []string{"items.price"}}, "needs layout=items"},
+		{export.Options{Format: export.FormatNDJSON, Layout: export.LayoutItems}, "csv only"},
+		{export.Options{Format: export.FormatNDJSON, Columns: []string{"payment.nope"}}, "unknown field"},
+	} {
+		_, err := export.NewWriter(&bytes.Buffer{}, tc.opts)
+		assert.ErrorContains(t, err, tc.want)
+	}
+}
+
+func TestExportHandler_StreamsWithFilters(t *testing.T) {
+	src := &fakeExporter{orders: []*models.Order{sampleOrder("uid-1", 1)}}
+	r := exportRouter(src)
+
+	w := getExport(r, "format=csv&layout=items&columns=order_uid,items.rid&customer_id=a,b&locale=en&created_from=2024-05-01T00:00:00Z")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="orders.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "order_uid,items.rid\nuid-1,ab4219087a764ae0btest0\n", w.Body.String())
+
+	assert.Equal(t, []string{"a", "b"}, src.filter.CustomerIDs)
+	assert.Equal(t, []string{"en"}, src.filter.Locales)
+	assert.Nil(t, src.filter.DeliveryServices)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), src.filter.CreatedFrom)
+	assert.True(t, src.filter.CreatedTo.IsZero())
+
+	w = getExport(r, "format=ndjson")
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"order_uid":"uid-1"`)
+
+	for _, q := range []string{"format=xml", "columns=nope", "created_to=yesterday"} {
+		assert.Equal(t, http.StatusBadRequest, getExport(r, q).Code, q)
+	}
+}
+
+func TestExportHandler_Errors(t *testing.T) {
+	// ошибка до первой отправки — обычный ответ с ошибкой
+	w := getExport(exportRouter(&fakeExporter{failAfter: -1}), "format=csv")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), "ошибка не выдаёт себя за выгрузку")
+	assert.Contains(t, w.Body.String(), "cursor failed")
+
+	// после отправки первой порции статус уже ушёл — ответ просто обрывается
+	var orders []*models.Order
+	for i := 0; i < 150; i++ {
+		orders = append(orders, sampleOrder("uid", 1))
+	}
+	w = getExport(exportRouter(&fakeExporter{orders: orders, failAfter: 120}), "format=ndjson&columns=order_uid")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 100, strings.Count(w.Body.String(), "\n"), "клиент получил только сброшенное")
+}
@@ -25,7 +25,7 @@ func TestGetOrderByUID_OK(t *testing.T) {
 	repo := &mockRepo{getOrderRes: &models.Order{OrderUID: "uid-1"}}
 	cache := newMockCache() 
 	svc := service.NewOrderService(repo, cache)
-	h := handlers.NewOrderHandler(svc)
+	h := handlers.NewOrderHandler(svc, "")
 
 	r.GET("/order/:orderId", h.GetOrderByUID)
 
@@ -48,7 +48,7 @@ func TestGetOrderByUID_NotFound(t *testing.T) {
 	repo := &mockRepo{getOrderErr: context.Canceled}
 	cache := newMockCache()
 	svc := service.NewOrderService(repo, cache)
-	h := handlers.NewOrderHandler(svc)
+	h := handlers.NewOrderHandler(svc, "")
 
 	r.GET("/order/:orderId", h.GetOrderByUID)
 
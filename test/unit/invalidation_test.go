@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/bootstrap"
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/models"
+)
+
+func TestParseOrderChange(t *testing.T) {
+	ch, err := db.ParseOrderChange(`{"op":"delete","uid":"uid-1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, db.OrderChange{Op: db.OrderDeleted, UID: "uid-1"}, ch)
+
+	for _, bad := range []string{`nope`, `{"op":"truncate","uid":"x"}`, `{"op":"upsert"}`} {
+		_, err := db.ParseOrderChange(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCacheInvalidator_Apply(t *testing.T) {
+	repo := newCountingRepo("uid-1", "uid-2")
+	c := cache.NewShardedLRU[*models.Order](2, 10, time.Minute)
+	c.Set("uid-1", &models.Order{OrderUID: "uid-1"})
+	inv := bootstrap.NewCacheInvalidator(repo, c)
+
+	// обновление закэшированного заказа перечитывает его из БД
+	inv.Apply(context.Background(), db.OrderChange{Op: db.OrderUpserted, UID: "uid-1"})
+	got, ok := c.Get("uid-1")
+	require.True(t, ok)
+	assert.Len(t, got.Items, 1)
+
+	// незакэшированные заказы в кэш не попадают
+	inv.Apply(context.Background(), db.OrderChange{Op: db.OrderUpserted, UID: "uid-2"})
+	_, ok = c.Get("uid-2")
+	assert.False(t, ok)
+	assert.Equal(t, 0, repo.count("uid-2"))
+
+	inv.Apply(context.Background(), db.OrderChange{Op: db.OrderDeleted, UID: "uid-1"})
+	_, ok = c.Get("uid-1")
+	assert.False(t, ok)
+}
+
+func TestCacheInvalidator_Resync(t *testing.T) {
+	repo := newCountingRepo("uid-1")
+	c := cache.NewShardedLRU[*models.Order](2, 10, time.Minute)
+	c.Set("uid-1", &models.Order{OrderUID: "uid-1"})
+	c.Set("gone", &models.Order{OrderUID: "gone"})
+
+	bootstrap.NewCacheInvalidator(repo, c).Resync(context.Background())
+
+	got, ok := c.Get("uid-1")
+	require.True(t, ok)
+	assert.Len(t, got.Items, 1)
+	_, ok = c.Get("gone")
+	assert.False(t, ok, "удалённый за время разрыва заказ уходит из кэша")
+}
@@ -0,0 +1,201 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/routes"
+)
+
+// fakeProcessed — журнал processed_messages в памяти.
+type fakeProcessed struct {
+	mu      sync.Mutex
+	keys    map[string]time.Time
+	failing error
+}
+
+func newFakeProcessed() *fakeProcessed {
+	return &fakeProcessed{keys: map[string]time.Time{}}
+}
+
+func (f *fakeProcessed) SeenSince(ctx context.Context, key string, since time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing != nil {
+		return false, f.failing
+	}
+	at, ok := f.keys[key]
+	return ok && !at.Before(since), nil
+}
+
+func (f *fakeProcessed) MarkProcessed(ctx context.Context, key string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing != nil {
+		return f.failing
+	}
+	f.keys[key] = at
+	return nil
+}
+
+func (f *fakeProcessed) PurgeProcessed(ctx context.Context, before time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for k, at := range f.keys {
+		if at.Before(before) {
+			delete(f.keys, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+var dedupKeys = []string{kafka.DedupKeyHeader, kafka.DedupKeyEventID, kafka.DedupKeyHash}
+
+func newDedup(t *testing.T, store *fakeProcessed, sources ...string) *kafka.Deduplicator {
+	if len(sources) == 0 {
+		sources = dedupKeys
+	}
+	d, err := kafka.NewDeduplicator(store, time.Hour, 100, sources)
+	require.NoError(t, err)
+	return d
+}
+
+func statusMsg(offset int64, value, idemKey string) kafkago.Message {
+	msg := kafkago.Message{Topic: "order-status", Offset: offset, Key: []byte("uid-1"), Value: []byte(value)}
+	if idemKey != "" {
+		msg.Headers = []kafkago.Header{{Key: kafka.IdempotencyKeyHeader, Value: []byte(idemKey)}}
+	}
+	return msg
+}
+
+func TestDedup_KeySources(t *testing.T) {
+	d := newDedup(t, newFakeProcessed())
+	assert.Equal(t, "h:req-1", d.Key(statusMsg(0, `{"order_uid":"uid-1","status":1}`, "req-1")))
+	assert.Equal(t, "e:ev-1", d.Key(statusMsg(0, `{"schema_version":2,"event_id":"ev-1","payload":{}}`, "")))
+
+	a := d.Key(statusMsg(0, `{"order_uid":"uid-1","status":1}`, ""))
+	b := d.Key(statusMsg(5, `{"order_uid":"uid-1","status":1}`, ""))
+	c := d.Key(statusMsg(6, `{"order_uid":"uid-1","status":2}`, ""))
+	assert.Equal(t, a, b, "тот же payload под другим смещением — тот же ключ")
+	assert.NotEqual(t, a, c)
+
+	byKey := newDedup(t, newFakeProcessed(), kafka.DedupKeyMessage)
+	assert.Equal(t, "k:order-status:uid-1", byKey.Key(statusMsg(0, `{}`, "")))
+	assert.Empty(t, byKey.Key(kafkago.Message{Topic: "order-status"}))
+
+	_, err := kafka.NewDeduplicator(nil, time.Hour, 100, []string{"offset"})
+	assert.Error(t, err)
+}
+
+func TestDedup_DispatcherSkipsDuplicates(t *testing.T) {
+	store := newFakeUpdater("uid-1")
+	processed := newFakeProcessed()
+	d := newDispatcher(store, newMockCache(), kafka.FallbackSkip, nil)
+	d.Dedup = newDedup(t, processed)
+
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), statusMsg(1, `{"order_uid":"uid-1","status":301}`, "req-1")))
+	assert.Equal(t, 301, store.status["uid-1"])
+
+	// ретрай продюсера: другое смещение и другое тело, но тот же idempotency-key
+	store.status["uid-1"] = 0
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), statusMsg(2, `{"order_uid":"uid-1","status":301} `, "req-1")))
+	assert.Equal(t, 0, store.status["uid-1"], "дубль не применяется, но смещение коммитится")
+
+	// без заголовка дубль ловится по хэшу тела
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), statusMsg(3, `{"order_uid":"uid-1","status":302}`, "")))
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), statusMsg(4, `{"order_uid":"uid-1","status":302}`, "")))
+	assert.Equal(t, kafka.DedupStats{Checked: 4, Duplicates: 2}, d.Dedup.Stats())
+	assert.Len(t, processed.keys, 2)
+
+	// после перезапуска память пуста, но журнал в Postgres помнит ключи
+	d.Dedup = newDedup(t, processed)
+	store.status["uid-1"] = 0
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), statusMsg(5, `{"order_uid":"uid-1","status":302}`, "")))
+	assert.Equal(t, 0, store.status["uid-1"])
+	assert.Equal(t, int64(1), d.Dedup.Stats().Duplicates)
+}
+
+func TestDedup_FailedMessagesAreNotRemembered(t *testing.T) {
+	store := newFakeUpdater("uid-1")
+	store.failing = errors.New("connection reset")
+	d := newDispatcher(store, newMockCache(), kafka.FallbackSkip, nil)
+	d.Dedup = newDedup(t, newFakeProcessed())
+
+	msg := statusMsg(1, `{"order_uid":"uid-1","status":301}`, "req-1")
+	assert.Equal(t, kafka.OutcomeRetry, d.Dispatch(context.Background(), msg))
+
+	store.failing = nil
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), msg))
+	assert.Equal(t, 301, store.status["uid-1"], "повтор после ошибки — не дубль")
+}
+
+func TestDedup_StoreErrorsDoNotDropMessages(t *testing.T) {
+	processed := newFakeProcessed()
+	processed.failing = errors.New("postgres is down")
+	dd := newDedup(t, processed)
+
+	assert.False(t, dd.Seen(context.Background(), "h:req-1"))
+	dd.Mark(context.Background(), "h:req-1")
+	assert.True(t, dd.Seen(context.Background(), "h:req-1"), "память работает и без журнала")
+	assert.Equal(t, int64(2), dd.Stats().StoreErrors)
+}
+
+func TestDedup_WindowAndPurge(t *testing.T) {
+	processed := newFakeProcessed()
+	processed.keys["h:old"] = time.Now().Add(-2 * time.Hour)
+	processed.keys["h:new"] = time.Now()
+	dd := newDedup(t, processed)
+
+	assert.False(t, dd.Seen(context.Background(), "h:old"), "ключ старше окна не считается дублем")
+	assert.True(t, dd.Seen(context.Background(), "h:new"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dd.RunPurger(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		processed.mu.Lock()
+		defer processed.mu.Unlock()
+		_, ok := processed.keys["h:old"]
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.Contains(t, processed.keys, "h:new")
+}
+
+func TestDedup_DuplicatesInMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := newDispatcher(newFakeUpdater("uid-1"), newMockCache(), kafka.FallbackSkip, nil)
+	d.Dedup = newDedup(t, newFakeProcessed())
+	d.Dispatch(context.Background(), statusMsg(1, `{"order_uid":"uid-1","status":301}`, "req-1"))
+	d.Dispatch(context.Background(), statusMsg(2, `{"order_uid":"uid-1","status":301}`, "req-1"))
+
+	r := routes.InitMetricsRoutes(gin.New(), d.Dedup)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "# TYPE kafka_dedup_duplicates_total counter\nkafka_dedup_duplicates_total 1\n")
+	assert.Contains(t, w.Body.String(), "kafka_dedup_checked_total 2\n")
+
+	// без отсева повторов эндпоинт есть, но счётчиков нет
+	w = httptest.NewRecorder()
+	routes.InitMetricsRoutes(gin.New(), nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "kafka_dedup")
+}
@@ -0,0 +1,356 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/db"
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+)
+
+type fakeUpdater struct {
+	orders  map[string]bool
+	status  map[string]int
+	chrts   map[string][]int
+	tracks  map[string]string
+	failing error
+}
+
+func newFakeUpdater(uids ...string) *fakeUpdater {
+	u := &fakeUpdater{orders: map[string]bool{}, status: map[string]int{}, chrts: map[string][]int{}, tracks: map[string]string{}}
+	for _, uid := range uids {
+		u.orders[uid] = true
+	}
+	return u
+}
+
+func (u *fakeUpdater) check(uid string) error {
+	if u.failing != nil {
+		return u.failing
+	}
+	if !u.orders[uid] {
+		return fmt.Errorf("update %s: %w", uid, ports.ErrOrderNotFound)
+	}
+	return nil
+}
+
+func (u *fakeUpdater) UpdateItemsStatus(ctx context.Context, uid string, status int, chrtIDs []int) error {
+	if err := u.check(uid); err != nil {
+		return err
+	}
+	u.status[uid] = status
+	u.chrts[uid] = chrtIDs
+	return nil
+}
+
+func (u *fakeUpdater) UpdateTrackNumber(ctx context.Context, uid, track string) error {
+	if err := u.check(uid); err != nil {
+		return err
+	}
+	u.tracks[uid] = track
+	return nil
+}
+
+func (u *fakeUpdater) DeleteOrder(ctx context.Context, uid string) error {
+	if err := u.check(uid); err != nil {
+		return err
+	}
+	delete(u.orders, uid)
+	return nil
+}
+
+type fakeWriter struct {
+	msgs []kafkago.Message
+	err  error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func header(msg kafkago.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func newDispatcher(store *fakeUpdater, c *mockCache, policy kafka.FallbackPolicy, dlq kafka.MessageWriter) *kafka.Dispatcher {
+	cfg := &config.Config{KafkaTopic: "orders", KafkaTopics: []string{"order-status=order.status", "order-events"}}
+	return &kafka.Dispatcher{
+		Registry: kafka.NewOrderRegistry(cfg, &mockRepo{}, store, c),
+		Fallback: policy,
+		DLQ:      dlq,
+	}
+}
+
+func TestRegistry_ResolvesByHeaderThenTopic(t *testing.T) {
+	cfg := &config.Config{KafkaTopic: "orders", KafkaTopics: []string{"order-status=order.status", "order-events"}}
+	assert.Equal(t, []string{"orders", "order-status", "order-events"}, kafka.Topics(cfg))
+
+	r := kafka.NewOrderRegistry(cfg, &mockRepo{}, newFakeUpdater(), newMockCache())
+	typ, h := r.Resolve(kafkago.Message{Topic: "orders"})
+	assert.Equal(t, kafka.EventOrderSnapshot, typ)
+	assert.NotNil(t, h)
+
+	typ, _ = r.Resolve(kafkago.Message{Topic: "order-status"})
+	assert.Equal(t, kafka.EventOrderStatus, typ)
+
+	typ, h = r.Resolve(kafkago.Message{Topic: "orders", Headers: []kafkago.Header{{Key: kafka.EventTypeHeader, Value: []byte("delivery.tracking")}}})
+	assert.Equal(t, kafka.EventDeliveryTracking, typ, "заголовок главнее привязки топика")
+	assert.NotNil(t, h)
+
+	_, h = r.Resolve(kafkago.Message{Topic: "order-events"})
+	assert.Nil(t, h, "топик без привязки и без заголовка — неизвестный тип")
+}
+
+func TestDispatcher_AppliesEventsAndEvictsCache(t *testing.T) {
+	store := newFakeUpdater("uid-1", "uid-2")
+	c := newMockCache()
+	c.store["uid-1"] = sampleOrder("uid-1", 1)
+	d := newDispatcher(store, c, kafka.FallbackSkip, nil)
+
+	out := d.Dispatch(context.Background(), kafkago.Message{Topic: "order-status", Value: []byte(`{"order_uid":"uid-1","status":300}`)})
+	assert.Equal(t, kafka.OutcomeCommit, out)
+	assert.Equal(t, 300, store.status["uid-1"])
+	_, cached := c.store["uid-1"]
+	assert.False(t, cached, "устаревший заказ убран из кэша")
+
+	out = d.Dispatch(context.Background(), kafkago.Message{
+		Topic:   "order-events",
+		Headers: []kafkago.Header{{Key: kafka.EventTypeHeader, Value: []byte(kafka.EventDeliveryTracking)}},
+		Value:   []byte(`{"order_uid":"uid-2","track_number":"NEWTRACK"}`),
+	})
+	assert.Equal(t, kafka.OutcomeCommit, out)
+	assert.Equal(t, "NEWTRACK", store.tracks["uid-2"])
+
+	cancel := kafkago.Message{
+		Topic:   "order-events",
+		Headers: []kafkago.Header{{Key: kafka.EventTypeHeader, Value: []byte(kafka.EventOrderCancelled)}},
+		Value:   []byte(`{"order_uid":"uid-2","reason":"customer"}`),
+	}
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), cancel))
+	assert.False(t, store.orders["uid-2"])
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), cancel), "повторная отмена идемпотентна")
+}
+
+func TestDispatcher_ErrorsArePermanentOrRetried(t *testing.T) {
+	store := newFakeUpdater("uid-1")
+	dlq := &fakeWriter{}
+	d := newDispatcher(store, newMockCache(), kafka.FallbackSkip, dlq)
+
+	// битый JSON и событие для неизвестного заказа повторять бессмысленно
+	bad := kafkago.Message{Topic: "orders", Offset: 7, Value: []byte(`{not json`)}
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), bad))
+	missing := kafkago.Message{Topic: "order-status", Value: []byte(`{"order_uid":"nope","status":1}`)}
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), missing))
+	require.Len(t, dlq.msgs, 2)
+	assert.Contains(t, header(dlq.msgs[0], "dlq-reason"), "decode")
+	assert.Equal(t, "7", header(dlq.msgs[0], "dlq-offset"))
+
+	invalid := kafkago.Message{Topic: "order-status", Value: []byte(`{"order_uid":"uid-1"}`)}
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), invalid))
+	assert.Contains(t, header(dlq.msgs[2], "dlq-reason"), "validate")
+
+	store.failing = errors.New("connection reset")
+	ok := kafkago.Message{Topic: "order-status", Value: []byte(`{"order_uid":"uid-1","status":1}`)}
+	assert.Equal(t, kafka.OutcomeRetry, d.Dispatch(context.Background(), ok))
+	assert.Len(t, dlq.msgs, 3, "временные ошибки не уходят в DLQ")
+}
+
+func TestDispatcher_UnknownTypeFallback(t *testing.T) {
+	unknown := kafkago.Message{
+		Topic:   "order-events",
+		Key:     []byte("uid-1"),
+		Headers: []kafkago.Header{{Key: kafka.EventTypeHeader, Value: []byte("order.refunded")}},
+		Value:   []byte(`{}`),
+	}
+
+	d := newDispatcher(newFakeUpdater(), newMockCache(), kafka.FallbackSkip, nil)
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), unknown))
+
+	d = newDispatcher(newFakeUpdater(), newMockCache(), kafka.FallbackBlock, nil)
+	assert.Equal(t, kafka.OutcomeBlock, d.Dispatch(context.Background(), unknown))
+
+	dlq := &fakeWriter{}
+	d = newDispatcher(newFakeUpdater(), newMockCache(), kafka.FallbackDLQ, dlq)
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), unknown))
+	require.Len(t, dlq.msgs, 1)
+	assert.Equal(t, "uid-1", string(dlq.msgs[0].Key))
+	assert.Equal(t, "order.refunded", header(dlq.msgs[0], kafka.EventTypeHeader), "исходные заголовки сохраняются")
+	assert.Contains(t, header(dlq.msgs[0], "dlq-reason"), `unknown event type "order.refunded"`)
+
+	dlq.err = errors.New("broker down")
+	assert.Equal(t, kafka.OutcomeRetry, d.Dispatch(context.Background(), unknown), "не коммитим, пока не записали в DLQ")
+
+	_, err := kafka.ParseFallbackPolicy("drop")
+	assert.Error(t, err)
+}
+
+type handlerFunc func(ctx context.Context, msg kafkago.Message) error
+
+func (f handlerFunc) Handle(ctx context.Context, msg kafkago.Message) error { return f(ctx, msg) }
+
+// fakeReader отдаёт сообщения по очереди, как reader, который уже ушёл вперёд,
+// а когда они кончаются — ждёт отмены контекста.
+type fakeReader struct {
+	mu        sync.Mutex
+	queue     []kafkago.Message
+	committed []int64
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	r.mu.Lock()
+	if len(r.queue) > 0 {
+		msg := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafkago.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Committed() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.committed...)
+}
+
+func (r *fakeReader) Stats() kafkago.ReaderStats { return kafkago.ReaderStats{} }
+func (r *fakeReader) Close() error               { return nil }
+
+func TestConsumer_RetriesSameMessageBeforeNext(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		handled []int64
+	)
+	reg := kafka.NewRegistry()
+	reg.MapTopic("orders", "order.created")
+	reg.Register("order.created", handlerFunc(func(ctx context.Context, msg kafkago.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, msg.Offset)
+		if len(handled) == 1 {
+			return errors.New("connection reset")
+		}
+		return nil
+	}))
+
+	reader := &fakeReader{queue: []kafkago.Message{{Topic: "orders", Offset: 1}, {Topic: "orders", Offset: 2}}}
+	consumer := kafka.NewConsumerWithReader(kafka.ConsumerConfig{GroupID: "g", Topics: []string{"orders"}},
+		&kafka.Dispatcher{Registry: reg}, func() kafka.MessageReader { return reader })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(reader.Committed()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{1, 1, 2}, handled, "после временной ошибки то же сообщение обрабатывается снова")
+	assert.Equal(t, []int64{1, 2}, reader.Committed(), "ни одно смещение не перепрыгнуто")
+}
+
+// uniqueRepo ведёт себя как Postgres с первичным ключом order_uid.
+type uniqueRepo struct {
+	mockRepo
+	mu     sync.Mutex
+	stored map[string]bool
+}
+
+func (r *uniqueRepo) SaveOrder(ctx context.Context, o models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o.SMID < 0 {
+		return db.ClassifyWriteError(fmt.Errorf("insert order failed: %w", &pgconn.PgError{Code: "23514", Message: "check violation"}))
+	}
+	if r.stored[o.OrderUID] {
+		return db.ClassifyWriteError(fmt.Errorf("insert order failed: %w", &pgconn.PgError{Code: "23505", Message: "duplicate key"}))
+	}
+	r.stored[o.OrderUID] = true
+	return nil
+}
+
+// validSnapshot — заказ, проходящий валидацию по тегам модели.
+func validSnapshot(uid string) *models.Order {
+	o := sampleOrder(uid+strings.Repeat("0", 50-len(uid)), 1)
+	o.TrackNumber = strings.Repeat("T", 50)
+	o.InternalSignature = "sig"
+	o.Payment.RequestID = "req"
+	return o
+}
+
+func TestConsumer_RedeliveredSnapshotIsCommitted(t *testing.T) {
+	repo := &uniqueRepo{stored: map[string]bool{}}
+	c := newMockCache()
+	cfg := &config.Config{KafkaTopic: "orders"}
+	d := &kafka.Dispatcher{Registry: kafka.NewOrderRegistry(cfg, repo, newFakeUpdater(), c)}
+
+	value, err := json.Marshal(validSnapshot("uid-1"))
+	require.NoError(t, err)
+	reader := &fakeReader{queue: []kafkago.Message{
+		{Topic: "orders", Offset: 1, Value: value},
+		{Topic: "orders", Offset: 2, Value: value}, // ретрай продюсера
+	}}
+	consumer := kafka.NewConsumerWithReader(kafka.ConsumerConfig{GroupID: "g", Topics: []string{"orders"}}, d,
+		func() kafka.MessageReader { return reader })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(reader.Committed()) == 2 }, 2*time.Second, 10*time.Millisecond,
+		"повтор снимка не должен стопорить партицию")
+	cancel()
+	<-done
+	assert.Equal(t, []int64{1, 2}, reader.Committed())
+	assert.Len(t, repo.stored, 1)
+
+	// нарушение ограничения повтором не исправить — сообщение уходит в DLQ
+	dlq := &fakeWriter{}
+	d.DLQ = dlq
+	bad := validSnapshot("uid-2")
+	bad.SMID = -1
+	value, err = json.Marshal(bad)
+	require.NoError(t, err)
+	assert.Equal(t, kafka.OutcomeCommit, d.Dispatch(context.Background(), kafkago.Message{Topic: "orders", Value: value}))
+	assert.Len(t, dlq.msgs, 1)
+}
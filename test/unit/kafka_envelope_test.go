@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/kafka"
+)
+
+func TestEnvelope_Parse(t *testing.T) {
+	env, ok, err := kafka.ParseEnvelope([]byte(`{"schema_version":2,"event_id":"e-1","produced_at":"2024-05-01T10:00:00Z","payload":{"order_uid":"uid-1"}}`))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 2, env.SchemaVersion)
+	assert.Equal(t, "e-1", env.EventID)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), env.ProducedAt)
+	assert.JSONEq(t, `{"order_uid":"uid-1"}`, string(env.Payload))
+
+	for _, bare := range []string{`{"order_uid":"uid-1","status":1}`, `{"schema_version":1}`, "\x00\x00\x00\x00\x01", `not json`} {
+		_, ok, err = kafka.ParseEnvelope([]byte(bare))
+		assert.NoError(t, err, bare)
+		assert.False(t, ok, bare)
+	}
+
+	_, _, err = kafka.ParseEnvelope([]byte(`{"schema_version":0,"payload":{}}`))
+	assert.Error(t, err)
+}
+
+// Поток статусов вперемешку: без конверта (v1), v1 и v2 в конверте и v3 из будущего.
+func TestEnvelope_MixedVersionStream(t *testing.T) {
+	store := newFakeUpdater("uid-1", "uid-2", "uid-3", "uid-4")
+	parking := &fakeWriter{}
+	d := newDispatcher(store, newMockCache(), kafka.FallbackSkip, nil)
+	d.Parking = parking
+
+	stream := []struct {
+		value string
+		uid   string
+		chrts []int
+	}{
+		{`{"order_uid":"uid-1","status":301,"chrt_id":11}`, "uid-1", []int{11}},
+		{`{"schema_version":1,"event_id":"e-2","payload":{"order_uid":"uid-2","status":302,"chrt_id":22}}`, "uid-2", []int{22}},
+		{`{"schema_version":2,"event_id":"e-3","payload":{"order_uid":"uid-3","status":303,"chrt_ids":[31,32]}}`, "uid-3", []int{31, 32}},
+		{`{"order_uid":"uid-4","status":304}`, "uid-4", nil},
+	}
+	for i, m := range stream {
+		out := d.Dispatch(context.Background(), kafkago.Message{Topic: "order-status", Offset: int64(i), Value: []byte(m.value)})
+		assert.Equal(t, kafka.OutcomeCommit, out, m.value)
+		assert.Equal(t, 301+i, store.status[m.uid], m.value)
+		assert.Equal(t, m.chrts, store.chrts[m.uid], m.value)
+	}
+
+	future := kafkago.Message{Topic: "order-status", Partition: 1, Offset: 9, Value: []byte(`{"schema_version":3,"payload":{"order_uid":"uid-1","statuses":[{"chrt_id":11,"status":400}]}}`)}
+	out := d.Dispatch(context.Background(), future)
+	assert.Equal(t, kafka.OutcomeCommit, out, "будущая версия отложена, смещение можно коммитить")
+	assert.Equal(t, 301, store.status["uid-1"], "будущая версия не применяется")
+	require.Len(t, parking.msgs, 1)
+	parked := parking.msgs[0]
+	assert.Equal(t, future.Value, parked.Value)
+	assert.Equal(t, kafka.EventOrderStatus, header(parked, kafka.EventTypeHeader), "тип сохранён для повторного чтения")
+	assert.Equal(t, "order-status", header(parked, "parking-topic"))
+	assert.Equal(t, "9", header(parked, "parking-offset"))
+	assert.Contains(t, header(parked, "parking-reason"), "v3")
+}
+
+func TestEnvelope_FutureVersionBlocksWithoutParkingTopic(t *testing.T) {
+	dlq := &fakeWriter{}
+	d := newDispatcher(newFakeUpdater("uid-1"), newMockCache(), kafka.FallbackSkip, dlq)
+
+	out := d.Dispatch(context.Background(), kafkago.Message{Topic: "orders", Value: []byte(`{"schema_version":5,"payload":{}}`)})
+	assert.Equal(t, kafka.OutcomeBlock, out, "не выбрасываем и не отправляем в DLQ")
+	assert.Empty(t, dlq.msgs)
+}
+
+func TestEnvelope_BrokenEnvelopeGoesToDLQ(t *testing.T) {
+	dlq := &fakeWriter{}
+	d := newDispatcher(newFakeUpdater("uid-1"), newMockCache(), kafka.FallbackSkip, dlq)
+
+	out := d.Dispatch(context.Background(), kafkago.Message{Topic: "order-status", Value: []byte(`{"schema_version":1,"payload":"oops"}`)})
+	assert.Equal(t, kafka.OutcomeCommit, out)
+	require.Len(t, dlq.msgs, 1)
+	assert.Contains(t, header(dlq.msgs[0], "dlq-reason"), "upcast v1")
+}
@@ -0,0 +1,261 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/routes"
+)
+
+var resetTime = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// fakeOffsetClient — кластер в памяти: у каждой партиции диапазон [first, last),
+// смещение первого сообщения после resetTime и закоммиченное смещение группы.
+type fakeOffsetClient struct {
+	first, last, atTime map[string][]int64
+	committed           map[string]map[int]int64
+	commits             []*kafkago.OffsetCommitRequest
+}
+
+func newFakeOffsetClient() *fakeOffsetClient {
+	return &fakeOffsetClient{
+		first:     map[string][]int64{"orders": {0, 10}, "order-status": {5}},
+		last:      map[string][]int64{"orders": {100, 50}, "order-status": {5}},
+		atTime:    map[string][]int64{"orders": {60, -1}, "order-status": {-1}},
+		committed: map[string]map[int]int64{"orders": {0: 90, 1: 20}},
+	}
+}
+
+func (f *fakeOffsetClient) Metadata(ctx context.Context, req *kafkago.MetadataRequest) (*kafkago.MetadataResponse, error) {
+	resp := &kafkago.MetadataResponse{}
+	for _, topic := range req.Topics {
+		t := kafkago.Topic{Name: topic}
+		for p := range f.last[topic] {
+			t.Partitions = append(t.Partitions, kafkago.Partition{Topic: topic, ID: p})
+		}
+		resp.Topics = append(resp.Topics, t)
+	}
+	return resp, nil
+}
+
+func (f *fakeOffsetClient) ListOffsets(ctx context.Context, req *kafkago.ListOffsetsRequest) (*kafkago.ListOffsetsResponse, error) {
+	resp := &kafkago.ListOffsetsResponse{Topics: map[string][]kafkago.PartitionOffsets{}}
+	for topic, reqs := range req.Topics {
+		for _, r := range reqs {
+			po := kafkago.PartitionOffsets{Partition: r.Partition, FirstOffset: -1, LastOffset: -1, Offsets: map[int64]time.Time{}}
+			switch r.Timestamp {
+			case kafkago.FirstOffset:
+				po.FirstOffset = f.first[topic][r.Partition]
+			case kafkago.LastOffset:
+				po.LastOffset = f.last[topic][r.Partition]
+			default:
+				po.Offsets[f.atTime[topic][r.Partition]] = time.UnixMilli(r.Timestamp)
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], po)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeOffsetClient) OffsetFetch(ctx context.Context, req *kafkago.OffsetFetchRequest) (*kafkago.OffsetFetchResponse, error) {
+	resp := &kafkago.OffsetFetchResponse{Topics: map[string][]kafkago.OffsetFetchPartition{}}
+	for topic, parts := range req.Topics {
+		for _, p := range parts {
+			off, ok := f.committed[topic][p]
+			if !ok {
+				off = -1
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], kafkago.OffsetFetchPartition{Partition: p, CommittedOffset: off})
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeOffsetClient) OffsetCommit(ctx context.Context, req *kafkago.OffsetCommitRequest) (*kafkago.OffsetCommitResponse, error) {
+	f.commits = append(f.commits, req)
+	resp := &kafkago.OffsetCommitResponse{Topics: map[string][]kafkago.OffsetCommitPartition{}}
+	for topic, parts := range req.Topics {
+		for _, p := range parts {
+			if f.committed[topic] == nil {
+				f.committed[topic] = map[int]int64{}
+			}
+			f.committed[topic][p.Partition] = p.Offset
+			resp.Topics[topic] = append(resp.Topics[topic], kafkago.OffsetCommitPartition{Partition: p.Partition})
+		}
+	}
+	return resp, nil
+}
+
+func newGroupOffsets() (*kafka.GroupOffsets, *fakeOffsetClient) {
+	client := newFakeOffsetClient()
+	return &kafka.GroupOffsets{Client: client, GroupID: "order-service", Topics: []string{"orders", "order-status"}}, client
+}
+
+func TestGroupOffsets_Lag(t *testing.T) {
+	g, _ := newGroupOffsets()
+	lag, err := g.Lag(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []kafka.PartitionLag{
+		{Topic: "order-status", Partition: 0, Committed: -1, Earliest: 5, Latest: 5, Lag: 0},
+		{Topic: "orders", Partition: 0, Committed: 90, Earliest: 0, Latest: 100, Lag: 10},
+		{Topic: "orders", Partition: 1, Committed: 20, Earliest: 10, Latest: 50, Lag: 30},
+	}, lag)
+}
+
+func TestGroupOffsets_Plan(t *testing.T) {
+	g, client := newGroupOffsets()
+	partition := 1
+	cases := []struct {
+		name string
+		spec kafka.ResetSpec
+		want []kafka.OffsetChange
+	}{
+		{"earliest", kafka.ResetSpec{To: kafka.ResetEarliest, Topic: "orders"}, []kafka.OffsetChange{
+			{Topic: "orders", Partition: 0, From: 90, To: 0},
+			{Topic: "orders", Partition: 1, From: 20, To: 10},
+		}},
+		{"latest", kafka.ResetSpec{To: kafka.ResetLatest}, []kafka.OffsetChange{
+			{Topic: "order-status", Partition: 0, From: -1, To: 5},
+			{Topic: "orders", Partition: 0, From: 90, To: 100},
+			{Topic: "orders", Partition: 1, From: 20, To: 50},
+		}},
+		// во второй партиции после resetTime сообщений нет — встаём в конец
+		{"timestamp", kafka.ResetSpec{To: kafka.ResetTimestamp, Timestamp: resetTime, Topic: "orders"}, []kafka.OffsetChange{
+			{Topic: "orders", Partition: 0, From: 90, To: 60},
+			{Topic: "orders", Partition: 1, From: 20, To: 50},
+		}},
+		// смещение за пределами хранимого диапазона прижимается к границе
+		{"offset", kafka.ResetSpec{To: kafka.ResetOffset, Offset: 3, Topic: "orders", Partition: &partition}, []kafka.OffsetChange{
+			{Topic: "orders", Partition: 1, From: 20, To: 10},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := g.Plan(context.Background(), tc.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, plan)
+		})
+	}
+	assert.Empty(t, client.commits, "план ничего не коммитит")
+
+	missing := 7
+	_, err := g.Plan(context.Background(), kafka.ResetSpec{To: kafka.ResetEarliest, Topic: "orders", Partition: &missing})
+	assert.ErrorContains(t, err, "no partition 7")
+	_, err = g.Plan(context.Background(), kafka.ResetSpec{To: "yesterday"})
+	assert.Error(t, err)
+	_, err = g.Plan(context.Background(), kafka.ResetSpec{To: kafka.ResetTimestamp})
+	assert.ErrorContains(t, err, "timestamp is required")
+}
+
+func TestGroupOffsets_CommitOutsideGeneration(t *testing.T) {
+	g, client := newGroupOffsets()
+	plan, err := g.Plan(context.Background(), kafka.ResetSpec{To: kafka.ResetEarliest, Topic: "orders"})
+	require.NoError(t, err)
+	require.NoError(t, g.Commit(context.Background(), plan))
+
+	require.Len(t, client.commits, 1)
+	assert.Equal(t, "order-service", client.commits[0].GroupID)
+	assert.Equal(t, -1, client.commits[0].GenerationID, "коммит без членства в группе")
+	assert.Equal(t, int64(0), client.committed["orders"][0])
+	assert.Equal(t, int64(10), client.committed["orders"][1])
+}
+
+// fakeConsumerControl ведёт себя как kafka.Consumer, но без брокера.
+type fakeConsumerControl struct {
+	*fakeConsumer
+	offsets *kafka.GroupOffsets
+}
+
+func (f *fakeConsumerControl) Status(ctx context.Context) (kafka.ConsumerStatus, error) {
+	lag, err := f.offsets.Lag(ctx)
+	return kafka.ConsumerStatus{GroupID: f.offsets.GroupID, Paused: f.Paused(), Partitions: lag}, err
+}
+
+func (f *fakeConsumerControl) ResetOffsets(ctx context.Context, spec kafka.ResetSpec, dryRun bool) ([]kafka.OffsetChange, error) {
+	plan, err := f.offsets.Plan(ctx, spec)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	if !f.Paused() {
+		return nil, kafka.ErrNotPaused
+	}
+	return plan, f.offsets.Commit(ctx, plan)
+}
+
+func TestKafkaAdmin_Endpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tuner, _, consumer := newTuner(t)
+	offsets, client := newGroupOffsets()
+	r := routes.InitAdminRoutes(gin.New(), tuner, &fakeConsumerControl{consumer, offsets}, nil, nil, "s3cret")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/kafka/consumer", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var st kafka.ConsumerStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+	assert.False(t, st.Paused)
+	assert.Len(t, st.Partitions, 3)
+
+	reset := `{"to":"timestamp","timestamp":"2024-05-01T10:00:00Z","topic":"orders"}`
+	w = do(http.MethodPost, "/admin/kafka/consumer/offsets/reset?dry_run=true", reset)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `[{"topic":"orders","partition":0,"from":90,"to":60},{"topic":"orders","partition":1,"from":20,"to":50}]`,
+		jsonField(t, w.Body.Bytes(), "changes"))
+	assert.Empty(t, client.commits)
+
+	w = do(http.MethodPost, "/admin/kafka/consumer/offsets/reset", reset)
+	assert.Equal(t, http.StatusConflict, w.Code, "без паузы смещения не трогаем")
+	w = do(http.MethodPost, "/admin/kafka/consumer/offsets/reset", `{"to":"offset","offset":-5}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/admin/kafka/consumer/pause", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, consumer.Paused())
+	assert.True(t, tuner.Current().KafkaPaused, "пауза видна и в /admin/runtime")
+
+	w = do(http.MethodPost, "/admin/kafka/consumer/offsets/reset", reset)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, int64(60), client.committed["orders"][0])
+
+	w = do(http.MethodPost, "/admin/kafka/consumer/resume", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, consumer.Paused())
+}
+
+func TestKafkaAdmin_ResumeAfterDispatcherPause(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tuner, _, consumer := newTuner(t)
+	offsets, _ := newGroupOffsets()
+	r := routes.InitAdminRoutes(gin.New(), tuner, &fakeConsumerControl{consumer, offsets}, nil, nil, "s3cret")
+
+	// консьюмер встал на паузу сам, мимо tuner: в конфиге kafka.paused=false
+	consumer.Pause()
+	require.False(t, tuner.Current().KafkaPaused)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/kafka/consumer/resume", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"paused":false}`, w.Body.String())
+	assert.False(t, consumer.Paused(), "resume снимает и паузу, поставленную диспетчером")
+	assert.Equal(t, false, tuner.Settings()["kafka.paused"])
+}
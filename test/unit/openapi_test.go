@@ -0,0 +1,236 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/api"
+	"wb-test-task/internal/feed"
+	"wb-test-task/internal/handlers"
+	"wb-test-task/internal/routes"
+	"wb-test-task/internal/service"
+)
+
+// Спецификация пишется руками; эти тесты сверяют её с маршрутами и с реальными ответами.
+
+func loadSpec(t *testing.T) map[string]any {
+	var spec map[string]any
+	require.NoError(t, json.Unmarshal(api.OpenAPI, &spec))
+	return spec
+}
+
+func publicRouter(svc *service.OrderService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("index.html").Parse(`OK`)))
+	r = routes.InitRoutes(r, svc, "no-cache")
+	r = routes.InitStreamRoutes(r, feed.NewHub(1, 1, 0), time.Second, time.Second)
+	r = routes.InitBatchRoutes(r, svc, 10)
+	return routes.InitExportRoutes(r, &fakeExporter{}, time.Second)
+}
+
+var pathParam = regexp.MustCompile(`:([^/]+)`)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	assert.True(t, strings.HasPrefix(spec["openapi"].(string), "3."))
+
+	var fromSpec []string
+	for path, item := range spec["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			fromSpec = append(fromSpec, strings.ToUpper(method)+" "+path)
+		}
+	}
+	var fromRoutes []string
+	for _, rt := range publicRouter(service.NewOrderService(&noopRepo{}, &noopCache{})).Routes() {
+		if rt.Path == "/" {
+			continue // HTML-страница интерфейса
+		}
+		if prefix, ok := strings.CutSuffix(rt.Path, ":method"); ok {
+			for _, m := range handlers.OrderMethods {
+				fromRoutes = append(fromRoutes, rt.Method+" "+prefix+":"+m)
+			}
+			continue
+		}
+		fromRoutes = append(fromRoutes, rt.Method+" "+pathParam.ReplaceAllString(rt.Path, "{$1}"))
+	}
+	sort.Strings(fromSpec)
+	sort.Strings(fromRoutes)
+	assert.Equal(t, fromRoutes, fromSpec, "api/openapi.json is out of sync with routes")
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	spec := loadSpec(t)
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				assert.NotNil(t, lookupRef(spec, ref), "unresolved %s", ref)
+			}
+			for _, c := range v {
+				walk(c)
+			}
+		case []any:
+			for _, c := range v {
+				walk(c)
+			}
+		}
+	}
+	walk(spec)
+}
+
+func lookupRef(spec map[string]any, ref string) map[string]any {
+	var cur any = spec
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	m, _ := cur.(map[string]any)
+	return m
+}
+
+// checkSchema — минимальная проверка JSON по схеме OpenAPI: типы, required,
+// отсутствие полей, которых нет в схеме, и формат date-time.
+func checkSchema(spec, schema map[string]any, v any, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return checkSchema(spec, lookupRef(spec, ref), v, path)
+	}
+	var problems []string
+	bad := func(format string, args ...any) []string {
+		return append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return bad("want object, got %T", v)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for _, r := range schema["required"].([]any) {
+			if _, ok := obj[r.(string)]; !ok {
+				problems = bad("missing required %q", r)
+			}
+		}
+		for k, fv := range obj {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				problems = bad("field %q is not in the schema", k)
+				continue
+			}
+			problems = append(problems, checkSchema(spec, ps, fv, path+"."+k)...)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return bad("want array, got %T", v)
+		}
+		for i, item := range arr {
+			problems = append(problems, checkSchema(spec, schema["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return bad("want string, got %T", v)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return bad("bad date-time %q", s)
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return bad("want integer, got %v", v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return bad("want number, got %T", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return bad("want boolean, got %T", v)
+		}
+	}
+	return problems
+}
+
+func responseSchema(t *testing.T, spec map[string]any, path, code, contentType string) map[string]any {
+	op := spec["paths"].(map[string]any)[path].(map[string]any)["get"].(map[string]any)
+	resp := op["responses"].(map[string]any)[code].(map[string]any)
+	if ref, ok := resp["$ref"].(string); ok {
+		resp = lookupRef(spec, ref)
+	}
+	schema := resp["content"].(map[string]any)[contentType].(map[string]any)["schema"].(map[string]any)
+	require.NotNil(t, schema)
+	return schema
+}
+
+func TestOpenAPIOrderResponsesConform(t *testing.T) {
+	spec := loadSpec(t)
+	repo := &mockRepo{getOrderRes: sampleOrder("b563feb7b2b84b6test", 2)}
+	r := publicRouter(service.NewOrderService(repo, newMockCache()))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/b563feb7b2b84b6test", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Empty(t, checkSchema(spec, responseSchema(t, spec, "/api/v1/orders/{orderId}", "200", "application/json"), body, "$"))
+
+	repo.getOrderRes, repo.getOrderErr = nil, fmt.Errorf("nope")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/missing", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Empty(t, checkSchema(spec, responseSchema(t, spec, "/api/v1/orders/{orderId}", "404", "application/json"), body, "$"))
+}
+
+func TestOpenAPISchemaCheckCatchesDrift(t *testing.T) {
+	spec := loadSpec(t)
+	delivery := lookupRef(spec, "#/components/schemas/Delivery")
+	problems := checkSchema(spec, delivery, map[string]any{"name": 1.0, "extra": "x"}, "$")
+	assert.Contains(t, problems, "$: field \"extra\" is not in the schema")
+	assert.Contains(t, problems, "$.name: want string, got float64")
+	assert.Contains(t, problems, "$: missing required \"city\"")
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	r := publicRouter(service.NewOrderService(&noopRepo{}, &noopCache{}))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, api.OpenAPI, w.Body.Bytes())
+}
+
+func TestLegacyOrderRouteIsDeprecatedAlias(t *testing.T) {
+	repo := &mockRepo{getOrderRes: sampleOrder("uid-1", 1)}
+	r := publicRouter(service.NewOrderService(repo, newMockCache()))
+
+	legacy := httptest.NewRecorder()
+	r.ServeHTTP(legacy, httptest.NewRequest(http.MethodGet, "/order/uid-1", nil))
+	require.Equal(t, http.StatusOK, legacy.Code)
+	assert.Equal(t, "true", legacy.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/orders/uid-1>; rel="successor-version"`, legacy.Header().Get("Link"))
+
+	current := httptest.NewRecorder()
+	r.ServeHTTP(current, httptest.NewRequest(http.MethodGet, "/api/v1/orders/uid-1", nil))
+	require.Equal(t, http.StatusOK, current.Code)
+	assert.Empty(t, current.Header().Get("Deprecation"))
+	assert.JSONEq(t, current.Body.String(), legacy.Body.String())
+}
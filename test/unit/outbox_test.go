@@ -0,0 +1,238 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/kafka"
+	"wb-test-task/internal/outbox"
+	"wb-test-task/internal/ports"
+)
+
+// fakeOutbox повторяет семантику таблицы outbox: у заказа отправляется только
+// самая ранняя запись, отправленные удаляются, неотправленные ждут retryAt.
+type fakeOutbox struct {
+	mu      sync.Mutex
+	nextID  int64
+	recs    []ports.OutboxRecord
+	due     map[int64]time.Time
+	retries []time.Duration
+}
+
+func newFakeOutbox() *fakeOutbox {
+	return &fakeOutbox{due: map[int64]time.Time{}}
+}
+
+func (f *fakeOutbox) add(uid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	f.recs = append(f.recs, ports.OutboxRecord{
+		ID: f.nextID, EventID: fmt.Sprintf("ev-%d", f.nextID), AggregateID: uid, EventType: "order.stored",
+		Payload: []byte(fmt.Sprintf(`{"order_uid":%q}`, uid)), CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	})
+}
+
+// expireBackoff имитирует, что время повтора уже наступило.
+func (f *fakeOutbox) expireBackoff() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id := range f.due {
+		f.due[id] = time.Time{}
+	}
+}
+
+func (f *fakeOutbox) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.recs)
+}
+
+func (f *fakeOutbox) ProcessOutbox(ctx context.Context, limit int, publish func(context.Context, []ports.OutboxRecord) []error, retryAt func(int) time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var batch []ports.OutboxRecord
+	heads := map[string]bool{}
+	for _, rec := range f.recs {
+		if heads[rec.AggregateID] {
+			continue
+		}
+		heads[rec.AggregateID] = true
+		if f.due[rec.ID].After(time.Now()) || len(batch) == limit {
+			continue
+		}
+		batch = append(batch, rec)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	errs := publish(ctx, batch)
+	sent := map[int64]bool{}
+	for i, rec := range batch {
+		if errs[i] == nil {
+			sent[rec.ID] = true
+			continue
+		}
+		at := retryAt(rec.Attempts + 1)
+		f.retries = append(f.retries, time.Until(at).Round(time.Second))
+		f.due[rec.ID] = at
+		for j := range f.recs {
+			if f.recs[j].ID == rec.ID {
+				f.recs[j].Attempts++
+			}
+		}
+	}
+	kept := f.recs[:0]
+	for _, rec := range f.recs {
+		if !sent[rec.ID] {
+			kept = append(kept, rec)
+		}
+	}
+	f.recs = kept
+	return len(sent), nil
+}
+
+// flakyWriter не принимает сообщения выбранных заказов, пока failing[uid] > 0.
+type flakyWriter struct {
+	mu      sync.Mutex
+	msgs    []kafkago.Message
+	failing map[string]int
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	werrs := make(kafkago.WriteErrors, len(msgs))
+	failed := false
+	for i, m := range msgs {
+		if w.failing[string(m.Key)] > 0 {
+			w.failing[string(m.Key)]--
+			werrs[i] = errors.New("leader not available")
+			failed = true
+			continue
+		}
+		w.msgs = append(w.msgs, m)
+	}
+	if failed {
+		return werrs
+	}
+	return nil
+}
+
+func (w *flakyWriter) keys() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var keys []string
+	for _, m := range w.msgs {
+		keys = append(keys, string(m.Key))
+	}
+	return keys
+}
+
+func TestOutboxRelay_PublishesEnvelopeAndCleansUp(t *testing.T) {
+	store := newFakeOutbox()
+	store.add("uid-1")
+	store.add("uid-2")
+	w := &flakyWriter{}
+	relay := outbox.NewRelay(store, w, outbox.Config{Topic: "orders-stored"})
+
+	n, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Zero(t, store.len(), "отправленные записи удалены")
+
+	require.Len(t, w.msgs, 2)
+	msg := w.msgs[0]
+	assert.Equal(t, "uid-1", string(msg.Key), "ключ — UID заказа, порядок внутри партиции")
+	assert.Equal(t, "order.stored", header(msg, kafka.EventTypeHeader))
+	assert.Equal(t, "ev-1", header(msg, kafka.IdempotencyKeyHeader))
+
+	env, ok, err := kafka.ParseEnvelope(msg.Value)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1, env.SchemaVersion)
+	assert.Equal(t, "ev-1", env.EventID)
+	assert.JSONEq(t, `{"order_uid":"uid-1"}`, string(env.Payload))
+}
+
+func TestOutboxRelay_RetriesInOrderPerOrder(t *testing.T) {
+	store := newFakeOutbox()
+	store.add("uid-1") // ev-1
+	store.add("uid-2") // ev-2
+	store.add("uid-1") // ev-3: должно уйти только после ev-1
+	w := &flakyWriter{failing: map[string]int{"uid-1": 2}}
+	relay := outbox.NewRelay(store, w, outbox.Config{MinBackoff: time.Second, MaxBackoff: 3 * time.Second})
+
+	n, err := relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"uid-2"}, w.keys())
+
+	// повтор ещё не наступил — ничего не отправляется
+	n, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	store.expireBackoff()
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uid-2"}, w.keys(), "вторая неудача")
+
+	store.expireBackoff()
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+	_, err = relay.Flush(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"uid-2", "uid-1", "uid-1"}, w.keys())
+	assert.Equal(t, "ev-1", header(w.msgs[1], kafka.IdempotencyKeyHeader))
+	assert.Equal(t, "ev-3", header(w.msgs[2], kafka.IdempotencyKeyHeader))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, store.retries, "экспоненциальная задержка")
+	assert.Zero(t, store.len())
+}
+
+func TestOutboxRelay_BackoffIsCapped(t *testing.T) {
+	store := newFakeOutbox()
+	store.add("uid-1")
+	w := &flakyWriter{failing: map[string]int{"uid-1": 4}}
+	relay := outbox.NewRelay(store, w, outbox.Config{MinBackoff: time.Second, MaxBackoff: 3 * time.Second})
+
+	for i := 0; i < 5; i++ {
+		store.expireBackoff()
+		_, err := relay.Flush(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}, store.retries)
+	assert.Equal(t, []string{"uid-1"}, w.keys())
+}
+
+func TestOutboxRelay_RunDrainsUntilCancelled(t *testing.T) {
+	store := newFakeOutbox()
+	for i := 0; i < 5; i++ {
+		store.add(fmt.Sprintf("uid-%d", i))
+	}
+	w := &flakyWriter{}
+	relay := outbox.NewRelay(store, w, outbox.Config{BatchSize: 2, PollInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return store.len() == 0 }, time.Second, 5*time.Millisecond)
+	store.add("uid-late")
+	assert.Eventually(t, func() bool { return len(w.keys()) == 6 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/peers"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/routes"
+	"wb-test-task/internal/service"
+)
+
+// countingRepo — локальный «Postgres» реплики: знает все заказы и считает обращения.
+type countingRepo struct {
+	mu    sync.Mutex
+	calls map[string]int
+	known map[string]bool
+}
+
+func newCountingRepo(uids ...string) *countingRepo {
+	r := &countingRepo{calls: map[string]int{}, known: map[string]bool{}}
+	for _, uid := range uids {
+		r.known[uid] = true
+	}
+	return r
+}
+
+func (r *countingRepo) SaveOrder(ctx context.Context, o models.Order) error { return nil }
+
+func (r *countingRepo) GetOrder(ctx context.Context, uid string) (*models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[uid]++
+	if !r.known[uid] {
+		return nil, fmt.Errorf("select order %s: %w", uid, ports.ErrOrderNotFound)
+	}
+	return sampleOrder(uid, 1), nil
+}
+
+func (r *countingRepo) count(uid string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[uid]
+}
+
+type peerInstance struct {
+	url  string
+	srv  *httptest.Server
+	repo *countingRepo
+	svc  *service.OrderService
+}
+
+// startPeers поднимает n реплик в процессе, каждая со своим кэшем и «БД».
+func startPeers(t *testing.T, n int, uids ...string) []*peerInstance {
+	gin.SetMode(gin.TestMode)
+	inst := make([]*peerInstance, n)
+	handlers := make([]http.Handler, n)
+	var urls []string
+	for i := range inst {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		inst[i] = &peerInstance{url: srv.URL, srv: srv, repo: newCountingRepo(uids...)}
+		urls = append(urls, srv.URL)
+	}
+	for i, p := range inst {
+		c := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+		p.svc = service.NewOrderService(peers.NewRepository(p.repo, p.url, urls, time.Second), c)
+		handlers[i] = routes.InitPeerRoutes(gin.New(), service.NewOrderService(p.repo, c))
+	}
+	return inst
+}
+
+func ownerIndex(inst []*peerInstance, uid string) int {
+	var urls []string
+	for _, p := range inst {
+		urls = append(urls, p.url)
+	}
+	owner := peers.NewRing(0, urls...).Owner(uid)
+	for i, p := range inst {
+		if p.url == owner {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRing_StableAndBalanced(t *testing.T) {
+	r := peers.NewRing(0, "a", "b", "c")
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		counts[r.Owner(fmt.Sprintf("order-%d", i))]++
+	}
+	for _, n := range counts {
+		assert.Greater(t, n, 500)
+	}
+
+	// добавление узла не должно переносить ключи между старыми узлами
+	r2 := peers.NewRing(0, "a", "b", "c", "d")
+	for i := 0; i < 3000; i++ {
+		k := fmt.Sprintf("order-%d", i)
+		if o := r2.Owner(k); o != "d" {
+			assert.Equal(t, r.Owner(k), o)
+		}
+	}
+	assert.Equal(t, "", peers.NewRing(0).Owner("x"))
+}
+
+func TestPeers_MissGoesToOwner(t *testing.T) {
+	inst := startPeers(t, 3, "uid-1")
+	owner := ownerIndex(inst, "uid-1")
+	asker := (owner + 1) % len(inst)
+
+	got, err := inst[asker].svc.GetOrderByUID(context.Background(), "uid-1")
+	require.NoError(t, err)
+	assert.Equal(t, "uid-1", got.OrderUID)
+	assert.Equal(t, 0, inst[asker].repo.count("uid-1"), "не владелец не ходит в свою БД")
+	assert.Equal(t, 1, inst[owner].repo.count("uid-1"))
+
+	// вторая реплика получает заказ из кэша владельца без похода в БД
+	other := (owner + 2) % len(inst)
+	_, err = inst[other].svc.GetOrderByUID(context.Background(), "uid-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, inst[owner].repo.count("uid-1"))
+}
+
+func TestPeers_NotFoundIsNotRetriedLocally(t *testing.T) {
+	inst := startPeers(t, 2)
+	owner := ownerIndex(inst, "missing")
+	asker := 1 - owner
+
+	_, err := inst[asker].svc.GetOrderByUID(context.Background(), "missing")
+	assert.ErrorIs(t, err, ports.ErrOrderNotFound)
+	assert.Equal(t, 0, inst[asker].repo.count("missing"))
+}
+
+func TestPeers_OwnerDownFallsBackToLocal(t *testing.T) {
+	inst := startPeers(t, 2, "uid-1")
+	owner := ownerIndex(inst, "uid-1")
+	asker := 1 - owner
+	inst[owner].srv.Close()
+
+	got, err := inst[asker].svc.GetOrderByUID(context.Background(), "uid-1")
+	require.NoError(t, err)
+	assert.Equal(t, "uid-1", got.OrderUID)
+	assert.Equal(t, 1, inst[asker].repo.count("uid-1"))
+}
+
+func TestPeers_CancelledCallerDoesNotAbortSharedFetch(t *testing.T) {
+	arrived, release := make(chan struct{}, 1), make(chan struct{})
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		arrived <- struct{}{}
+		<-release
+		_ = json.NewEncoder(w).Encode(sampleOrder("uid-1", 1))
+	}))
+	defer srv.Close()
+
+	local := newCountingRepo("uid-1")
+	repo := peers.NewRepository(local, "http://self.invalid", []string{srv.URL}, 5*time.Second)
+	uid := ""
+	for i := 0; uid == ""; i++ {
+		if k := fmt.Sprintf("uid-%d", i); peers.NewRing(0, "http://self.invalid", srv.URL).Owner(k) == srv.URL {
+			uid = k
+		}
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := repo.GetOrder(first, uid)
+		firstErr <- err
+	}()
+	<-arrived
+
+	type result struct {
+		order *models.Order
+		err   error
+	}
+	second := make(chan result, 1)
+	go func() {
+		o, err := repo.GetOrder(context.Background(), uid)
+		second <- result{o, err}
+	}()
+
+	cancelFirst()
+	select {
+	case err := <-firstErr:
+		assert.ErrorIs(t, err, context.Canceled, "отменивший уходит сразу")
+	case <-time.After(time.Second):
+		t.Fatal("cancelled caller still waits for the shared fetch")
+	}
+
+	close(release)
+	res := <-second
+	require.NoError(t, res.err, "отмена первого не обрывает запрос для второго")
+	assert.Equal(t, "uid-1", res.order.OrderUID)
+	assert.Equal(t, 1, hits, "один запрос к владельцу на обоих")
+	assert.Zero(t, local.count(uid), "без отката на локальную БД")
+}
@@ -0,0 +1,324 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/bootstrap"
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/rediscache"
+)
+
+// fakeRedis — RESP-сервер в процессе: GET/SET [PX] [NX]/DEL/PUBLISH/SUBSCRIBE.
+type fakeRedis struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	data      map[string]string
+	exp       map[string]time.Time
+	subs      map[string][]*fakeRedisConn
+	sets      int
+	published []string
+}
+
+type fakeRedisConn struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+func (c *fakeRedisConn) send(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.w.WriteString(s)
+	c.w.Flush()
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{ln: ln, data: map[string]string{}, exp: map[string]time.Time{}, subs: map[string][]*fakeRedisConn{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(nc)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func (f *fakeRedis) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	c := &fakeRedisConn{w: bufio.NewWriter(nc)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		c.send(f.exec(c, args))
+	}
+}
+
+func (f *fakeRedis) exec(c *fakeRedisConn, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING", "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.data[args[1]]
+		if exp, has := f.exp[args[1]]; has && time.Now().After(exp) {
+			ok = false
+		}
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		var px time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "PX":
+				i++
+				ms, _ := strconv.Atoi(args[i])
+				px = time.Duration(ms) * time.Millisecond
+			case "NX":
+				nx = true
+			}
+		}
+		if _, exists := f.data[args[1]]; exists && nx {
+			return "$-1\r\n"
+		}
+		f.sets++
+		f.data[args[1]] = args[2]
+		delete(f.exp, args[1])
+		if px > 0 {
+			f.exp[args[1]] = time.Now().Add(px)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := f.data[args[1]]
+		delete(f.data, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PUBLISH":
+		f.published = append(f.published, args[2])
+		subs := f.subs[args[1]]
+		for _, s := range subs {
+			go s.send("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+		}
+		return ":" + strconv.Itoa(len(subs)) + "\r\n"
+	case "SUBSCRIBE":
+		f.subs[args[1]] = append(f.subs[args[1]], c)
+		return "*3\r\n" + bulk("subscribe") + bulk(args[1]) + ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		hdr, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCodec_RoundTrip(t *testing.T) {
+	o := sampleOrder("uid-1", 3)
+	got, err := rediscache.DecodeOrder(rediscache.EncodeOrder(o))
+	require.NoError(t, err)
+	assert.Equal(t, o, got)
+
+	raw := rediscache.EncodeOrder(o)
+	_, err = rediscache.DecodeOrder(raw[:len(raw)-3])
+	assert.Error(t, err)
+
+	// UpdatedAt нужен для Last-Modified, поэтому тоже переживает кодирование
+	o.UpdatedAt = time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	got, err = rediscache.DecodeOrder(rediscache.EncodeOrder(o))
+	require.NoError(t, err)
+	assert.True(t, o.UpdatedAt.Equal(got.UpdatedAt), "updated_at: %v", got.UpdatedAt)
+	assert.Equal(t, o, got)
+}
+
+func TestRedisCache_SetGetDelete(t *testing.T) {
+	srv := newFakeRedis(t)
+	client := rediscache.NewClient(rediscache.Options{Addr: srv.addr(), Password: "secret", DB: 1})
+	defer client.Close()
+	c := rediscache.New(client, "order:", time.Minute)
+
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	got, ok := c.Get("uid-1")
+	require.True(t, ok)
+	assert.Equal(t, "uid-1", got.OrderUID)
+
+	c.Delete("uid-1")
+	_, ok = c.Get("uid-1")
+	assert.False(t, ok)
+}
+
+func TestRedisCache_UnavailableIsMiss(t *testing.T) {
+	client := rediscache.NewClient(rediscache.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond})
+	c := rediscache.New(client, "order:", time.Minute)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	_, ok := c.Get("uid-1")
+	assert.False(t, ok)
+}
+
+func newTieredInstance(t *testing.T, ctx context.Context, addr, name string) *rediscache.Tiered {
+	client := rediscache.NewClient(rediscache.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	l1 := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	tc := rediscache.NewTiered(l1, rediscache.New(client, "order:", time.Minute), client, "orders:invalidate", name)
+	go tc.Listen(ctx)
+	return tc
+}
+
+func TestTiered_SharesL2AndPropagatesInvalidation(t *testing.T) {
+	srv := newFakeRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := newTieredInstance(t, ctx, srv.addr(), "a")
+	b := newTieredInstance(t, ctx, srv.addr(), "b")
+	require.Eventually(t, func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return len(srv.subs["orders:invalidate"]) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	// b получает заказ из общего L2 и кладёт себе в L1
+	a.Set("uid-1", sampleOrder("uid-1", 1))
+	got, ok := b.Get("uid-1")
+	require.True(t, ok)
+	assert.Len(t, got.Items, 1)
+
+	// a обновляет заказ — b должен сбросить устаревшую L1-копию
+	a.Set("uid-1", sampleOrder("uid-1", 2))
+	require.Eventually(t, func() bool {
+		o, ok := b.Get("uid-1")
+		return ok && len(o.Items) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	a.Delete("uid-1")
+	require.Eventually(t, func() bool {
+		_, ok := b.Get("uid-1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestTiered_EncodedPassthrough(t *testing.T) {
+	srv := newFakeRedis(t)
+	client := rediscache.NewClient(rediscache.Options{Addr: srv.addr()})
+	defer client.Close()
+
+	l2 := rediscache.New(client, "order:", time.Minute)
+	l2.Set("uid-1", sampleOrder("uid-1", 1))
+
+	tc := rediscache.NewTiered(newEncodedCache(t, false), l2, client, "orders:invalidate", "x")
+	e, ok := tc.GetEncoded("uid-1")
+	require.True(t, ok)
+	assert.Contains(t, string(e.JSON), `"order_uid":"uid-1"`)
+}
+
+func (f *fakeRedis) counts() (sets, published int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sets, len(f.published)
+}
+
+// Заполнение из БД и повтор того же значения не должны рассылать инвалидации.
+func TestTiered_PublishesOnlyRealChanges(t *testing.T) {
+	srv := newFakeRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := newTieredInstance(t, ctx, srv.addr(), "a")
+	b := newTieredInstance(t, ctx, srv.addr(), "b")
+
+	// прогрев/промах: L1 и пустой L2 заполняются, но без PUBLISH
+	a.Fill("uid-1", sampleOrder("uid-1", 1))
+	sets, published := srv.counts()
+	assert.Equal(t, 1, sets)
+	assert.Zero(t, published)
+	got, ok := b.Get("uid-1")
+	require.True(t, ok, "заполненное значение доступно другим репликам через L2")
+	assert.Len(t, got.Items, 1)
+
+	// повтор события с тем же содержимым: L2 уже его хранит
+	a.Set("uid-1", sampleOrder("uid-1", 1))
+	sets, published = srv.counts()
+	assert.Equal(t, 1, sets)
+	assert.Zero(t, published)
+
+	// настоящее изменение пишется и публикуется
+	a.Set("uid-1", sampleOrder("uid-1", 2))
+	sets, published = srv.counts()
+	assert.Equal(t, 2, sets)
+	assert.Equal(t, 1, published)
+
+	// устаревшая копия из БД не затирает свежую запись в L2
+	b.Fill("uid-1", sampleOrder("uid-1", 1))
+	c := newTieredInstance(t, ctx, srv.addr(), "c")
+	got, ok = c.Get("uid-1")
+	require.True(t, ok)
+	assert.Len(t, got.Items, 2)
+	_, published = srv.counts()
+	assert.Equal(t, 1, published)
+
+	a.Delete("uid-1")
+	_, published = srv.counts()
+	assert.Equal(t, 2, published)
+}
+
+func TestWarmup_ThroughTieredDoesNotPublish(t *testing.T) {
+	srv := newFakeRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := newTieredInstance(t, ctx, srv.addr(), "a")
+
+	w := bootstrap.NewWarmer(newFakeWarmupSource(), tc, bootstrap.WarmupConfig{Strategy: bootstrap.WarmupAll, Concurrency: 2})
+	w.Run(ctx)
+	require.True(t, w.Ready())
+	sets, published := srv.counts()
+	assert.Positive(t, sets)
+	assert.Zero(t, published, "прогрев не рассылает инвалидации")
+}
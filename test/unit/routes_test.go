@@ -46,7 +46,7 @@ func TestRoutes_IndexServed(t *testing.T) {
 	r.SetHTMLTemplate(tmpl)
 
 	svc := service.NewOrderService(&noopRepo{}, &noopCache{})
-	r = routes.InitRoutes(r, svc)
+	r = routes.InitRoutes(r, svc, "no-cache")
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	w := httptest.NewRecorder()
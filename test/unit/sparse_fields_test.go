@@ -0,0 +1,209 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/handlers"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/rediscache"
+	"wb-test-task/internal/service"
+)
+
+// partialRepo отдаёт только запрошенные части заказа, как db.Repository.
+type partialRepo struct {
+	mockRepo
+	parts []ports.OrderParts
+}
+
+func (p *partialRepo) GetOrderParts(ctx context.Context, uid string, parts ports.OrderParts) (*models.Order, error) {
+	p.parts = append(p.parts, parts)
+	if p.getOrderErr != nil {
+		return nil, p.getOrderErr
+	}
+	o := *p.getOrderRes
+	if !parts.Delivery {
+		o.Delivery = models.Delivery{}
+	}
+	if !parts.Payment {
+		o.Payment = models.Payment{}
+	}
+	if !parts.Items {
+		o.Items = nil
+	}
+	return &o, nil
+}
+
+func sparseRouter(svc *service.OrderService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/order/:orderId", handlers.NewOrderHandler(svc, "").GetOrderByUID)
+	return r
+}
+
+// plainTiered — Redis поверх обычного L1 (CACHE_ENCODED=false): GetEncoded у него всегда промах.
+func plainTiered(t *testing.T) *rediscache.Tiered {
+	srv := newFakeRedis(t)
+	client := rediscache.NewClient(rediscache.Options{Addr: srv.addr()})
+	t.Cleanup(func() { client.Close() })
+	l1 := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	return rediscache.NewTiered(l1, rediscache.New(client, "order:", time.Minute), client, "orders:invalidate", "x")
+}
+
+func decodeBody(t *testing.T, body []byte) map[string]any {
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func TestSparseFields_ProjectsPaths(t *testing.T) {
+	c := newEncodedCache(t, true)
+	c.Set("uid-1", sampleOrder("uid-1", 2))
+	r := sparseRouter(service.NewOrderService(&mockRepo{}, c))
+
+	w := getOrder(r, "uid-1?fields=order_uid,payment.amount,delivery.city,items.chrt_id", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{
+		"order_uid": "uid-1",
+		"payment": {"amount": 1817},
+		"delivery": {"city": "Kiryat Mozkin"},
+		"items": [{"chrt_id": 9934930}, {"chrt_id": 9934931}]
+	}`, w.Body.String())
+
+	full := getOrder(r, "uid-1", nil)
+	assert.NotEqual(t, full.Header().Get("ETag"), w.Header().Get("ETag"), "у урезанного ответа свой ETag")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+
+	w = getOrder(r, "uid-1?fields=order_uid,payment.amount", map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusOK, w.Code, "другая выборка — другое представление")
+}
+
+// objectKeys возвращает ключи JSON-объекта в порядке следования.
+func objectKeys(t *testing.T, raw []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	_, err := dec.Token()
+	require.NoError(t, err)
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		require.NoError(t, err)
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		require.NoError(t, dec.Decode(&skip))
+	}
+	return keys
+}
+
+// Урезанный ответ сохраняет порядок ключей полного, а не сортирует их.
+func TestSparseFields_KeepsKeyOrder(t *testing.T) {
+	c := newEncodedCache(t, false)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	r := sparseRouter(service.NewOrderService(&mockRepo{}, c))
+
+	fullBody := getOrder(r, "uid-1", nil).Body.Bytes()
+	w := getOrder(r, "uid-1?fields=sm_id,payment.provider,payment.amount,entry,order_uid,locale", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var want []string
+	for _, k := range objectKeys(t, fullBody) {
+		if slices.Contains([]string{"sm_id", "payment", "entry", "order_uid", "locale"}, k) {
+			want = append(want, k)
+		}
+	}
+	assert.Equal(t, want, objectKeys(t, w.Body.Bytes()))
+
+	var full, got struct{ Payment json.RawMessage }
+	require.NoError(t, json.Unmarshal(fullBody, &full))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	want = nil
+	for _, k := range objectKeys(t, full.Payment) {
+		if k == "amount" || k == "provider" {
+			want = append(want, k)
+		}
+	}
+	assert.Equal(t, want, objectKeys(t, got.Payment))
+}
+
+func TestSparseFields_IncludeAddsEntities(t *testing.T) {
+	c := newEncodedCache(t, false)
+	c.Set("uid-1", sampleOrder("uid-1", 1))
+	r := sparseRouter(service.NewOrderService(&mockRepo{}, c))
+
+	w := getOrder(r, "uid-1?include=payment", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	m := decodeBody(t, w.Body.Bytes())
+	assert.Equal(t, "uid-1", m["order_uid"])
+	assert.Equal(t, "WBILMTESTTRACK", m["track_number"], "поля заголовка остаются")
+	assert.Contains(t, m, "payment")
+	assert.NotContains(t, m, "delivery")
+	assert.NotContains(t, m, "items")
+
+	w = getOrder(r, "uid-1?fields=order_uid,delivery.city&include=items,delivery", nil)
+	m = decodeBody(t, w.Body.Bytes())
+	assert.Len(t, m, 3)
+	assert.Len(t, m["delivery"], 7, "include важнее урезанного пути")
+	assert.Len(t, m["items"], 1)
+}
+
+func TestSparseFields_RejectsUnknown(t *testing.T) {
+	r := sparseRouter(service.NewOrderService(&mockRepo{}, newEncodedCache(t, false)))
+
+	for _, q := range []string{"fields=payment.nope", "fields=nope", "fields=order_uid.x", "include=locale", "include=nope"} {
+		w := getOrder(r, "uid-1?"+q, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, q)
+		assert.Contains(t, w.Body.String(), "unknown", q)
+	}
+}
+
+func TestSparseFields_MissSkipsUnrequestedParts(t *testing.T) {
+	c := newEncodedCache(t, false)
+	repo := &partialRepo{mockRepo: mockRepo{getOrderRes: sampleOrder("uid-1", 3)}}
+	svc := service.NewOrderService(repo, c)
+	r := sparseRouter(svc)
+
+	w := getOrder(r, "uid-1?fields=order_uid,payment.amount,delivery.city", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"order_uid":"uid-1","payment":{"amount":1817},"delivery":{"city":"Kiryat Mozkin"}}`, w.Body.String())
+	assert.Equal(t, []ports.OrderParts{{Delivery: true, Payment: true}}, repo.parts)
+	_, cached := c.Get("uid-1")
+	assert.False(t, cached, "неполный заказ в кэш не попадает")
+
+	w = getOrder(r, "uid-1?fields=sm_id", nil)
+	assert.JSONEq(t, `{"sm_id":99}`, w.Body.String())
+	assert.Equal(t, ports.OrderParts{}, repo.parts[1], "только заголовок")
+
+	// всё сразу — обычный путь через GetOrder с кэшированием
+	getOrder(r, "uid-1?include=items,delivery,payment", nil)
+	assert.Len(t, repo.parts, 2)
+	assert.Equal(t, 1, repo.callsGet)
+	_, cached = c.Get("uid-1")
+	assert.True(t, cached)
+
+	w = getOrder(r, "uid-1?fields=items.rid", nil)
+	assert.Len(t, decodeBody(t, w.Body.Bytes())["items"], 3, "после кэширования — из кэша")
+	assert.Len(t, repo.parts, 2)
+}
+
+func TestSparseFields_CacheWithoutEncodedL1(t *testing.T) {
+	c := plainTiered(t)
+	c.Set("uid-1", sampleOrder("uid-1", 2))
+	repo := &partialRepo{mockRepo: mockRepo{getOrderErr: context.Canceled}}
+	r := sparseRouter(service.NewOrderService(repo, c))
+
+	w := getOrder(r, "uid-1?fields=order_uid,payment.amount", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"order_uid":"uid-1","payment":{"amount":1817}}`, w.Body.String())
+	assert.Empty(t, repo.parts, "заказ из кэша, без похода в БД")
+	assert.Zero(t, repo.callsGet)
+}
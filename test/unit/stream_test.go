@@ -0,0 +1,235 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/feed"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/routes"
+)
+
+func summary(uid, service, locale string) models.OrderSummary {
+	return models.OrderSummary{OrderUID: uid, CustomerID: "c-" + uid, DeliveryService: service, Locale: locale}
+}
+
+func eventIDs(evs []feed.Event) []uint64 {
+	var ids []uint64
+	for _, ev := range evs {
+		ids = append(ids, ev.ID)
+	}
+	return ids
+}
+
+func TestFeedHubReplayFromRing(t *testing.T) {
+	hub := feed.NewHub(3, 8, 0)
+	for i := 1; i <= 5; i++ {
+		hub.Publish(summary(fmt.Sprint(i), "meest", "en"))
+	}
+
+	sub, replay, gap, err := hub.Subscribe(feed.Filter{}, 3, true)
+	require.NoError(t, err)
+	defer hub.Unsubscribe(sub)
+	assert.False(t, gap)
+	assert.Equal(t, []uint64{4, 5}, eventIDs(replay))
+
+	// 1 уже вытеснено из кольца (в нём 3, 4, 5)
+	_, replay, gap, err = hub.Subscribe(feed.Filter{}, 1, true)
+	require.NoError(t, err)
+	assert.True(t, gap)
+	assert.Equal(t, []uint64{3, 4, 5}, eventIDs(replay))
+
+	// ID из прошлой жизни сервиса
+	_, replay, gap, err = hub.Subscribe(feed.Filter{}, 42, true)
+	require.NoError(t, err)
+	assert.True(t, gap)
+	assert.Len(t, replay, 3)
+
+	_, replay, gap, err = hub.Subscribe(feed.Filter{}, 5, true)
+	require.NoError(t, err)
+	assert.False(t, gap)
+	assert.Empty(t, replay)
+}
+
+func TestFeedHubFilter(t *testing.T) {
+	hub := feed.NewHub(10, 8, 0)
+	hub.Publish(summary("1", "meest", "en"))
+	hub.Publish(summary("2", "dhl", "ru"))
+
+	filter := feed.Filter{DeliveryServices: []string{"dhl", "ups"}}
+	sub, replay, _, err := hub.Subscribe(filter, 0, true)
+	require.NoError(t, err)
+	defer hub.Unsubscribe(sub)
+	require.Len(t, replay, 1)
+	assert.Equal(t, "2", replay[0].Summary.OrderUID)
+
+	hub.Publish(summary("3", "meest", "ru"))
+	hub.Publish(summary("4", "ups", "en"))
+	ev := <-sub.C
+	assert.Equal(t, "4", ev.Summary.OrderUID)
+
+	assert.True(t, feed.Filter{CustomerIDs: []string{"c-1"}, Locales: []string{"en"}}.Match(summary("1", "x", "en")))
+	assert.False(t, feed.Filter{CustomerIDs: []string{"c-1"}, Locales: []string{"ru"}}.Match(summary("1", "x", "en")))
+}
+
+func TestFeedHubDropsSlowSubscriber(t *testing.T) {
+	hub := feed.NewHub(10, 2, 0)
+	slow, _, _, err := hub.Subscribe(feed.Filter{}, 0, false)
+	require.NoError(t, err)
+	fast, _, _, err := hub.Subscribe(feed.Filter{}, 0, false)
+	require.NoError(t, err)
+	defer hub.Unsubscribe(fast)
+
+	for i := 1; i <= 5; i++ {
+		hub.Publish(summary(fmt.Sprint(i), "meest", "en")) // Publish не блокируется
+		<-fast.C
+	}
+
+	var got []uint64
+	for ev := range slow.C {
+		got = append(got, ev.ID)
+	}
+	assert.Equal(t, []uint64{1, 2}, got, "buffered events are delivered before close")
+	assert.True(t, slow.Lagged())
+	assert.False(t, fast.Lagged())
+	assert.Equal(t, 1, hub.Clients())
+
+	// переподключение с последнего ID догружает остальное из кольца
+	again, replay, gap, err := hub.Subscribe(feed.Filter{}, 2, true)
+	require.NoError(t, err)
+	defer hub.Unsubscribe(again)
+	assert.False(t, gap)
+	assert.Equal(t, []uint64{3, 4, 5}, eventIDs(replay))
+}
+
+func TestFeedHubMaxClients(t *testing.T) {
+	hub := feed.NewHub(10, 2, 1)
+	sub, _, _, err := hub.Subscribe(feed.Filter{}, 0, false)
+	require.NoError(t, err)
+	_, _, _, err = hub.Subscribe(feed.Filter{}, 0, false)
+	assert.ErrorIs(t, err, feed.ErrTooManyClients)
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(sub) // повторный вызов безопасен
+	_, _, _, err = hub.Subscribe(feed.Filter{}, 0, false)
+	assert.NoError(t, err)
+}
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSE читает события из потока, пропуская комментарии и retry.
+func readSSE(sc *bufio.Scanner, n int) []sseEvent {
+	var out []sseEvent
+	var cur sseEvent
+	for len(out) < n && sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if cur.event != "" {
+				out = append(out, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			cur.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			cur.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return out
+}
+
+func streamServer(t *testing.T, hub *feed.Hub) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	srv := httptest.NewServer(routes.InitStreamRoutes(gin.New(), hub, 20*time.Millisecond, time.Second))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func openStream(t *testing.T, url, lastID string) (*http.Response, *bufio.Scanner) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewScanner(resp.Body)
+}
+
+func TestOrderStreamSSE(t *testing.T) {
+	hub := feed.NewHub(10, 8, 0)
+	srv := streamServer(t, hub)
+	hub.Publish(summary("old", "dhl", "en"))
+
+	resp, sc := openStream(t, srv.URL+"/api/v1/orders/stream?delivery_service=dhl,ups&locale=en", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	require.Eventually(t, func() bool { return hub.Clients() == 1 }, time.Second, 5*time.Millisecond)
+	hub.Publish(summary("skip", "meest", "en"))
+	hub.Publish(summary("new", "ups", "en"))
+
+	evs := readSSE(sc, 1)
+	require.Len(t, evs, 1, "without Last-Event-ID only new orders are sent")
+	assert.Equal(t, "order", evs[0].event)
+	assert.Equal(t, "3", evs[0].id)
+	var got models.OrderSummary
+	require.NoError(t, json.Unmarshal([]byte(evs[0].data), &got))
+	assert.Equal(t, "new", got.OrderUID)
+}
+
+func TestOrderStreamResumesFromLastEventID(t *testing.T) {
+	hub := feed.NewHub(3, 8, 0)
+	srv := streamServer(t, hub)
+	for i := 1; i <= 4; i++ {
+		hub.Publish(summary(fmt.Sprint(i), "dhl", "en"))
+	}
+
+	_, sc := openStream(t, srv.URL+"/api/v1/orders/stream", "2")
+	evs := readSSE(sc, 2)
+	require.Len(t, evs, 2)
+	assert.Equal(t, []string{"3", "4"}, []string{evs[0].id, evs[1].id})
+
+	// 1 вытеснено из кольца — клиент узнаёт о пропуске
+	_, sc = openStream(t, srv.URL+"/api/v1/orders/stream", "0")
+	evs = readSSE(sc, 4)
+	require.Len(t, evs, 4)
+	assert.Equal(t, "gap", evs[0].event)
+	assert.Equal(t, "2", evs[1].id)
+}
+
+func TestOrderStreamErrors(t *testing.T) {
+	hub := feed.NewHub(3, 8, 1)
+	srv := streamServer(t, hub)
+
+	resp, err := http.Get(srv.URL + "/api/v1/orders/stream?last_event_id=abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	openStream(t, srv.URL+"/api/v1/orders/stream", "")
+	require.Eventually(t, func() bool { return hub.Clients() == 1 }, time.Second, 5*time.Millisecond)
+	resp, err = http.Get(srv.URL + "/api/v1/orders/stream")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+}
@@ -0,0 +1,213 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/config"
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/logging"
+	"wb-test-task/internal/routes"
+	"wb-test-task/internal/tuning"
+)
+
+type fakeConsumer struct{ paused bool }
+
+func (f *fakeConsumer) Pause()       { f.paused = true }
+func (f *fakeConsumer) Resume()      { f.paused = false }
+func (f *fakeConsumer) Paused() bool { return f.paused }
+
+func countPresent(c interface{ Get(string) (int, bool) }, n int) int {
+	cnt := 0
+	for i := 0; i < n; i++ {
+		if _, ok := c.Get(fmt.Sprint(i)); ok {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func TestCachePolicies_Resize(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 2, 100, time.Minute)
+			require.NoError(t, err)
+			for i := 0; i < 100; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+
+			c.(cache.Tunable).Resize(20)
+			assert.LessOrEqual(t, countPresent(c, 100), 20, "лишнее вытесняется сразу")
+
+			for i := 100; i < 200; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+			assert.LessOrEqual(t, countPresent(c, 200), 20)
+
+			c.(cache.Tunable).Resize(400)
+			for i := 0; i < 200; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+			assert.Greater(t, countPresent(c, 200), 100, "после увеличения помещается больше")
+		})
+	}
+}
+
+// Ёмкость меньше числа шардов не должна превращаться в «без ограничений».
+func TestCachePolicies_ResizeBelowShardCount(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 16, 1000, time.Minute)
+			require.NoError(t, err)
+			c.(cache.Tunable).Resize(1)
+			for i := 0; i < 200; i++ {
+				c.Set(fmt.Sprint(i), i)
+			}
+			n := countPresent(c, 200)
+			assert.LessOrEqual(t, n, 16, "не больше одного элемента на шард")
+			assert.Positive(t, n)
+		})
+	}
+}
+
+func TestCachePolicies_SetTTLAffectsNewEntries(t *testing.T) {
+	for _, p := range cache.Policies {
+		t.Run(string(p), func(t *testing.T) {
+			c, err := cache.New[int](p, 1, 10, time.Minute)
+			require.NoError(t, err)
+			c.Set("old", 1)
+			c.(cache.Tunable).SetTTL(10 * time.Millisecond)
+			c.Set("new", 2)
+			time.Sleep(20 * time.Millisecond)
+
+			_, ok := c.Get("new")
+			assert.False(t, ok)
+			_, ok = c.Get("old")
+			assert.True(t, ok, "старая запись живёт со своим TTL")
+		})
+	}
+}
+
+func newTuner(t *testing.T) (*tuning.Tuner, cache.Tunable, *fakeConsumer) {
+	setRequiredEnv(t)
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	c, err := cache.New[int](cache.PolicyLRU, 1, cfg.CacheCapacity, cfg.CacheTTL)
+	require.NoError(t, err)
+	consumer := &fakeConsumer{}
+	return tuning.NewTuner(cfg, c.(cache.Tunable), consumer), c.(cache.Tunable), consumer
+}
+
+func TestTuner_AppliesRuntimeKeysAndReportsTheRest(t *testing.T) {
+	tuner, _, consumer := newTuner(t)
+	t.Cleanup(func() { _ = logging.SetLevel("info") })
+
+	res, err := tuner.Update(map[string]string{
+		"cache.capacity": "50",
+		"logging.level":  "debug",
+		"kafka.paused":   "true",
+		"cache.policy":   "lfu",
+		"http.port":      "9090",
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"cache.capacity", "logging.level", "kafka.paused"}, res.Applied)
+	assert.Equal(t, []string{"cache.policy", "http.port"}, res.RestartRequired)
+
+	assert.True(t, consumer.Paused())
+	assert.Equal(t, "debug", logging.Level())
+	cur := tuner.Current()
+	assert.Equal(t, 50, cur.CacheCapacity)
+	assert.Equal(t, "lru", cur.CachePolicy, "настройка без горячей замены не меняется")
+
+	_, err = tuner.Update(map[string]string{"cache.capacity": "-1"})
+	var verr *config.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	_, err = tuner.Update(map[string]string{"cache.size": "1"})
+	assert.ErrorContains(t, err, `unknown setting "cache.size"`)
+}
+
+func TestAdminRuntime_Endpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tuner, _, consumer := newTuner(t)
+	r := routes.InitAdminRoutes(gin.New(), tuner, nil, nil, nil, "s3cret")
+
+	do := func(method, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/runtime", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "", "wrong").Code)
+
+	w := do(http.MethodPatch, `{"cache.ttl":"30s","kafka.paused":true,"kafka.topic":"other"}`, "s3cret")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `["cache.ttl","kafka.paused"]`, jsonField(t, w.Body.Bytes(), "applied"))
+	assert.JSONEq(t, `["kafka.topic"]`, jsonField(t, w.Body.Bytes(), "restart_required"))
+	assert.True(t, consumer.Paused())
+
+	w = do(http.MethodGet, "", "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cache.ttl":"30s"`)
+	assert.Contains(t, w.Body.String(), `"restart_required":["kafka.topic"]`)
+
+	w = do(http.MethodPatch, `{"cache.capacity":"lots"}`, "s3cret")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "CACHE_CAPACITY")
+}
+
+func jsonField(t *testing.T, body []byte, field string) string {
+	var m map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &m))
+	return string(m[field])
+}
+
+func TestTuning_WatchReloadsConfigFile(t *testing.T) {
+	setRequiredEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("cache:\n  capacity: 100\n"), 0o644))
+	opts := config.Options{ConfigFile: path}
+	cfg, _, err := config.LoadWith(opts)
+	require.NoError(t, err)
+
+	c, err := cache.New[int](cache.PolicyLRU, 1, cfg.CacheCapacity, cfg.CacheTTL)
+	require.NoError(t, err)
+	tuner := tuning.NewTuner(cfg, c.(cache.Tunable), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tuning.Watch(ctx, 5*time.Millisecond, tuner, func() (*config.Config, error) {
+		next, _, err := config.LoadWith(opts)
+		return next, err
+	}, path)
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("cache:\n  capacity: 7\n  policy: 2q\n"), 0o644))
+	// mtime на некоторых ФС грубый — сдвигаем явно
+	future := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	require.Eventually(t, func() bool { return tuner.Current().CacheCapacity == 7 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"cache.policy"}, tuner.Pending())
+
+	for i := 0; i < 50; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	assert.LessOrEqual(t, countPresent(c, 50), 7)
+}
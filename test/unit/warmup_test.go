@@ -0,0 +1,206 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/bootstrap"
+	"wb-test-task/internal/cache"
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/routes"
+)
+
+// fakeWarmupSource хранит заказы с датой создания и считает параллельные загрузки.
+type fakeWarmupSource struct {
+	mu       sync.Mutex
+	created  map[string]time.Time
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+}
+
+func (f *fakeWarmupSource) GetOrder(ctx context.Context, uid string) (*models.Order, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		m := f.maxSeen.Load()
+		if n <= m || f.maxSeen.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	return &models.Order{OrderUID: uid}, nil
+}
+
+func (f *fakeWarmupSource) GetOrderUIDs(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var uids []string
+	for _, uid := range []string{"new", "mid", "old"} {
+		if f.created[uid].Before(since) {
+			continue
+		}
+		if limit > 0 && len(uids) == limit {
+			break
+		}
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+func newFakeWarmupSource() *fakeWarmupSource {
+	now := time.Now()
+	return &fakeWarmupSource{created: map[string]time.Time{
+		"new": now.Add(-time.Hour),
+		"mid": now.Add(-30 * time.Hour),
+		"old": now.Add(-300 * time.Hour),
+	}}
+}
+
+func warmedKeys(c *mockCache) []string {
+	var keys []string
+	for _, k := range []string{"new", "mid", "old"} {
+		if _, ok := c.store[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestWarmer_Strategies(t *testing.T) {
+	cases := []struct {
+		cfg  bootstrap.WarmupConfig
+		want []string
+	}{
+		{bootstrap.WarmupConfig{Strategy: bootstrap.WarmupAll}, []string{"new", "mid", "old"}},
+		{bootstrap.WarmupConfig{Strategy: bootstrap.WarmupRecent, Limit: 2}, []string{"new", "mid"}},
+		{bootstrap.WarmupConfig{Strategy: bootstrap.WarmupWindow, Window: 48 * time.Hour}, []string{"new", "mid"}},
+		{bootstrap.WarmupConfig{Strategy: bootstrap.WarmupNone}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.cfg.Strategy), func(t *testing.T) {
+			c := newMockCache()
+			tc.cfg.Concurrency = 1
+			w := bootstrap.NewWarmer(newFakeWarmupSource(), c, tc.cfg)
+			require.False(t, w.Ready())
+			w.Run(context.Background())
+			assert.True(t, w.Ready())
+			assert.Equal(t, tc.want, warmedKeys(c))
+		})
+	}
+}
+
+func TestWarmer_BoundedConcurrency(t *testing.T) {
+	src := newFakeWarmupSource()
+	for i := 0; i < 50; i++ {
+		src.created[string(rune('a'+i))] = time.Now()
+	}
+	uidsPath := filepath.Join(t.TempDir(), "hot")
+	var body []byte
+	for k := range src.created {
+		body = append(body, k+"\n"...)
+	}
+	require.NoError(t, os.WriteFile(uidsPath, body, 0o644))
+
+	c := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	w := bootstrap.NewWarmer(src, c, bootstrap.WarmupConfig{Strategy: bootstrap.WarmupHot, HotKeysPath: uidsPath, Concurrency: 3})
+	w.Run(context.Background())
+
+	assert.LessOrEqual(t, src.maxSeen.Load(), int32(3))
+	_, ok := c.Get("new")
+	assert.True(t, ok)
+}
+
+func TestHotKeys_SaveAndWarm(t *testing.T) {
+	c := cache.NewShardedLRU[*models.Order](1, 100, time.Minute)
+	c.Set("old", &models.Order{OrderUID: "old"})
+	c.Set("new", &models.Order{OrderUID: "new"})
+
+	path := filepath.Join(t.TempDir(), "cache.hotkeys")
+	bootstrap.SaveHotKeys(path, c, 1)
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(raw))
+
+	dst := newMockCache()
+	bootstrap.NewWarmer(newFakeWarmupSource(), dst, bootstrap.WarmupConfig{Strategy: bootstrap.WarmupHot, HotKeysPath: path, Concurrency: 1}).Run(context.Background())
+	assert.Equal(t, []string{"new"}, warmedKeys(dst))
+}
+
+func TestHotKeys_FollowCachePolicy(t *testing.T) {
+	c, err := cache.New[*models.Order](cache.PolicyLFU, 1, 100, time.Minute)
+	require.NoError(t, err)
+	for _, uid := range []string{"a", "b", "c"} {
+		c.Set(uid, &models.Order{OrderUID: uid})
+	}
+	for i := 0; i < 3; i++ {
+		c.Get("a")
+	}
+	c.Get("b")
+
+	path := filepath.Join(t.TempDir(), "cache.hotkeys")
+	bootstrap.SaveHotKeys(path, c, 2)
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(raw), "LFU: самые частые первыми, а не вставленные последними")
+}
+
+func TestWarmer_EmptySnapshotFallsBackToDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	_, err := cache.SaveSnapshot[*models.Order](path, cache.NewShardedLRU[*models.Order](4, 100, time.Minute))
+	require.NoError(t, err)
+
+	dst := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	bootstrap.NewWarmer(newFakeWarmupSource(), dst, bootstrap.WarmupConfig{Strategy: bootstrap.WarmupAll, SnapshotPath: path}).Run(context.Background())
+	_, ok := dst.Get("new")
+	assert.True(t, ok, "пустой снимок не повод оставить кэш пустым")
+}
+
+func TestWarmer_PrefersSnapshot(t *testing.T) {
+	src := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	src.Set("snap", &models.Order{OrderUID: "snap"})
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	_, err := cache.SaveSnapshot[*models.Order](path, src)
+	require.NoError(t, err)
+
+	dst := cache.NewShardedLRU[*models.Order](4, 100, time.Minute)
+	bootstrap.NewWarmer(newFakeWarmupSource(), dst, bootstrap.WarmupConfig{Strategy: bootstrap.WarmupAll, SnapshotPath: path}).Run(context.Background())
+
+	_, ok := dst.Get("snap")
+	assert.True(t, ok)
+	_, ok = dst.Get("new")
+	assert.False(t, ok, "при удачном снимке БД не трогаем")
+}
+
+func TestRoutes_Readiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var ready atomic.Bool
+	r := routes.InitHealthRoutes(gin.New(), ready.Load)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	ready.Store(true)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestParseWarmupStrategy(t *testing.T) {
+	s, err := bootstrap.ParseWarmupStrategy("Recent")
+	require.NoError(t, err)
+	assert.Equal(t, bootstrap.WarmupRecent, s)
+	_, err = bootstrap.ParseWarmupStrategy("lucky")
+	assert.Error(t, err)
+}
@@ -0,0 +1,471 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"wb-test-task/internal/models"
+	"wb-test-task/internal/ports"
+	"wb-test-task/internal/routes"
+	"wb-test-task/internal/webhooks"
+)
+
+// fakeWebhooks хранит подписки и доставки в памяти и применяет результаты
+// попыток так же, как ProcessDeliveries в Postgres.
+type fakeWebhooks struct {
+	mu         sync.Mutex
+	nextID     int64
+	subs       map[int64]*models.WebhookSubscription
+	deliveries []*models.WebhookDelivery
+}
+
+func newFakeWebhooks() *fakeWebhooks {
+	return &fakeWebhooks{subs: map[int64]*models.WebhookSubscription{}}
+}
+
+func (f *fakeWebhooks) subscribe(url, secret string) int64 {
+	s := &models.WebhookSubscription{URL: url, Secret: secret, EventTypes: webhooks.EventTypes, Active: true}
+	_ = f.CreateSubscription(context.Background(), s)
+	return s.ID
+}
+
+func (f *fakeWebhooks) enqueue(subID int64, eventType, uid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	f.deliveries = append(f.deliveries, &models.WebhookDelivery{
+		ID: f.nextID, SubscriptionID: subID, EventID: fmt.Sprintf("ev-%d", f.nextID), EventType: eventType,
+		Payload: json.RawMessage(fmt.Sprintf(`{"order_uid":%q}`, uid)), Status: models.WebhookPending,
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	})
+}
+
+func (f *fakeWebhooks) delivery(id int64) models.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range f.deliveries {
+		if d.ID == id {
+			return *d
+		}
+	}
+	return models.WebhookDelivery{}
+}
+
+// expireBackoff имитирует, что время повтора уже наступило.
+func (f *fakeWebhooks) expireBackoff() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range f.deliveries {
+		d.NextAttemptAt = time.Time{}
+	}
+}
+
+func (f *fakeWebhooks) CreateSubscription(_ context.Context, s *models.WebhookSubscription) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	s.ID = f.nextID
+	s.CreatedAt, s.UpdatedAt = time.Now(), time.Now()
+	cp := *s
+	f.subs[s.ID] = &cp
+	return nil
+}
+
+func (f *fakeWebhooks) Subscriptions(context.Context) ([]models.WebhookSubscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.WebhookSubscription
+	for id := int64(1); id <= f.nextID; id++ {
+		if s, ok := f.subs[id]; ok {
+			out = append(out, *s)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeWebhooks) Subscription(_ context.Context, id int64) (*models.WebhookSubscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.subs[id]
+	if !ok {
+		return nil, ports.ErrSubscriptionNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (f *fakeWebhooks) UpdateSubscription(_ context.Context, s *models.WebhookSubscription) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[s.ID]; !ok {
+		return ports.ErrSubscriptionNotFound
+	}
+	cp := *s
+	f.subs[s.ID] = &cp
+	return nil
+}
+
+func (f *fakeWebhooks) DeleteSubscription(_ context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subs[id]; !ok {
+		return ports.ErrSubscriptionNotFound
+	}
+	delete(f.subs, id)
+	return nil
+}
+
+func (f *fakeWebhooks) Deliveries(_ context.Context, subID int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []models.WebhookDelivery
+	for i := len(f.deliveries) - 1; i >= 0 && len(out) < limit; i-- {
+		d := f.deliveries[i]
+		if d.SubscriptionID == subID && (status == "" || d.Status == status) {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeWebhooks) Redeliver(_ context.Context, subID, deliveryID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range f.deliveries {
+		if d.ID == deliveryID && d.SubscriptionID == subID {
+			d.Status, d.Attempts, d.NextAttemptAt = models.WebhookPending, 0, time.Time{}
+			return nil
+		}
+	}
+	return ports.ErrDeliveryNotFound
+}
+
+func (f *fakeWebhooks) ProcessDeliveries(ctx context.Context, limit int, send func(context.Context, []models.WebhookDelivery) []ports.WebhookAttempt) (int, error) {
+	f.mu.Lock()
+	var batch []*models.WebhookDelivery
+	for _, d := range f.deliveries {
+		s := f.subs[d.SubscriptionID]
+		if d.Status != models.WebhookPending || d.NextAttemptAt.After(time.Now()) || s == nil || !s.Active || len(batch) == limit {
+			continue
+		}
+		batch = append(batch, d)
+	}
+	ds := make([]models.WebhookDelivery, len(batch))
+	for i, d := range batch {
+		ds[i] = *d
+		ds[i].URL, ds[i].Secret = f.subs[d.SubscriptionID].URL, f.subs[d.SubscriptionID].Secret
+	}
+	f.mu.Unlock()
+	if len(ds) == 0 {
+		return 0, nil
+	}
+
+	results := send(ctx, ds)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delivered := 0
+	for i, d := range batch {
+		res := results[i]
+		d.Status, d.NextAttemptAt = res.Status, res.NextAttemptAt
+		if res.Counted {
+			d.Attempts++
+			d.LastStatusCode, d.LastError = res.StatusCode, res.Error
+		}
+		if res.Status == models.WebhookDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// receiver — httptest-сервер, который проверяет подпись и отвечает кодом из status.
+type receiver struct {
+	*httptest.Server
+	secret string
+	status atomic.Int32
+	hits   atomic.Int32
+
+	mu     sync.Mutex
+	bodies []webhooks.Body
+	heads  []http.Header
+	errs   []error
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{secret: secret}
+	r.status.Store(http.StatusOK)
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.hits.Add(1)
+		raw, _ := io.ReadAll(req.Body)
+		err := webhooks.Verify(r.secret, req.Header.Get(webhooks.TimestampHeader), req.Header.Get(webhooks.SignatureHeader), raw, 5*time.Minute, time.Now())
+		var body webhooks.Body
+		_ = json.Unmarshal(raw, &body)
+		r.mu.Lock()
+		r.bodies = append(r.bodies, body)
+		r.heads = append(r.heads, req.Header.Clone())
+		r.errs = append(r.errs, err)
+		r.mu.Unlock()
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(int(r.status.Load()))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestWebhookDeliverySignedWithTimestamp(t *testing.T) {
+	store := newFakeWebhooks()
+	rcv := newReceiver(t, "whsec_test")
+	sub := store.subscribe(rcv.URL, "whsec_test")
+	store.enqueue(sub, "order.stored", "b563feb7b2b84b6test")
+
+	n, err := webhooks.NewSender(store, webhooks.Config{}).Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	require.Len(t, rcv.bodies, 1)
+	assert.NoError(t, rcv.errs[0])
+	assert.Equal(t, "ev-2", rcv.bodies[0].ID)
+	assert.Equal(t, "order.stored", rcv.bodies[0].Type)
+	assert.JSONEq(t, `{"order_uid":"b563feb7b2b84b6test"}`, string(rcv.bodies[0].Data))
+	assert.Equal(t, "ev-2", rcv.heads[0].Get(webhooks.EventIDHeader))
+	assert.Equal(t, "order.stored", rcv.heads[0].Get(webhooks.EventTypeHeader))
+	assert.Equal(t, "2", rcv.heads[0].Get(webhooks.DeliveryHeader))
+	assert.True(t, strings.HasPrefix(rcv.heads[0].Get(webhooks.SignatureHeader), "sha256="))
+
+	d := store.delivery(2)
+	assert.Equal(t, models.WebhookDelivered, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusOK, d.LastStatusCode)
+}
+
+func TestWebhookWrongSecretIsRejectedByReceiver(t *testing.T) {
+	store := newFakeWebhooks()
+	rcv := newReceiver(t, "whsec_receiver")
+	sub := store.subscribe(rcv.URL, "whsec_other")
+	store.enqueue(sub, "order.updated", "uid-1")
+
+	n, err := webhooks.NewSender(store, webhooks.Config{}).Flush(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	require.Len(t, rcv.errs, 1)
+	assert.ErrorIs(t, rcv.errs[0], webhooks.ErrBadSignature)
+
+	d := store.delivery(2)
+	assert.Equal(t, models.WebhookPending, d.Status)
+	assert.Equal(t, http.StatusUnauthorized, d.LastStatusCode)
+}
+
+func TestWebhookVerify(t *testing.T) {
+	body := []byte(`{"id":"ev-1"}`)
+	now := time.Unix(1714557600, 0)
+	sig := webhooks.Sign("s", now, body)
+	ts := fmt.Sprint(now.Unix())
+
+	assert.NoError(t, webhooks.Verify("s", ts, sig, body, time.Minute, now.Add(30*time.Second)))
+	assert.ErrorIs(t, webhooks.Verify("s", ts, sig, body, time.Minute, now.Add(2*time.Minute)), webhooks.ErrStale)
+	assert.ErrorIs(t, webhooks.Verify("s", ts, sig, []byte(`{"id":"ev-2"}`), time.Minute, now), webhooks.ErrBadSignature)
+	// подпись привязана ко времени: старую нельзя переиспользовать с новым timestamp
+	assert.ErrorIs(t, webhooks.Verify("s", fmt.Sprint(now.Unix()+1), sig, body, time.Minute, now), webhooks.ErrBadSignature)
+	assert.Error(t, webhooks.Verify("s", "yesterday", sig, body, 0, now))
+}
+
+func TestWebhookRetriesWithBackoffThenFails(t *testing.T) {
+	store := newFakeWebhooks()
+	rcv := newReceiver(t, "k")
+	rcv.status.Store(http.StatusInternalServerError)
+	sub := store.subscribe(rcv.URL, "k")
+	store.enqueue(sub, "order.deleted", "uid-1")
+	sender := webhooks.NewSender(store, webhooks.Config{MinBackoff: time.Second, MaxBackoff: 3 * time.Second, MaxAttempts: 4})
+
+	var delays []time.Duration
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		_, err := sender.Flush(context.Background())
+		require.NoError(t, err)
+		d := store.delivery(2)
+		require.Equal(t, models.WebhookPending, d.Status)
+		assert.Equal(t, i+1, d.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, d.LastStatusCode)
+		assert.Contains(t, d.LastError, "500")
+		delays = append(delays, d.NextAttemptAt.Sub(start).Round(time.Second))
+
+		// до срока повтор не уходит
+		_, err = sender.Flush(context.Background())
+		require.NoError(t, err)
+		assert.EqualValues(t, i+1, rcv.hits.Load())
+		store.expireBackoff()
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, delays)
+
+	_, err := sender.Flush(context.Background())
+	require.NoError(t, err)
+	d := store.delivery(2)
+	assert.Equal(t, models.WebhookFailed, d.Status)
+	assert.Equal(t, 4, d.Attempts)
+
+	store.expireBackoff()
+	_, err = sender.Flush(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, rcv.hits.Load(), "failed deliveries are not retried")
+}
+
+func TestWebhookRedirectIsNotFollowed(t *testing.T) {
+	target := newReceiver(t, "k")
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+	store := newFakeWebhooks()
+	sub := store.subscribe(redirect.URL, "k")
+	store.enqueue(sub, "order.stored", "uid-1")
+
+	_, err := webhooks.NewSender(store, webhooks.Config{}).Flush(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, target.hits.Load())
+	assert.Equal(t, http.StatusTemporaryRedirect, store.delivery(2).LastStatusCode)
+}
+
+func TestWebhookCircuitBreakerPerSubscription(t *testing.T) {
+	store := newFakeWebhooks()
+	down := newReceiver(t, "k")
+	down.status.Store(http.StatusServiceUnavailable)
+	up := newReceiver(t, "k")
+	bad := store.subscribe(down.URL, "k")
+	good := store.subscribe(up.URL, "k")
+	for i := 0; i < 5; i++ {
+		store.enqueue(bad, "order.updated", fmt.Sprintf("uid-%d", i))
+	}
+	store.enqueue(good, "order.updated", "uid-ok")
+	sender := webhooks.NewSender(store, webhooks.Config{BreakerThreshold: 2, BreakerCooldown: time.Hour})
+
+	n, err := sender.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "healthy subscription is not affected")
+	assert.EqualValues(t, 1, up.hits.Load())
+	assert.EqualValues(t, 2, down.hits.Load(), "circuit opens after threshold failures")
+	assert.Equal(t, webhooks.CircuitOpen, sender.CircuitState(bad))
+	assert.Equal(t, webhooks.CircuitClosed, sender.CircuitState(good))
+
+	ds, err := store.Deliveries(context.Background(), bad, models.WebhookPending, 10)
+	require.NoError(t, err)
+	require.Len(t, ds, 5)
+	for _, d := range ds[:3] {
+		assert.Zero(t, d.Attempts, "deferred deliveries do not spend attempts")
+		assert.WithinDuration(t, time.Now().Add(time.Hour), d.NextAttemptAt, time.Minute)
+	}
+
+	// пока предохранитель разомкнут, запросов нет даже для просроченных доставок
+	store.expireBackoff()
+	_, err = sender.Flush(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, down.hits.Load())
+}
+
+func TestWebhookBreakerHalfOpen(t *testing.T) {
+	b := webhooks.NewBreaker(2, time.Minute)
+	now := time.Now()
+
+	b.Failure(7, now)
+	ok, _ := b.Allow(7, now)
+	assert.True(t, ok, "below threshold")
+	b.Failure(7, now)
+	ok, until := b.Allow(7, now)
+	assert.False(t, ok)
+	assert.Equal(t, now.Add(time.Minute), until)
+
+	later := now.Add(time.Minute)
+	assert.Equal(t, webhooks.CircuitHalfOpen, b.State(7, later))
+	ok, _ = b.Allow(7, later)
+	assert.True(t, ok, "one probe after cooldown")
+	ok, _ = b.Allow(7, later)
+	assert.False(t, ok, "only one probe at a time")
+
+	// неудачная проба сразу размыкает снова
+	b.Failure(7, later)
+	assert.Equal(t, webhooks.CircuitOpen, b.State(7, later))
+
+	ok, _ = b.Allow(7, later.Add(time.Minute))
+	require.True(t, ok)
+	b.Success(7)
+	assert.Equal(t, webhooks.CircuitClosed, b.State(7, later.Add(time.Minute)))
+}
+
+func TestWebhookAdminAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := newFakeWebhooks()
+	sender := webhooks.NewSender(store, webhooks.Config{})
+	tuner, _, _ := newTuner(t)
+	r := routes.InitAdminRoutes(gin.New(), tuner, nil, store, sender, "s3cret")
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/admin/webhooks", `{"url":"ftp://example.com","event_types":["order.stored"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/admin/webhooks", `{"url":"https://example.com/hook","event_types":["order.paid"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/admin/webhooks", `{"url":"https://example.com/hook","event_types":["order.stored","order.updated"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		ID      int64  `json:"id"`
+		Secret  string `json:"secret"`
+		Active  bool   `json:"active"`
+		Circuit string `json:"circuit"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Secret, "whsec_"), "secret is generated")
+	assert.True(t, created.Active)
+	assert.Equal(t, webhooks.CircuitClosed, created.Circuit)
+
+	w = do(http.MethodGet, "/admin/webhooks", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret, "secret is shown only once")
+	assert.Contains(t, w.Body.String(), "https://example.com/hook")
+
+	w = do(http.MethodPatch, fmt.Sprintf("/admin/webhooks/%d", created.ID), `{"active":false,"rotate_secret":true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	s, err := store.Subscription(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.False(t, s.Active)
+	assert.NotEqual(t, created.Secret, s.Secret)
+	assert.Contains(t, w.Body.String(), s.Secret)
+	assert.Equal(t, []string{"order.stored", "order.updated"}, s.EventTypes, "untouched fields are kept")
+
+	store.enqueue(created.ID, "order.stored", "uid-1")
+	w = do(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d/deliveries?status=pending", created.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var ds []models.WebhookDelivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ds))
+	require.Len(t, ds, 1)
+	assert.Equal(t, "order.stored", ds[0].EventType)
+
+	w = do(http.MethodPost, fmt.Sprintf("/admin/webhooks/%d/deliveries/%d/redeliver", created.ID, ds[0].ID), "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = do(http.MethodPost, fmt.Sprintf("/admin/webhooks/%d/deliveries/999/redeliver", created.ID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/webhooks/999/deliveries", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/webhooks/abc", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, fmt.Sprintf("/admin/webhooks/%d", created.ID), "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, fmt.Sprintf("/admin/webhooks/%d", created.ID), "").Code)
+}